@@ -2,11 +2,17 @@ package main
 
 import (
 	"log"
+	"pwa-rsbw/internal/audit"
 	"pwa-rsbw/internal/auth"
 	"pwa-rsbw/internal/config"
+	"pwa-rsbw/internal/cppt"
 	"pwa-rsbw/internal/database"
+	"pwa-rsbw/internal/lab"
 	"pwa-rsbw/internal/listranap"
 	"pwa-rsbw/internal/notifications"
+	"pwa-rsbw/internal/obat"
+	"pwa-rsbw/internal/radiologi"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -32,18 +38,78 @@ func main() {
 	// KONEKSI DATABASE TUNGGAL
 	db, sqlDB_worker := database.Connect(cfg)
 
+	// Tabel milik aplikasi (refresh token, dll.)
+	database.Migrate(db,
+		&auth.RefreshToken{},
+		&auth.RevokedToken{},
+		&auth.LoginAttempt{},
+		&auth.LocalAccount{},
+		&auth.MFATOTP{},
+		&auth.MFARecoveryCode{},
+		&auth.SigningKey{},
+		&auth.Session{},
+		&auth.DevicePIN{},
+		&auth.OIDCState{},
+		&auth.OIDCIdentity{},
+		&auth.APIKey{},
+		&auth.ImpersonationLog{},
+		&audit.PatientAccessLog{},
+		&listranap.EmergencyAccess{},
+		&cppt.CpptRevision{},
+		&cppt.CpptVerification{},
+		&cppt.CpptSyncRecord{},
+		&cppt.CpptTemplate{},
+		&obat.ObatGenerik{},
+		&obat.ObatFormularium{},
+	)
+
+	// Log akses pasien append-only juga di level database
+	if err := audit.EnsureAppendOnly(db); err != nil {
+		log.Fatalf("❌ Failed to enforce append-only audit log: %v", err)
+	}
+
 	// --- DEPENDENCY INJECTION (Merakit semua lapisan) ---
-	authRepo := auth.NewAuthRepository(db)
+	authRepo := auth.NewAuthRepository(db, cfg.KhanzaAESKeyUser, cfg.KhanzaAESKeyPassword)
+	auditRepo := audit.NewAuditRepository(db)
 	listRanapRepo := listranap.NewPasienRepository(db)
+	cpptRepo := cppt.NewCpptRepository(db)
+	labRepo := lab.NewLabRepository(db)
+	radiologiRepo := radiologi.NewRadiologiRepository(db)
+	obatRepo := obat.NewObatRepository(db)
 	notificationRepo := notifications.NewRepository(sqlDB_worker)
 
 	// Inisialisasi Service dan Handler
-	authService := auth.NewAuthService(authRepo, cfg.JWTSecret)
+	credentialVerifier := auth.NewCredentialVerifier(cfg, authRepo)
+	keyManager, err := auth.NewKeyManager(authRepo, cfg)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize JWT signing keys: %v", err)
+	}
+	authService := auth.NewAuthService(authRepo, credentialVerifier, keyManager, cfg)
 	authHandler := auth.NewAuthHandler(authService)
 
-	listRanapService := listranap.NewPasienService(listRanapRepo)
+	auditService := audit.NewAuditService(auditRepo)
+	auditHandler := audit.NewAuditHandler(auditService)
+
+	listRanapService := listranap.NewPasienService(listRanapRepo, notificationRepo, cfg.EmergencyAccessTTL)
 	listRanapHandler := listranap.NewPasienHandler(listRanapService)
 
+	cpptService := cppt.NewCpptService(cpptRepo, listRanapService, cfg.CpptEditWindow)
+	cpptHandler := cppt.NewCpptHandler(cpptService)
+
+	labService := lab.NewLabService(labRepo, listRanapService)
+	labHandler := lab.NewLabHandler(labService)
+
+	radiologiService := radiologi.NewRadiologiService(radiologiRepo, listRanapService, cfg.RadiologiStoragePath, cfg.RadiologiURLSecret, cfg.RadiologiURLTTL)
+	radiologiHandler := radiologi.NewRadiologiHandler(radiologiService)
+
+	// Indeks pencarian obat dimuat sekali saat start, lalu di-refresh oleh worker
+	obatIndex := obat.NewObatIndex(obatRepo)
+	if err := obatIndex.Refresh(); err != nil {
+		log.Printf("⚠️ Drug index not loaded at startup, will retry on next refresh: %v", err)
+	}
+	obatService := obat.NewObatService(obatRepo, listRanapService, cfg.ResepKdDepo, obatIndex)
+	obatHandler := obat.NewObatHandler(obatService)
+
 	// ✅ PERBAIKAN: Berikan AppID, APIKey, dan FrontendURL ke Service
 	notificationService := notifications.NewService(
 		notificationRepo,
@@ -57,12 +123,29 @@ func main() {
 	// Setup router Gin
 	r := gin.Default()
 
+	// c.ClientIP() dipakai untuk lockout per IP; X-Forwarded-For hanya dipercaya dari proxy sendiri
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("❌ Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// no_rawat Khanza berformat "2025/01/31/000001", frontend mengirimnya sebagai %2F
+	r.UseRawPath = true
+	r.UnescapePathValues = true
+
 	// Middleware untuk CORS
+	// Login SSO memakai cookie oidc_state, jadi khusus /auth/oidc/ origin frontend boleh kirim credentials
+	frontendOrigin := strings.TrimSuffix(cfg.FrontendURL, "/")
 	r.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if strings.HasPrefix(c.Request.URL.Path, "/api/v1/auth/oidc/") && c.GetHeader("Origin") == frontendOrigin {
+			c.Header("Access-Control-Allow-Origin", frontendOrigin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+			c.Header("Vary", "Origin")
+		} else {
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("Access-Control-Allow-Credentials", "false")
+		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, X-Requested-With, Origin, ngrok-skip-browser-warning")
-		c.Header("Access-Control-Allow-Credentials", "false")
+		c.Header("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, X-Requested-With, Origin, ngrok-skip-browser-warning, X-Device-ID, X-API-Key")
 		c.Header("Access-Control-Max-Age", "86400")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -80,25 +163,45 @@ func main() {
 		})
 	})
 
+	// JWKS untuk layanan lain (kosong jika JWT_ALGORITHM=HS256)
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	apiV1 := r.Group("/api/v1")
 	apiV1.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "success", "message": "API is running"})
 	})
 
+	// Gambar radiologi: publik, dilindungi URL bertanda tangan yang berumur pendek
+	apiV1.GET("/radiologi/gambar", radiologiHandler.ServeImage)
+
 	// Rute Auth (Publik dan Dilindungi)
 	authRoutes := apiV1.Group("/auth")
 	{
 		authRoutes.POST("/login", authHandler.Login)
+		authRoutes.POST("/refresh", authHandler.Refresh)
+		authRoutes.POST("/mfa/verify", authHandler.VerifyMFA)
+		authRoutes.POST("/pin/unlock", authHandler.UnlockWithPIN)
+		authRoutes.GET("/oidc/login", authHandler.StartOIDCLogin)
+		authRoutes.POST("/oidc/callback", authHandler.OIDCCallback)
 		authProtected := authRoutes.Group("")
 		authProtected.Use(authHandler.JWTMiddleware())
 		{
 			authProtected.GET("/validate", authHandler.Validate)
+			authProtected.POST("/logout", authHandler.Logout)
+			authProtected.POST("/mfa/enroll", authHandler.EnrollMFA)
+			authProtected.POST("/mfa/confirm", authHandler.ConfirmMFA)
+			authProtected.POST("/mfa/disable", authHandler.DisableMFA)
+			authProtected.GET("/sessions", authHandler.ListSessions)
+			authProtected.DELETE("/sessions", authHandler.RevokeAllSessions)
+			authProtected.DELETE("/sessions/:session_id", authHandler.RevokeSession)
+			authProtected.POST("/pin", authHandler.RegisterPIN)
+			authProtected.DELETE("/pin", authHandler.RemovePIN)
 		}
 	}
 
-	// Rute yang Dilindungi (Membutuhkan JWT)
+	// Rute yang Dilindungi (JWT, atau X-API-Key untuk service account)
 	protectedRoutes := apiV1.Group("/")
-	protectedRoutes.Use(authHandler.JWTMiddleware())
+	protectedRoutes.Use(authHandler.AuthMiddleware())
 	{
 		protectedRoutes.GET("/profile", func(c *gin.Context) {
 			idUser := c.GetString("id_user")
@@ -114,16 +217,71 @@ func main() {
 
 		// Rute Ranap (Rawat Inap)
 		ranapRoutes := protectedRoutes.Group("/ranap")
+		ranapRoutes.Use(auth.RequirePermission(auth.PermRanapRead))
 		{
 			ranapRoutes.GET("/profile", listRanapHandler.GetDokterProfile)
-			ranapRoutes.GET("/pasien", listRanapHandler.GetPasienRawatInapAktif)
-			ranapRoutes.GET("/pasien/:no_rat", listRanapHandler.GetPasienDetail)
+			ranapRoutes.GET("/pasien", auditHandler.Track(audit.ActionViewList), listRanapHandler.GetPasienRawatInapAktif)
+			ranapRoutes.GET("/pasien/:no_rawat", auditHandler.Track(audit.ActionViewDetail), listRanapHandler.GetPasienDetail)
+			ranapRoutes.GET("/riwayat", auditHandler.Track(audit.ActionViewList), listRanapHandler.GetRiwayatPasien)
+			ranapRoutes.GET("/riwayat/:no_rawat", auditHandler.Track(audit.ActionViewDetail), listRanapHandler.GetRiwayatDetail)
+			ranapRoutes.POST("/pasien/:no_rawat/emergency-access", auditHandler.Track(audit.ActionEmergencyAccess), listRanapHandler.RequestEmergencyAccess)
+			ranapRoutes.GET("/cppt/templates", auth.RequirePermission(auth.PermCpptWrite), cpptHandler.ListTemplates)
+			ranapRoutes.POST("/cppt/templates", auth.RequirePermission(auth.PermCpptWrite), cpptHandler.CreateTemplate)
+			ranapRoutes.PUT("/cppt/templates/:template_id", auth.RequirePermission(auth.PermCpptWrite), cpptHandler.UpdateTemplate)
+			ranapRoutes.DELETE("/cppt/templates/:template_id", auth.RequirePermission(auth.PermCpptWrite), cpptHandler.DeleteTemplate)
+			ranapRoutes.POST("/cppt/templates/:template_id/expand/:no_rawat", auth.RequirePermission(auth.PermCpptWrite), auditHandler.Track(audit.ActionViewDetail), cpptHandler.ExpandTemplate)
+			ranapRoutes.POST("/cppt/sync", auth.RequirePermission(auth.PermCpptWrite), auditHandler.Track(audit.ActionSyncCppt), cpptHandler.SyncDrafts)
+			ranapRoutes.GET("/pasien/:no_rawat/lab", auth.RequirePermission(auth.PermLabRead), auditHandler.Track(audit.ActionViewLab), labHandler.GetLabResults)
+			ranapRoutes.GET("/pasien/:no_rawat/radiologi", auth.RequirePermission(auth.PermRadiologiRead), auditHandler.Track(audit.ActionViewRadiologi), radiologiHandler.GetRadiologi)
+			ranapRoutes.GET("/pasien/:no_rawat/obat", auth.RequirePermission(auth.PermObatRead), auditHandler.Track(audit.ActionViewObat), obatHandler.GetObatPasien)
+			ranapRoutes.POST("/pasien/:no_rawat/resep", auth.RequirePermission(auth.PermResepWrite), auditHandler.Track(audit.ActionWriteResep), obatHandler.CreateResep)
+			ranapRoutes.GET("/pasien/:no_rawat/cppt", auth.RequirePermission(auth.PermCpptRead), auditHandler.Track(audit.ActionViewCppt), cpptHandler.GetCpptHistory)
+			ranapRoutes.POST("/pasien/:no_rawat/cppt", auth.RequirePermission(auth.PermCpptWrite), auditHandler.Track(audit.ActionWriteCppt), cpptHandler.CreateCppt)
+			ranapRoutes.PUT("/pasien/:no_rawat/cppt/:tgl_perawatan/:jam_rawat", auth.RequirePermission(auth.PermCpptWrite), auditHandler.Track(audit.ActionEditCppt), cpptHandler.UpdateCppt)
+			ranapRoutes.GET("/pasien/:no_rawat/cppt/:tgl_perawatan/:jam_rawat/revisions", auth.RequirePermission(auth.PermCpptRead), auditHandler.Track(audit.ActionViewCppt), cpptHandler.GetRevisions)
+			ranapRoutes.POST("/pasien/:no_rawat/cppt/:tgl_perawatan/:jam_rawat/verify", auth.RequirePermission(auth.PermCpptWrite), auditHandler.Track(audit.ActionVerifyCppt), cpptHandler.VerifyCppt)
+		}
+
+		// Katalog obat (bukan data pasien, tidak diaudit)
+		protectedRoutes.GET("/obat/search", auth.RequirePermission(auth.PermObatRead), obatHandler.SearchObat)
+
+		// Rute Audit (privacy officer / komite medik)
+		auditRoutes := protectedRoutes.Group("/audit")
+		auditRoutes.Use(auth.RequirePermission(auth.PermAuditRead))
+		{
+			auditRoutes.GET("/patient-access", auditHandler.GetAccessLogs)
+		}
+
+		// Rute Admin
+		adminRoutes := protectedRoutes.Group("/admin")
+		adminRoutes.Use(auth.RequirePermission(auth.PermAdmin))
+		{
+			adminRoutes.GET("/auth/lockouts", authHandler.GetLoginLockouts)
+			adminRoutes.POST("/auth/unlock", authHandler.UnlockLogin)
+			adminRoutes.GET("/auth/oidc-identities", authHandler.GetOIDCIdentities)
+			adminRoutes.POST("/auth/oidc-identities", authHandler.LinkOIDCIdentity)
+			adminRoutes.DELETE("/auth/oidc-identities/:subject", authHandler.UnlinkOIDCIdentity)
+			adminRoutes.GET("/api-keys", authHandler.ListAPIKeys)
+			adminRoutes.POST("/api-keys", authHandler.CreateAPIKey)
+			adminRoutes.DELETE("/api-keys/:id", authHandler.RevokeAPIKey)
+			adminRoutes.POST("/impersonate", authHandler.Impersonate)
+			adminRoutes.GET("/impersonations", authHandler.GetImpersonationLogs)
+
+			// Master generik & formularium untuk pencarian obat dan cek e-resep
+			adminRoutes.GET("/obat/generik", obatHandler.GetGenerik)
+			adminRoutes.PUT("/obat/generik", obatHandler.ImportGenerik)
+			adminRoutes.DELETE("/obat/generik/:kode_brng", obatHandler.DeleteGenerik)
+			adminRoutes.GET("/obat/formularium/:kd_pj", obatHandler.GetFormularium)
+			adminRoutes.PUT("/obat/formularium/:kd_pj", obatHandler.ImportFormularium)
+			adminRoutes.DELETE("/obat/formularium/:kd_pj/:kode_brng", obatHandler.DeleteFormularium)
 		}
 	}
 	// --- AKHIR DARI ROUTING ---
 
 	// --- TAMBAHAN: JALANKAN WORKER ---
 	go notificationService.StartWorker(5 * time.Second)
+	go keyManager.StartRotation(auth.KeyReloadInterval)
+	go obatIndex.StartRefresh(cfg.ObatIndexRefresh)
 
 	// Jalankan Server
 	serverAddr := "0.0.0.0:" + cfg.ServerPort
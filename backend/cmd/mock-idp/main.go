@@ -0,0 +1,272 @@
+// mock-idp adalah IdP OpenID Connect sederhana untuk development/testing login SSO.
+// JANGAN dipakai di production: tidak ada password, user dipilih dari daftar.
+//
+//	MOCK_IDP_ADDR=:9000
+//	MOCK_IDP_ISSUER=http://localhost:9000
+//	MOCK_IDP_USERS=dr.andi:D0001,dr.sari:D0002   (subject:kd_dokter)
+//
+// Backend: OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=pwa-rsbw OIDC_KD_DOKTER_CLAIM=kd_dokter
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyID   = "mock-idp-key"
+	codeTTL = 2 * time.Minute
+)
+
+type mockUser struct {
+	Subject  string
+	KdDokter string
+}
+
+type authCode struct {
+	subject       string
+	kdDokter      string
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+type mockIdP struct {
+	issuer string
+	users  []mockUser
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html><head><title>Mock IdP</title></head>
+<body style="font-family:sans-serif;max-width:420px;margin:40px auto">
+<h2>Mock IdP - pilih user</h2>
+<form method="post">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}
+<select name="subject">
+{{range .Users}}<option value="{{.Subject}}">{{.Subject}} ({{.KdDokter}})</option>
+{{end}}
+</select>
+<button type="submit">Login</button>
+</form>
+</body></html>`))
+
+func main() {
+	addr := getEnv("MOCK_IDP_ADDR", ":9000")
+	issuer := strings.TrimSuffix(getEnv("MOCK_IDP_ISSUER", "http://localhost:9000"), "/")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Gagal membuat key: %v", err)
+	}
+
+	idp := &mockIdP{
+		issuer: issuer,
+		users:  parseUsers(getEnv("MOCK_IDP_USERS", "dr.test:D0001")),
+		key:    key,
+		codes:  make(map[string]authCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks.json", idp.jwks)
+
+	log.Printf("⚠️ Mock IdP (development only) berjalan di %s, issuer %s", addr, issuer)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+func (p *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize: GET menampilkan pilihan user, POST menerbitkan code dan redirect ke client
+func (p *mockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		if r.Form.Get("response_type") != "code" || r.Form.Get("code_challenge_method") != "S256" ||
+			r.Form.Get("code_challenge") == "" || r.Form.Get("redirect_uri") == "" {
+			http.Error(w, "response_type=code and PKCE S256 are required", http.StatusBadRequest)
+			return
+		}
+		loginPage.Execute(w, map[string]interface{}{"Params": r.URL.Query(), "Users": p.users})
+		return
+	}
+
+	var user *mockUser
+	for i := range p.users {
+		if p.users[i].Subject == r.PostForm.Get("subject") {
+			user = &p.users[i]
+		}
+	}
+	if user == nil {
+		http.Error(w, "unknown user", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(r.PostForm.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString(24)
+	p.mu.Lock()
+	p.codes[code] = authCode{
+		subject:       user.Subject,
+		kdDokter:      user.KdDokter,
+		clientID:      r.PostForm.Get("client_id"),
+		redirectURI:   r.PostForm.Get("redirect_uri"),
+		nonce:         r.PostForm.Get("nonce"),
+		codeChallenge: r.PostForm.Get("code_challenge"),
+		expiresAt:     time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	query.Set("state", r.PostForm.Get("state"))
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token: tukar code + code_verifier dengan id_token (RS256)
+func (p *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	stored, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(stored.expiresAt) || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	clientID := r.PostForm.Get("client_id")
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ = url.QueryUnescape(user)
+	}
+	if clientID != stored.clientID || r.PostForm.Get("redirect_uri") != stored.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != stored.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":       p.issuer,
+		"sub":       stored.subject,
+		"aud":       stored.clientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(5 * time.Minute).Unix(),
+		"nonce":     stored.nonce,
+		"amr":       []string{"pwd"}, // tanpa MFA: backend tetap meminta TOTP jika aktif
+		"kd_dokter": stored.kdDokter,
+	})
+	token.Header["kid"] = keyID
+
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(24),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func parseUsers(value string) []mockUser {
+	var users []mockUser
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			continue
+		}
+		users = append(users, mockUser{Subject: parts[0], KdDokter: parts[1]})
+	}
+	return users
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("Gagal membuat random: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
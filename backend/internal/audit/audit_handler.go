@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditService AuditService
+}
+
+func NewAuditHandler(auditService AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// ✅ Middleware: catat setiap akses data pasien SETELAH handler selesai (outcome dari status response).
+// Dipasang per-route setelah JWTMiddleware, misal: ranapRoutes.GET("/pasien", auditHandler.Track(audit.ActionViewList), ...)
+func (h *AuditHandler) Track(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		// Akses lewat token impersonasi admin selalu di-flag
+		detail := c.GetString("audit_detail")
+		flagged := c.GetBool("audit_flagged")
+		if actor := c.GetString("actor"); actor != "" {
+			flagged = true
+			detail = strings.TrimSpace("impersonated_by: " + actor + " " + detail)
+		}
+
+		h.auditService.RecordAccess(&PatientAccessLog{
+			IDUser:     c.GetString("id_user"),
+			KodeDokter: c.GetString("kd_dokter"),
+			NoRawat:    c.Param("no_rawat"),
+			Action:     action,
+			HTTPStatus: c.Writer.Status(),
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.GetHeader("User-Agent"),
+			DeviceID:   c.GetHeader("X-Device-ID"),
+			Detail:     detail,  // Opsional, diisi handler
+			Flagged:    flagged, // Diisi handler untuk akses darurat / impersonasi
+		})
+	}
+}
+
+// ✅ Query jejak akses untuk privacy officer (filter user, pasien, tanggal)
+func (h *AuditHandler) GetAccessLogs(c *gin.Context) {
+	var filter AccessLogFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid query parameters",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response, err := h.auditService.GetAccessLogs(filter)
+	if errors.Is(err, ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get access logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"time"
+)
+
+// Action yang dicatat
+const (
+	ActionViewList        = "view_list"
+	ActionViewDetail      = "view_detail"
+	ActionEmergencyAccess = "emergency_access"
+	ActionViewCppt        = "view_cppt"
+	ActionWriteCppt       = "write_cppt"
+	ActionEditCppt        = "edit_cppt"
+	ActionVerifyCppt      = "verify_cppt"
+	ActionSyncCppt        = "sync_cppt"
+	ActionViewLab         = "view_lab"
+	ActionViewRadiologi   = "view_radiologi"
+	ActionViewObat        = "view_obat"
+	ActionWriteResep      = "write_resep"
+)
+
+// Outcome akses, diturunkan dari HTTP status response
+const (
+	OutcomeSuccess  = "success"
+	OutcomeDenied   = "denied"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+// PatientAccessLog: jejak audit akses data pasien (append-only, tidak pernah di-update/hapus).
+// Selain AuditRepository yang hanya bisa insert, trigger database menolak UPDATE/DELETE (EnsureAppendOnly).
+type PatientAccessLog struct {
+	ID         uint64    `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	IDUser     string    `json:"id_user" gorm:"column:id_user;size:64;index"`
+	KodeDokter string    `json:"kd_dokter" gorm:"column:kd_dokter;size:20"`
+	NoRawat    string    `json:"no_rawat" gorm:"column:no_rawat;size:17;index"` // Kosong untuk akses list
+	Action     string    `json:"action" gorm:"column:action;size:30"`
+	Outcome    string    `json:"outcome" gorm:"column:outcome;size:20"`
+	HTTPStatus int       `json:"http_status" gorm:"column:http_status"`
+	IPAddress  string    `json:"ip_address" gorm:"column:ip_address;size:45"`
+	UserAgent  string    `json:"user_agent" gorm:"column:user_agent;size:255"`
+	DeviceID   string    `json:"device_id" gorm:"column:device_id;size:64"` // Header X-Device-ID dari PWA
+	Detail     string    `json:"detail,omitempty" gorm:"column:detail;size:500"`
+	Flagged    bool      `json:"flagged" gorm:"column:flagged;index"` // ✅ Akses darurat (break-the-glass)
+	AccessedAt time.Time `json:"accessed_at" gorm:"column:accessed_at;index"`
+}
+
+func (PatientAccessLog) TableName() string {
+	return "pwa_patient_access_log"
+}
+
+// Filter query untuk privacy officer
+type AccessLogFilter struct {
+	IDUser     string `form:"id_user"`
+	KodeDokter string `form:"kd_dokter"`
+	NoRawat    string `form:"no_rawat"`
+	NoRKMMedis string `form:"no_rkm_medis"`
+	DateFrom   string `form:"date_from"` // YYYY-MM-DD
+	DateTo     string `form:"date_to"`   // YYYY-MM-DD (inklusif)
+	Action     string `form:"action"`
+	Flagged    *bool  `form:"flagged"`
+	Page       int    `form:"page"`
+	Limit      int    `form:"limit"`
+}
+
+type AccessLogListResponse struct {
+	Status  string             `json:"status"`
+	Message string             `json:"message"`
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	Limit   int                `json:"limit"`
+	Data    []PatientAccessLog `json:"data"`
+}
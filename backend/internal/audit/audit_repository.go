@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Trigger yang menolak UPDATE/DELETE pada log akses, sehingga append-only juga berlaku
+// untuk query manual atau aplikasi lain yang memakai user database yang sama
+var appendOnlyTriggers = []struct{ name, timing string }{
+	{"pwa_patient_access_log_no_update", "BEFORE UPDATE"},
+	{"pwa_patient_access_log_no_delete", "BEFORE DELETE"},
+}
+
+// AuditRepository sengaja hanya punya operasi insert dan baca (append-only)
+type AuditRepository interface {
+	CreateAccessLog(entry *PatientAccessLog) error
+	FindAccessLogs(filter AccessLogFilter, dateFrom, dateTo *time.Time) ([]PatientAccessLog, int64, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// EnsureAppendOnly memasang trigger append-only pada pwa_patient_access_log (dipanggil setelah migrate).
+// Butuh hak TRIGGER; user dengan hak DROP TRIGGER tetap bisa melepasnya, jadi user database
+// aplikasi sebaiknya tidak diberi hak tersebut di production.
+func EnsureAppendOnly(db *gorm.DB) error {
+	table := PatientAccessLog{}.TableName()
+	for _, trigger := range appendOnlyTriggers {
+		var count int64
+		err := db.Raw(`
+			SELECT COUNT(*) FROM information_schema.TRIGGERS
+			WHERE TRIGGER_SCHEMA = DATABASE() AND TRIGGER_NAME = ?
+		`, trigger.name).Scan(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		stmt := fmt.Sprintf("CREATE TRIGGER %s %s ON %s FOR EACH ROW "+
+			"SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = '%s is append-only'",
+			trigger.name, trigger.timing, table, table)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("create trigger %s: %w", trigger.name, err)
+		}
+	}
+	return nil
+}
+
+func (r *auditRepository) CreateAccessLog(entry *PatientAccessLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *auditRepository) FindAccessLogs(filter AccessLogFilter, dateFrom, dateTo *time.Time) ([]PatientAccessLog, int64, error) {
+	query := r.db.Model(&PatientAccessLog{})
+
+	if filter.IDUser != "" {
+		query = query.Where("id_user = ?", filter.IDUser)
+	}
+	if filter.KodeDokter != "" {
+		query = query.Where("kd_dokter = ?", filter.KodeDokter)
+	}
+	if filter.NoRawat != "" {
+		query = query.Where("no_rawat = ?", filter.NoRawat)
+	}
+	if filter.NoRKMMedis != "" {
+		// Semua rawat inap milik pasien ini (reg_periksa Khanza)
+		query = query.Where("no_rawat IN (SELECT no_rawat FROM reg_periksa WHERE no_rkm_medis = ?)", filter.NoRKMMedis)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Flagged != nil {
+		query = query.Where("flagged = ?", *filter.Flagged)
+	}
+	if dateFrom != nil {
+		query = query.Where("accessed_at >= ?", *dateFrom)
+	}
+	if dateTo != nil {
+		query = query.Where("accessed_at < ?", *dateTo)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []PatientAccessLog
+	err := query.Order("accessed_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
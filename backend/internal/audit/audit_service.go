@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pwa-rsbw/internal/textutil"
+	"time"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+type AuditService interface {
+	RecordAccess(entry *PatientAccessLog)
+	GetAccessLogs(filter AccessLogFilter) (*AccessLogListResponse, error)
+}
+
+type auditService struct {
+	auditRepo AuditRepository
+}
+
+func NewAuditService(auditRepo AuditRepository) AuditService {
+	return &auditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// RecordAccess menyimpan jejak akses. Gagal simpan hanya dicatat di log agar
+// dokter tetap bisa membuka data pasien saat darurat.
+func (s *auditService) RecordAccess(entry *PatientAccessLog) {
+	if entry.AccessedAt.IsZero() {
+		entry.AccessedAt = time.Now()
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = outcomeFromStatus(entry.HTTPStatus)
+	}
+	entry.UserAgent = textutil.Truncate(entry.UserAgent, 255)
+	entry.Detail = textutil.Truncate(entry.Detail, 500)
+
+	if err := s.auditRepo.CreateAccessLog(entry); err != nil {
+		fmt.Printf("❌ Failed to write audit log (%s %s by %s): %v\n", entry.Action, entry.NoRawat, entry.IDUser, err)
+	}
+}
+
+func (s *auditService) GetAccessLogs(filter AccessLogFilter) (*AccessLogListResponse, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	dateFrom, err := parseDate(filter.DateFrom)
+	if err != nil {
+		return nil, fmt.Errorf("%w: date_from must be YYYY-MM-DD", ErrInvalidFilter)
+	}
+	dateTo, err := parseDate(filter.DateTo)
+	if err != nil {
+		return nil, fmt.Errorf("%w: date_to must be YYYY-MM-DD", ErrInvalidFilter)
+	}
+	if dateTo != nil {
+		// date_to inklusif: ambil sampai akhir hari tersebut
+		next := dateTo.AddDate(0, 0, 1)
+		dateTo = &next
+	}
+
+	logs, total, err := s.auditRepo.FindAccessLogs(filter, dateFrom, dateTo)
+	if err != nil {
+		fmt.Printf("❌ Error getting audit logs: %v\n", err)
+		return nil, err
+	}
+
+	return &AccessLogListResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Found %d access log entries", total),
+		Total:   total,
+		Page:    filter.Page,
+		Limit:   filter.Limit,
+		Data:    logs,
+	}, nil
+}
+
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func outcomeFromStatus(status int) string {
+	switch {
+	case status >= 200 && status < 300:
+		return OutcomeSuccess
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return OutcomeDenied
+	case status == http.StatusNotFound:
+		return OutcomeNotFound
+	default:
+		return OutcomeError
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/textutil"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix         = "rsbw_"
+	apiKeyDefaultTTLDays = 90
+	apiKeyMaxTTLDays     = 365
+	apiKeyTouchInterval  = time.Minute
+)
+
+var (
+	ErrAPIKeyInvalid  = errors.New("invalid api key")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// AllowsRoute mencocokkan method + pola route gin (c.FullPath()) dengan allowed_routes
+func (k *APIKey) AllowsRoute(method, fullPath string) bool {
+	for _, rule := range k.AllowedRoutes {
+		ruleMethod, rulePath := "", strings.TrimSpace(rule)
+		if parts := strings.Fields(rulePath); len(parts) == 2 {
+			ruleMethod, rulePath = strings.ToUpper(parts[0]), parts[1]
+		}
+		if ruleMethod != "" && ruleMethod != method {
+			continue
+		}
+
+		if prefix, ok := strings.CutSuffix(rulePath, "/*"); ok {
+			if fullPath == prefix || strings.HasPrefix(fullPath, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if rulePath == fullPath {
+			return true
+		}
+	}
+	return false
+}
+
+// ✅ CreateAPIKey: admin membuat key untuk integrasi, key lengkap hanya dikembalikan sekali
+func (s *authService) CreateAPIKey(req APIKeyCreateRequest, createdBy string) (*APIKeyCreateResponse, error) {
+	for _, perm := range req.Permissions {
+		if !apiKeyPermissions[perm] {
+			return nil, fmt.Errorf("permission %s cannot be granted to an api key", perm)
+		}
+	}
+	if len(req.AllowedRoutes) == 0 {
+		return nil, errors.New("allowed_routes is required")
+	}
+	for _, rule := range req.AllowedRoutes {
+		if !strings.Contains(rule, "/") {
+			return nil, fmt.Errorf("invalid route rule: %s", rule)
+		}
+	}
+
+	days := req.ExpiresInDays
+	if days <= 0 {
+		days = apiKeyDefaultTTLDays
+	}
+	if days > apiKeyMaxTTLDays {
+		return nil, fmt.Errorf("expires_in_days must be at most %d", apiKeyMaxTTLDays)
+	}
+
+	id, err := generateRandomToken(12)
+	if err != nil {
+		return nil, errors.New("failed to create api key")
+	}
+	secret, err := generateRandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to create api key")
+	}
+	rawKey := apiKeyPrefix + id + "." + secret
+
+	now := time.Now()
+	key := &APIKey{
+		ID:            id,
+		Name:          textutil.Truncate(strings.TrimSpace(req.Name), 100),
+		KeyHash:       hashToken(rawKey),
+		Permissions:   req.Permissions,
+		AllowedRoutes: req.AllowedRoutes,
+		KodeBangsal:   strings.TrimSpace(req.KodeBangsal),
+		CreatedBy:     createdBy,
+		CreatedAt:     now,
+		ExpiresAt:     now.AddDate(0, 0, days),
+	}
+	if err := s.authRepo.CreateAPIKey(key); err != nil {
+		fmt.Printf("❌ Failed to create api key: %v\n", err)
+		return nil, errors.New("failed to create api key")
+	}
+
+	fmt.Printf("🔑 API key %s (%s) created by %s\n", key.ID, key.Name, createdBy)
+	return &APIKeyCreateResponse{Key: rawKey, APIKey: key}, nil
+}
+
+func (s *authService) ListAPIKeys() ([]APIKey, error) {
+	return s.authRepo.GetAPIKeys()
+}
+
+// ✅ RevokeAPIKey berlaku langsung di request berikutnya
+func (s *authService) RevokeAPIKey(id string) error {
+	err := s.authRepo.RevokeAPIKey(id, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to revoke api key: %v\n", err)
+		return errors.New("failed to revoke api key")
+	}
+
+	fmt.Printf("🔑 API key revoked: %s\n", id)
+	return nil
+}
+
+// ValidateAPIKey: format "rsbw_<id>.<secret>", dicek hash, masa berlaku, dan status revoke
+func (s *authService) ValidateAPIKey(rawKey string) (*APIKey, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(rawKey, apiKeyPrefix), ".")
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) || !ok || id == "" {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	key, err := s.authRepo.GetAPIKey(id)
+	if err != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashToken(rawKey))) != 1 {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || now.After(key.ExpiresAt) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.authRepo.TouchAPIKey(key.ID, now); err != nil {
+			fmt.Printf("⚠️ Failed to update api key last used: %v\n", err)
+		}
+	}
+	return key, nil
+}
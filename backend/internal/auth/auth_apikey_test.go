@@ -0,0 +1,52 @@
+package auth
+
+import "testing"
+
+func TestAPIKeyAllowsRoute(t *testing.T) {
+	key := &APIKey{AllowedRoutes: []string{
+		"GET /api/v1/ranap/pasien",
+		"/api/v1/obat/*",
+		"get /api/v1/ranap/riwayat",
+	}}
+
+	tests := []struct {
+		name     string
+		method   string
+		fullPath string
+		want     bool
+	}{
+		{"exact method and path", "GET", "/api/v1/ranap/pasien", true},
+		{"method mismatch", "POST", "/api/v1/ranap/pasien", false},
+		{"lowercase rule method", "GET", "/api/v1/ranap/riwayat", true},
+		{"exact rule does not cover children", "GET", "/api/v1/ranap/pasien/:no_rawat", false},
+		{"wildcard any method", "POST", "/api/v1/obat/search", true},
+		{"wildcard covers the prefix itself", "GET", "/api/v1/obat", true},
+		{"wildcard is segment based", "GET", "/api/v1/obatx/search", false},
+		{"unlisted route", "GET", "/api/v1/audit/logs", false},
+		{"unmatched route has empty full path", "GET", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := key.AllowsRoute(tt.method, tt.fullPath); got != tt.want {
+				t.Errorf("AllowsRoute(%s, %q) = %v, want %v", tt.method, tt.fullPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAPIKeyRejectsUnusablePermissions(t *testing.T) {
+	service := &authService{authRepo: newFakeAuthRepo()}
+
+	// Route pasien per dokter menolak caller tanpa kd_dokter, jadi permission-nya tidak boleh diberikan
+	for _, perm := range []string{PermCpptRead, PermLabRead, PermRadiologiRead, PermCpptWrite, PermAdmin} {
+		_, err := service.CreateAPIKey(APIKeyCreateRequest{
+			Name:          "nurse station",
+			Permissions:   []string{PermRanapRead, perm},
+			AllowedRoutes: []string{"GET /api/v1/ranap/pasien"},
+		}, "admin")
+		if err == nil {
+			t.Errorf("CreateAPIKey granted %s", perm)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeAuthRepo: AuthRepository di memori untuk unit test. Method yang tidak
+// di-override akan panic (nil interface), sehingga test yang memakainya ketahuan.
+type fakeAuthRepo struct {
+	AuthRepository
+
+	attempts      map[string]*LoginAttempt
+	khanzaUsers   map[string]*User // id_user -> user Khanza (password di User.Password)
+	localAccounts map[string]*LocalAccount
+	mfa           map[string]*MFATOTP
+	signingKeys   map[string]*SigningKey
+	devicePINs    map[string]*DevicePIN
+	sessions      map[string]*Session
+	refreshTokens map[string]*RefreshToken
+	oidcStates    map[string]*OIDCState
+	oidcIdentity  map[string]*OIDCIdentity // issuer|subject
+
+	// Error database yang disuntikkan tes
+	sessionErr error
+	reserveErr error
+	userErr    error
+}
+
+func newFakeAuthRepo() *fakeAuthRepo {
+	return &fakeAuthRepo{
+		attempts:      make(map[string]*LoginAttempt),
+		khanzaUsers:   make(map[string]*User),
+		localAccounts: make(map[string]*LocalAccount),
+		mfa:           make(map[string]*MFATOTP),
+		signingKeys:   make(map[string]*SigningKey),
+		devicePINs:    make(map[string]*DevicePIN),
+		sessions:      make(map[string]*Session),
+		refreshTokens: make(map[string]*RefreshToken),
+		oidcStates:    make(map[string]*OIDCState),
+		oidcIdentity:  make(map[string]*OIDCIdentity),
+	}
+}
+
+func (r *fakeAuthRepo) GetUserByCredentials(idUser, password string) (*User, error) {
+	user, ok := r.khanzaUsers[idUser]
+	if !ok || user.Password != password {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) GetUserByID(idUser string) (*User, error) {
+	if r.userErr != nil {
+		return nil, r.userErr
+	}
+	user, ok := r.khanzaUsers[idUser]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) GetLocalAccount(idUser string) (*LocalAccount, error) {
+	account, ok := r.localAccounts[idUser]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return account, nil
+}
+
+func (r *fakeAuthRepo) GetNamaDokter(kdDokter string) (string, error) {
+	return "dr. " + kdDokter, nil
+}
+
+func (r *fakeAuthRepo) GetLoginAttempt(keyType, keyValue string) (*LoginAttempt, error) {
+	attempt, ok := r.attempts[keyType+"|"+keyValue]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *attempt
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) RecordFailedLogin(keyType, keyValue string, now, resetBefore time.Time, maxAttempts int, lockedUntil time.Time) (*LoginAttempt, error) {
+	key := keyType + "|" + keyValue
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &LoginAttempt{KeyType: keyType, KeyValue: keyValue}
+		r.attempts[key] = attempt
+	}
+
+	if attempt.LastFailedAt.Before(resetBefore) {
+		attempt.FailedCount = 1
+	} else {
+		attempt.FailedCount++
+	}
+	attempt.LastFailedAt = now
+	if attempt.FailedCount >= maxAttempts {
+		attempt.LockedUntil = &lockedUntil
+	}
+	return r.GetLoginAttempt(keyType, keyValue)
+}
+
+func (r *fakeAuthRepo) DeleteLoginAttempt(keyType, keyValue string) error {
+	delete(r.attempts, keyType+"|"+keyValue)
+	return nil
+}
+
+func (r *fakeAuthRepo) GetMFA(idUser string) (*MFATOTP, error) {
+	mfa, ok := r.mfa[idUser]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) DeleteMFA(idUser string) error {
+	delete(r.mfa, idUser)
+	return nil
+}
+
+func (r *fakeAuthRepo) MarkTOTPStepUsed(idUser string, step int64) (bool, error) {
+	mfa, ok := r.mfa[idUser]
+	if !ok || mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (r *fakeAuthRepo) GetSigningKeys(retiredAfter time.Time) ([]SigningKey, error) {
+	var keys []SigningKey
+	for _, key := range r.signingKeys {
+		if key.RetiredAt == nil || key.RetiredAt.After(retiredAfter) {
+			keys = append(keys, *key)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeAuthRepo) CreateSigningKeyIfDue(key *SigningKey, dueBefore time.Time) (bool, error) {
+	for _, existing := range r.signingKeys {
+		if existing.Algorithm == key.Algorithm && existing.RetiredAt == nil && existing.CreatedAt.After(dueBefore) {
+			return false, nil
+		}
+	}
+	copied := *key
+	r.signingKeys[key.KID] = &copied
+	return true, nil
+}
+
+func (r *fakeAuthRepo) RetireSigningKeysCreatedBefore(createdBefore, retiredAt time.Time) error {
+	for _, key := range r.signingKeys {
+		if key.RetiredAt == nil && key.CreatedAt.Before(createdBefore) {
+			at := retiredAt
+			key.RetiredAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) DeleteSigningKeysRetiredBefore(before time.Time) error {
+	for kid, key := range r.signingKeys {
+		if key.RetiredAt != nil && key.RetiredAt.Before(before) {
+			delete(r.signingKeys, kid)
+		}
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) GetJabatanPetugas(nip string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (r *fakeAuthRepo) GetKhanzaPermissionFlags(idUser string) (map[string]bool, error) {
+	return map[string]bool{}, nil
+}
+
+func (r *fakeAuthRepo) CreateSession(session *Session) error {
+	copied := *session
+	r.sessions[session.ID] = &copied
+	return nil
+}
+
+func (r *fakeAuthRepo) CreateRefreshToken(token *RefreshToken) error {
+	copied := *token
+	r.refreshTokens[token.TokenHash] = &copied
+	return nil
+}
+
+func (r *fakeAuthRepo) GetSession(id string) (*Session, error) {
+	if r.sessionErr != nil {
+		return nil, r.sessionErr
+	}
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) GetDevicePIN(id string) (*DevicePIN, error) {
+	pin, ok := r.devicePINs[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *pin
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) ReserveDevicePINAttempt(id string, maxTry int) (int, error) {
+	if r.reserveErr != nil {
+		return 0, r.reserveErr
+	}
+	pin, ok := r.devicePINs[id]
+	if !ok || pin.FailedCount >= maxTry {
+		return 0, gorm.ErrRecordNotFound
+	}
+	pin.FailedCount++
+	return pin.FailedCount, nil
+}
+
+func (r *fakeAuthRepo) DeleteDevicePIN(id string) error {
+	delete(r.devicePINs, id)
+	return nil
+}
+
+func (r *fakeAuthRepo) CreateOIDCState(state *OIDCState) error {
+	copied := *state
+	r.oidcStates[state.State] = &copied
+	return nil
+}
+
+func (r *fakeAuthRepo) ConsumeOIDCState(state string, now time.Time) (*OIDCState, error) {
+	stored, ok := r.oidcStates[state]
+	if !ok || now.After(stored.ExpiresAt) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.oidcStates, state)
+	return stored, nil
+}
+
+func (r *fakeAuthRepo) GetOIDCIdentity(issuer, subject string) (*OIDCIdentity, error) {
+	identity, ok := r.oidcIdentity[issuer+"|"+subject]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *identity
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) SaveOIDCIdentity(identity *OIDCIdentity) error {
+	copied := *identity
+	r.oidcIdentity[identity.Issuer+"|"+identity.Subject] = &copied
+	return nil
+}
+
+func (r *fakeAuthRepo) MarkOIDCLogin(issuer, subject string, at time.Time) error {
+	if identity, ok := r.oidcIdentity[issuer+"|"+subject]; ok {
+		identity.LastLogin = &at
+	}
+	return nil
+}
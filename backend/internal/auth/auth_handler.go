@@ -1,7 +1,11 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -28,7 +32,85 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	loginResp, err := h.authService.Login(req.IDUser, req.Password)
+	loginResp, err := h.authService.Login(req.IDUser, req.Password, clientInfo(c))
+
+	if respondIfThrottled(c, err) {
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// ✅ TOTP aktif: PWA harus meminta kode lalu memanggil /auth/mfa/verify
+	if loginResp.MFARequired {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "mfa_required",
+			"message": "MFA code required",
+			"data":    loginResp,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Login successful",
+		"data":    loginResp,
+	})
+}
+
+func clientInfo(c *gin.Context) ClientInfo {
+	return ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		DeviceID:  c.GetHeader("X-Device-ID"),
+	}
+}
+
+// ✅ Akun/IP dikunci: 429 + code agar PWA bisa menampilkan pesan khusus
+func respondIfThrottled(c *gin.Context, err error) bool {
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	code := "too_many_attempts"
+	message := fmt.Sprintf("Too many login attempts. Please wait %d seconds", retryAfter)
+	if throttled.Locked {
+		code = "account_locked"
+		message = fmt.Sprintf("Too many failed login attempts. Login is locked for %d minutes", int(math.Ceil(throttled.RetryAfter.Minutes())))
+	}
+
+	c.Header("Retry-After", fmt.Sprint(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"status":      "error",
+		"code":        code,
+		"message":     message,
+		"retry_after": retryAfter,
+	})
+	return true
+}
+
+// ✅ Tukar challenge MFA + kode TOTP (atau recovery code) dengan JWT penuh
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "mfa_token and code or recovery_code are required",
+		})
+		return
+	}
+
+	loginResp, err := h.authService.VerifyMFA(req.MFAToken, req.Code, req.RecoveryCode, clientInfo(c))
+	if respondIfThrottled(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  "error",
@@ -44,6 +126,175 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	})
 }
 
+// ✅ Mulai enrollment TOTP: kembalikan secret + URI otpauth untuk QR code
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	resp, err := h.authService.EnrollMFA(c.GetString("id_user"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Scan the QR code, then confirm with a code",
+		"data":    resp,
+	})
+}
+
+// ✅ Konfirmasi enrollment TOTP, recovery code hanya ditampilkan sekali di sini
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.authService.ConfirmMFA(c.GetString("id_user"), req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "MFA enabled. Store the recovery codes safely",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// ✅ Nonaktifkan TOTP (wajib kode yang valid)
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if err := h.authService.DisableMFA(c.GetString("id_user"), req.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "MFA disabled",
+	})
+}
+
+// ✅ Tukar refresh token dengan access token baru
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	loginResp, err := h.authService.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Token refreshed",
+		"data":    loginResp,
+	})
+}
+
+// ✅ Logout: cabut access token ini dan refresh token di sesi yang sama
+func (h *AuthHandler) Logout(c *gin.Context) {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*JWTClaims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "Invalid token",
+		})
+		return
+	}
+
+	if err := h.authService.Logout(claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Logout successful",
+	})
+}
+
+// ✅ AuthMiddleware: menerima header X-API-Key (service account) atau JWT Bearer biasa
+func (h *AuthHandler) AuthMiddleware() gin.HandlerFunc {
+	jwtMiddleware := h.JWTMiddleware()
+	return func(c *gin.Context) {
+		rawKey := c.GetHeader("X-API-Key")
+		if rawKey == "" {
+			jwtMiddleware(c)
+			return
+		}
+
+		key, err := h.authService.ValidateAPIKey(rawKey)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "Invalid API key",
+			})
+			c.Abort()
+			return
+		}
+
+		if !key.AllowsRoute(c.Request.Method, c.FullPath()) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": "API key is not allowed to access this route",
+			})
+			c.Abort()
+			return
+		}
+
+		// Bukan user: kd_dokter kosong, id_user diberi prefix agar jelas di audit log
+		c.Set("api_key_id", key.ID)
+		c.Set("id_user", "apikey:"+key.ID)
+		c.Set("kd_dokter", "")
+		c.Set("nm_dokter", key.Name)
+		c.Set("kd_bangsal", key.KodeBangsal)
+		c.Set("roles", []string{RoleService})
+		c.Set("permissions", key.Permissions)
+		c.Next()
+	}
+}
+
 // ✅ Middleware untuk validate JWT token dengan kode dokter DAN nama dokter
 func (h *AuthHandler) JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -78,10 +329,22 @@ func (h *AuthHandler) JWTMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// ✅ Token impersonasi: tolak semua request tulis, tandai actor untuk audit log
+		if claims.Actor != nil {
+			if blockImpersonationWrites(c) {
+				return
+			}
+			c.Set("actor", claims.Actor.Subject)
+		}
+
 		// ✅ Set user info, kode dokter, DAN nama dokter di context
+		c.Set("claims", claims)
+		c.Set("session_id", claims.SessionID)
 		c.Set("id_user", claims.IDUser)
 		c.Set("kd_dokter", claims.KodeDokter) // ✅ Kode dokter
 		c.Set("nm_dokter", claims.NamaDokter) // ✅ TAMBAH: Nama dokter
+		c.Set("roles", claims.Roles)
+		c.Set("permissions", claims.Permissions)
 		c.Next()
 	}
 }
@@ -94,9 +357,518 @@ func (h *AuthHandler) Validate(c *gin.Context) {
 		"status":  "success",
 		"message": "Token is valid",
 		"data": gin.H{
-			"id_user":   c.GetString("id_user"),
-			"kd_dokter": c.GetString("kd_dokter"),
-			"nm_dokter": c.GetString("nm_dokter"),
+			"id_user":     c.GetString("id_user"),
+			"kd_dokter":   c.GetString("kd_dokter"),
+			"nm_dokter":   c.GetString("nm_dokter"),
+			"roles":       c.GetStringSlice("roles"),
+			"permissions": c.GetStringSlice("permissions"),
 		},
 	})
 }
+
+// ✅ Admin: daftar akun/IP yang sedang dikunci
+func (h *AuthHandler) GetLoginLockouts(c *gin.Context) {
+	lockouts, err := h.authService.GetLoginLockouts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get login lockouts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"total":  len(lockouts),
+		"data":   lockouts,
+	})
+}
+
+// ✅ Admin: buka kunci akun dan/atau IP
+func (h *AuthHandler) UnlockLogin(c *gin.Context) {
+	var req UnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if err := h.authService.UnlockLogin(req.IDUser, req.IP); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	fmt.Printf("🔓 Unlock performed by admin: %s\n", c.GetString("id_user"))
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Login unlocked",
+	})
+}
+
+// ✅ JWKS: public key (RS256/EdDSA) untuk verifikasi token oleh layanan lain di rumah sakit
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(JWKSCacheMaxAge.Seconds())))
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
+// ✅ Daftar sesi aktif dokter di semua perangkat
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.GetString("id_user"), c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"total":  len(sessions),
+		"data":   sessions,
+	})
+}
+
+// ✅ Cabut satu sesi (perangkat)
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	err := h.authService.RevokeSession(c.GetString("id_user"), c.Param("session_id"))
+	if errors.Is(err, ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Session revoked",
+	})
+}
+
+// ✅ Cabut semua sesi. ?keep_current=true mempertahankan sesi perangkat ini
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	keepSessionID := ""
+	if c.Query("keep_current") == "true" {
+		keepSessionID = c.GetString("session_id")
+	}
+
+	count, err := h.authService.RevokeAllSessions(c.GetString("id_user"), keepSessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to revoke sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("%d sessions revoked", count),
+	})
+}
+
+// ✅ Daftarkan PIN quick-unlock untuk perangkat ini (butuh header X-Device-ID)
+func (h *AuthHandler) RegisterPIN(c *gin.Context) {
+	var req PINRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	claimsValue, _ := c.Get("claims")
+	claims, ok := claimsValue.(*JWTClaims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "Invalid token",
+		})
+		return
+	}
+
+	resp, err := h.authService.RegisterPIN(claims, req.PIN, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "PIN registered. Store the device secret on this device only",
+		"data":    resp,
+	})
+}
+
+// ✅ Tukar PIN + device secret dengan access token baru
+func (h *AuthHandler) UnlockWithPIN(c *gin.Context) {
+	var req PINUnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	loginResp, err := h.authService.UnlockWithPIN(req, clientInfo(c))
+	if errors.Is(err, ErrDeviceDeregistered) {
+		// Client harus menghapus device secret dan kembali ke login penuh
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"code":    "device_deregistered",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrPINUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Unlock successful",
+		"data":    loginResp,
+	})
+}
+
+// ✅ Hapus PIN quick-unlock untuk perangkat ini
+func (h *AuthHandler) RemovePIN(c *gin.Context) {
+	if err := h.authService.RemovePIN(c.GetString("id_user"), clientInfo(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "PIN removed",
+	})
+}
+
+// ✅ Mulai login SSO: frontend diarahkan ke authorization_url
+func (h *AuthHandler) StartOIDCLogin(c *gin.Context) {
+	resp, err := h.authService.StartOIDCLogin()
+	if errors.Is(err, ErrOIDCDisabled) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	setOIDCStateCookie(c, resp.State, int(oidcStateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   resp,
+	})
+}
+
+// setOIDCStateCookie: cookie HttpOnly berisi state, dibaca lagi di callback (maxAge < 0 = hapus).
+// Lewat HTTPS dipakai SameSite=None agar tetap terkirim bila frontend beda domain dengan API.
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	sameSite := http.SameSiteLaxMode
+	if secure {
+		sameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+	})
+}
+
+// ✅ Callback SSO: frontend meneruskan code & state dari redirect IdP
+// (request harus membawa cookie oidc_state, mis. axios withCredentials: true)
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	boundState, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1) // state sekali pakai
+
+	loginResp, err := h.authService.CompleteOIDCLogin(req.Code, req.State, boundState, clientInfo(c))
+	if errors.Is(err, ErrOIDCDisabled) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrOIDCNotLinked) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"code":    "sso_not_linked",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Login successful",
+		"data":    loginResp,
+	})
+}
+
+// ✅ Admin: daftar akun SSO yang sudah dihubungkan ke dokter
+func (h *AuthHandler) GetOIDCIdentities(c *gin.Context) {
+	identities, err := h.authService.GetOIDCIdentities()
+	if errors.Is(err, ErrOIDCDisabled) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get sso accounts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"total":  len(identities),
+		"data":   identities,
+	})
+}
+
+// ✅ Admin: hubungkan subject IdP ke kd_dokter
+func (h *AuthHandler) LinkOIDCIdentity(c *gin.Context) {
+	var req OIDCLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if err := h.authService.LinkOIDCIdentity(req.Subject, req.KodeDokter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "SSO account linked",
+	})
+}
+
+// ✅ Admin: putuskan akun SSO
+func (h *AuthHandler) UnlinkOIDCIdentity(c *gin.Context) {
+	err := h.authService.UnlinkOIDCIdentity(c.Param("subject"))
+	if errors.Is(err, ErrOIDCLinkNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "SSO account unlinked",
+	})
+}
+
+// ✅ Admin: buat API key service account
+func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
+	var req APIKeyCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.authService.CreateAPIKey(req, c.GetString("id_user"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "API key created. Store the key now, it will not be shown again",
+		"data":    resp,
+	})
+}
+
+// ✅ Admin: daftar API key (tanpa secret)
+func (h *AuthHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.authService.ListAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get api keys",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"total":  len(keys),
+		"data":   keys,
+	})
+}
+
+// ✅ Admin: cabut API key
+func (h *AuthHandler) RevokeAPIKey(c *gin.Context) {
+	err := h.authService.RevokeAPIKey(c.Param("id"))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "API key revoked",
+	})
+}
+
+// ✅ Admin: terbitkan token impersonasi read-only untuk satu dokter
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	var req ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	claimsValue, _ := c.Get("claims")
+	claims, ok := claimsValue.(*JWTClaims)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": "Impersonation requires an admin user token",
+		})
+		return
+	}
+
+	resp, err := h.authService.Impersonate(claims, req.KodeDokter, req.Alasan, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Read-only impersonation token issued",
+		"data":    resp,
+	})
+}
+
+// ✅ Admin: riwayat impersonasi
+func (h *AuthHandler) GetImpersonationLogs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	logs, err := h.authService.GetImpersonationLogs(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get impersonation logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"total":  len(logs),
+		"data":   logs,
+	})
+}
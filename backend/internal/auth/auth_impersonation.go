@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pwa-rsbw/internal/textutil"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	impersonationTTL     = 15 * time.Minute
+	minAlasanImpersonasi = 10 // karakter
+)
+
+var ErrAlasanImpersonasi = fmt.Errorf("alasan must be at least %d characters", minAlasanImpersonasi)
+
+// ✅ Impersonate: admin IT melihat aplikasi sebagai dokter (misal "list pasien saya salah").
+// Token: read-only, tanpa refresh token/sesi, berlaku singkat, dan membawa claim "act".
+func (s *authService) Impersonate(admin *JWTClaims, kdDokter, alasan string, client ClientInfo) (*LoginResponse, error) {
+	alasan = strings.TrimSpace(alasan)
+	if len([]rune(alasan)) < minAlasanImpersonasi {
+		return nil, ErrAlasanImpersonasi
+	}
+	if admin.Actor != nil {
+		return nil, errors.New("cannot impersonate from an impersonation token")
+	}
+
+	user, err := s.lookupDokterUser(kdDokter)
+	if err != nil {
+		return nil, errors.New("dokter not found")
+	}
+	s.resolveAccess(user.IDUser, user)
+
+	// Hanya permission baca milik dokter tersebut
+	var perms []string
+	for _, perm := range user.Permissions {
+		if readOnlyPermissions[perm] {
+			perms = append(perms, perm)
+		}
+	}
+
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	now := time.Now()
+	ttl := impersonationTTL
+	if s.accessTTL < ttl {
+		ttl = s.accessTTL
+	}
+	expirationTime := now.Add(ttl)
+
+	claims := &JWTClaims{
+		IDUser:      user.IDUser,
+		KodeDokter:  user.KodeDokter,
+		NamaDokter:  user.NamaDokter,
+		Roles:       user.Roles,
+		Permissions: perms,
+		TokenType:   tokenTypeAccess,
+		Actor:       &Actor{Subject: admin.IDUser},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	// Jejak dicatat dulu; tanpa log, token tidak diterbitkan
+	if err := s.authRepo.CreateImpersonationLog(&ImpersonationLog{
+		AdminID:    admin.IDUser,
+		KodeDokter: user.KodeDokter,
+		Alasan:     textutil.Truncate(alasan, 500),
+		TokenID:    jti,
+		IPAddress:  client.IP,
+		UserAgent:  textutil.Truncate(client.UserAgent, 255),
+		CreatedAt:  now,
+		ExpiresAt:  expirationTime,
+	}); err != nil {
+		fmt.Printf("❌ Failed to write impersonation log: %v\n", err)
+		return nil, errors.New("failed to generate token")
+	}
+
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		fmt.Printf("❌ Failed to generate token: %v\n", err)
+		return nil, errors.New("failed to generate token")
+	}
+
+	fmt.Printf("🕵️ Admin %s impersonating dokter %s until %s. Alasan: %s\n",
+		admin.IDUser, user.KodeDokter, expirationTime.Format("15:04"), alasan)
+	return &LoginResponse{
+		Token:       tokenString,
+		IDUser:      user.IDUser,
+		KodeDokter:  user.KodeDokter,
+		NamaDokter:  user.NamaDokter,
+		Roles:       user.Roles,
+		Permissions: perms,
+		ExpiresAt:   expirationTime.Unix(),
+	}, nil
+}
+
+func (s *authService) GetImpersonationLogs(limit int) ([]ImpersonationLog, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.authRepo.GetImpersonationLogs(limit)
+}
+
+// blockImpersonationWrites: token impersonasi hanya boleh GET/HEAD/OPTIONS
+func blockImpersonationWrites(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"status":  "error",
+		"code":    "impersonation_read_only",
+		"message": "Impersonation tokens are read-only",
+	})
+	c.Abort()
+	return true
+}
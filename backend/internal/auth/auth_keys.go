@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"pwa-rsbw/internal/config"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+	algEdDSA = "EdDSA"
+
+	rsaKeyBits = 2048
+
+	// JWKSCacheMaxAge: Cache-Control max-age endpoint JWKS
+	JWKSCacheMaxAge = 5 * time.Minute
+	// KeyReloadInterval: setiap instance memuat ulang key dari database sesering ini
+	KeyReloadInterval = time.Minute
+	// keyPublishLead: key baru dipublikasikan di JWKS selama ini sebelum mulai dipakai menandatangani,
+	// cukup untuk cache JWKS pihak lain habis dan instance lain memuat key tersebut
+	keyPublishLead = 2*JWKSCacheMaxAge + 2*KeyReloadInterval
+)
+
+type loadedKey struct {
+	kid         string
+	method      jwt.SigningMethod
+	private     crypto.Signer
+	public      crypto.PublicKey
+	createdAt   time.Time
+	activatesAt time.Time
+	retired     bool
+}
+
+// KeyManager menandatangani dan memverifikasi JWT.
+// HS256 memakai JWT_SECRET (tanpa kid, tanpa rotasi). RS256/EdDSA memakai key di tabel
+// pwa_jwt_signing_key, dirotasi berkala, dan public key-nya dipublikasikan lewat JWKS.
+// Key baru dibuat oleh satu instance saja (lock di database) dan baru dipakai setelah keyPublishLead.
+type KeyManager struct {
+	repo      AuthRepository
+	algorithm string
+	secret    []byte
+	rotation  time.Duration
+	grace     time.Duration
+
+	mu   sync.RWMutex
+	keys map[string]*loadedKey
+}
+
+func NewKeyManager(repo AuthRepository, cfg *config.Config) (*KeyManager, error) {
+	m := &KeyManager{
+		repo:      repo,
+		algorithm: cfg.JWTAlgorithm,
+		secret:    []byte(cfg.JWTSecret),
+		rotation:  cfg.JWTKeyRotation,
+		grace:     cfg.JWTKeyGracePeriod,
+		keys:      make(map[string]*loadedKey),
+	}
+
+	switch m.algorithm {
+	case algHS256:
+		return m, nil
+	case algRS256, algEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM: %s", m.algorithm)
+	}
+
+	// Instance lain mungkin sedang membuat key pertama (memegang lock), tunggu sebentar
+	for attempt := 1; ; attempt++ {
+		err := m.reload()
+		if err == nil {
+			err = m.rotateIfDue()
+		}
+		if key := m.activeKey(time.Now()); key != nil {
+			fmt.Printf("✅ JWT signing with %s (kid: %s)\n", m.algorithm, key.kid)
+			return m, nil
+		}
+		if attempt == 5 {
+			if err == nil {
+				err = errors.New("no active signing key")
+			}
+			return nil, err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// StartRotation mengecek jadwal rotasi secara berkala dan memuat ulang key dari database
+// (agar key yang dibuat instance lain ikut dikenali). Interval harus jauh di bawah keyPublishLead.
+func (m *KeyManager) StartRotation(interval time.Duration) {
+	if m.algorithm == algHS256 {
+		return
+	}
+
+	log.Printf("✅ JWT key rotation worker dimulai (cek setiap %v, rotasi setiap %v).", interval, m.rotation)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := m.reload(); err != nil {
+			log.Printf("ERROR (KeyRotation): gagal memuat key: %v", err)
+			continue
+		}
+		if err := m.rotateIfDue(); err != nil {
+			log.Printf("ERROR (KeyRotation): gagal rotasi key: %v", err)
+		}
+	}
+}
+
+// Sign menandatangani claims dengan key aktif (header kid diisi untuk RS256/EdDSA)
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	if m.algorithm == algHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	}
+
+	key := m.activeKey(time.Now())
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Keyfunc dipakai jwt.ParseWithClaims untuk memilih key berdasarkan kid
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if m.algorithm == algHS256 {
+		return m.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	m.mu.RLock()
+	key := m.keys[kid]
+	m.mu.RUnlock()
+	if key == nil {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("signing method mismatch")
+	}
+	return key.public, nil
+}
+
+func (m *KeyManager) ValidMethods() []string {
+	return []string{m.algorithm}
+}
+
+// activeKey: key terbaru yang belum di-retire, sudah melewati masa publikasi, dan algoritmanya sesuai
+func (m *KeyManager) activeKey(now time.Time) *loadedKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var active *loadedKey
+	for _, key := range m.keys {
+		if key.retired || key.method.Alg() != m.algorithm || now.Before(key.activatesAt) {
+			continue
+		}
+		if active == nil || key.createdAt.After(active.createdAt) {
+			active = key
+		}
+	}
+	return active
+}
+
+// JWKS mengembalikan semua public key yang masih berlaku (menunggu aktif + aktif + grace period)
+func (m *KeyManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// reload memuat key aktif, key yang menunggu aktif, dan key dalam grace period dari database
+func (m *KeyManager) reload() error {
+	stored, err := m.repo.GetSigningKeys(time.Now().Add(-m.grace))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*loadedKey, len(stored))
+	for _, sk := range stored {
+		key, err := parseSigningKey(sk)
+		if err != nil {
+			log.Printf("⚠️ Signing key %s tidak valid: %v", sk.KID, err)
+			continue
+		}
+		keys[key.kid] = key
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	return nil
+}
+
+// rotateIfDue membuat key baru jika belum ada key yang dibuat dalam interval rotasi, lalu
+// me-retire key yang lebih lama dari key aktif. Aman dijalankan di banyak instance sekaligus.
+func (m *KeyManager) rotateIfDue() error {
+	now := time.Now()
+	active := m.activeKey(now)
+
+	if active != nil {
+		if err := m.repo.RetireSigningKeysCreatedBefore(active.createdAt, now); err != nil {
+			return err
+		}
+		if err := m.repo.DeleteSigningKeysRetiredBefore(now.Add(-m.grace)); err != nil {
+			log.Printf("⚠️ Gagal menghapus signing key lama: %v", err)
+		}
+	}
+
+	if m.hasRecentKey(now.Add(-m.rotation)) {
+		return nil
+	}
+
+	sk, err := generateSigningKey(m.algorithm)
+	if err != nil {
+		return err
+	}
+
+	// Key pertama langsung aktif; key pengganti menunggu dipublikasikan di JWKS dulu
+	if active != nil {
+		activatesAt := now.Add(keyPublishLead)
+		sk.ActivatesAt = &activatesAt
+	}
+
+	created, err := m.repo.CreateSigningKeyIfDue(sk, now.Add(-m.rotation))
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Printf("🔑 JWT signing key baru dibuat, kid: %s (aktif mulai %s)", sk.KID, sk.activation().Format(time.RFC3339))
+	}
+	return m.reload()
+}
+
+// hasRecentKey: sudah ada key (aktif atau menunggu aktif) yang dibuat setelah since
+func (m *KeyManager) hasRecentKey(since time.Time) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.keys {
+		if !key.retired && key.method.Alg() == m.algorithm && key.createdAt.After(since) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSigningKey(algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case algRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case algEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := generateRandomToken(12)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		KID:           kid,
+		Algorithm:     algorithm,
+		PrivateKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+func parseSigningKey(sk SigningKey) (*loadedKey, error) {
+	block, _ := pem.Decode([]byte(sk.PrivateKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &loadedKey{
+		kid:         sk.KID,
+		createdAt:   sk.CreatedAt,
+		activatesAt: sk.activation(),
+		retired:     sk.RetiredAt != nil,
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+		key.private = private
+		key.public = &private.PublicKey
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.private = private
+		key.public = private.Public()
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+	return key, nil
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"pwa-rsbw/internal/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKeyManager(t *testing.T, repo *fakeAuthRepo, algorithm string) *KeyManager {
+	t.Helper()
+
+	m, err := NewKeyManager(repo, &config.Config{
+		JWTAlgorithm:      algorithm,
+		JWTKeyRotation:    30 * 24 * time.Hour,
+		JWTKeyGracePeriod: 2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewKeyManager: %v", err)
+	}
+	return m
+}
+
+func signTestToken(t *testing.T, m *KeyManager) (string, string) {
+	t.Helper()
+
+	signed, err := m.Sign(&JWTClaims{
+		IDUser:           "dr01",
+		TokenType:        tokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(signed, &JWTClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return signed, kid
+}
+
+func verifyTestToken(m *KeyManager, signed string) error {
+	_, err := jwt.ParseWithClaims(signed, &JWTClaims{}, m.Keyfunc, jwt.WithValidMethods(m.ValidMethods()))
+	return err
+}
+
+func TestKeyManagerSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{algRS256, algEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			m := newTestKeyManager(t, newFakeAuthRepo(), algorithm)
+
+			signed, kid := signTestToken(t, m)
+			if kid == "" {
+				t.Fatal("signed token has no kid header")
+			}
+			if err := verifyTestToken(m, signed); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+
+			jwks := m.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != kid || jwks.Keys[0].Alg != algorithm {
+				t.Fatalf("JWKS = %+v, want the single %s key %s", jwks.Keys, algorithm, kid)
+			}
+		})
+	}
+}
+
+func TestKeyManagerRotationPublishesBeforeSigning(t *testing.T) {
+	repo := newFakeAuthRepo()
+	m := newTestKeyManager(t, repo, algEdDSA)
+	oldToken, oldKID := signTestToken(t, m)
+
+	// Key lama melewati interval rotasi
+	repo.signingKeys[oldKID].CreatedAt = time.Now().Add(-31 * 24 * time.Hour)
+	if err := m.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.rotateIfDue(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.signingKeys) != 2 {
+		t.Fatalf("signing keys = %d, want old + pending", len(repo.signingKeys))
+	}
+	if got := len(m.JWKS().Keys); got != 2 {
+		t.Fatalf("JWKS keys = %d, want the pending key published next to the active key", got)
+	}
+	if _, kid := signTestToken(t, m); kid != oldKID {
+		t.Fatalf("signed with %s before the new key was published long enough, want %s", kid, oldKID)
+	}
+
+	// Rotasi berikutnya (instance lain) tidak membuat key ketiga
+	other := newTestKeyManager(t, repo, algEdDSA)
+	if err := other.rotateIfDue(); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.signingKeys) != 2 {
+		t.Fatalf("signing keys = %d after a second instance checked rotation, want 2", len(repo.signingKeys))
+	}
+
+	// Masa publikasi selesai: key baru dipakai, key lama di-retire tapi masih bisa memverifikasi
+	var newKID string
+	for kid, key := range repo.signingKeys {
+		if kid != oldKID {
+			newKID = kid
+			past := time.Now().Add(-time.Second)
+			key.ActivatesAt = &past
+		}
+	}
+	if err := m.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.rotateIfDue(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, kid := signTestToken(t, m); kid != newKID {
+		t.Fatalf("signed with %s after activation, want %s", kid, newKID)
+	}
+	if repo.signingKeys[oldKID].RetiredAt == nil {
+		t.Error("old key was not retired after the new key became active")
+	}
+	if err := verifyTestToken(m, oldToken); err != nil {
+		t.Errorf("token signed by the retired key no longer verifies during grace period: %v", err)
+	}
+}
+
+func TestKeyManagerRejectsUnknownKid(t *testing.T) {
+	signer := newTestKeyManager(t, newFakeAuthRepo(), algEdDSA)
+	verifier := newTestKeyManager(t, newFakeAuthRepo(), algEdDSA)
+
+	signed, _ := signTestToken(t, signer)
+	if err := verifyTestToken(verifier, signed); err == nil {
+		t.Fatal("token signed by an unknown key was accepted")
+	}
+}
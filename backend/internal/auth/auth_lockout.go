@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	attemptKeyUser = "user"
+	attemptKeyIP   = "ip"
+
+	maxProgressiveDelay = 30 * time.Second
+)
+
+// LoginThrottledError dikembalikan saat login ditolak sebelum password dicek.
+// Pesan sengaja sama untuk akun yang ada maupun tidak ada.
+type LoginThrottledError struct {
+	Locked     bool // true = dikunci sementara, false = harus menunggu jeda progresif
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "account temporarily locked"
+	}
+	return "too many login attempts"
+}
+
+// loginGuard menghitung gagal login per akun dan per IP, menerapkan jeda progresif dan lockout
+type loginGuard struct {
+	repo          AuthRepository
+	maxAttempts   int
+	ipMaxAttempts int
+	lockout       time.Duration
+}
+
+// progressiveDelay: gagal ke-1 dan ke-2 tanpa jeda, selanjutnya 1s, 2s, 4s, ... maksimal 30s
+func progressiveDelay(failedCount int) time.Duration {
+	if failedCount < 3 {
+		return 0
+	}
+	delay := time.Second << uint(failedCount-3)
+	if delay <= 0 || delay > maxProgressiveDelay {
+		return maxProgressiveDelay
+	}
+	return delay
+}
+
+// check menolak login jika akun/IP sedang dikunci atau masih dalam jeda progresif
+func (g *loginGuard) check(idUser, clientIP string) error {
+	now := time.Now()
+
+	keys := []struct{ keyType, keyValue string }{
+		{attemptKeyUser, idUser},
+		{attemptKeyIP, clientIP},
+	}
+	for _, key := range keys {
+		attempt, err := g.repo.GetLoginAttempt(key.keyType, key.keyValue)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			// Jangan blokir login hanya karena tabel attempt bermasalah
+			fmt.Printf("⚠️ Failed to read login attempts (%s): %v\n", key.keyType, err)
+			continue
+		}
+
+		if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+			return &LoginThrottledError{Locked: true, RetryAfter: attempt.LockedUntil.Sub(now)}
+		}
+
+		// Counter yang sudah lewat jendela lockout dianggap reset
+		if now.Sub(attempt.LastFailedAt) > g.lockout {
+			continue
+		}
+
+		retryAt := attempt.LastFailedAt.Add(progressiveDelay(attempt.FailedCount))
+		if now.Before(retryAt) {
+			return &LoginThrottledError{Locked: false, RetryAfter: retryAt.Sub(now)}
+		}
+	}
+
+	return nil
+}
+
+// recordFailure menambah counter akun dan IP, lalu mengembalikan lockout jika batas tercapai
+func (g *loginGuard) recordFailure(idUser, clientIP string) error {
+	now := time.Now()
+	resetBefore := now.Add(-g.lockout)
+	lockedUntil := now.Add(g.lockout)
+
+	limits := []struct {
+		keyType     string
+		keyValue    string
+		maxAttempts int
+	}{
+		{attemptKeyUser, idUser, g.maxAttempts},
+		{attemptKeyIP, clientIP, g.ipMaxAttempts},
+	}
+
+	var throttled error
+	for _, limit := range limits {
+		attempt, err := g.repo.RecordFailedLogin(limit.keyType, limit.keyValue, now, resetBefore, limit.maxAttempts, lockedUntil)
+		if err != nil {
+			fmt.Printf("⚠️ Failed to record login attempt (%s): %v\n", limit.keyType, err)
+			continue
+		}
+
+		if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+			fmt.Printf("🔒 Login locked (%s: %s) until %s\n", limit.keyType, attempt.KeyValue, attempt.LockedUntil.Format("15:04:05"))
+			throttled = &LoginThrottledError{Locked: true, RetryAfter: attempt.LockedUntil.Sub(now)}
+		}
+	}
+
+	return throttled
+}
+
+// recordSuccess hanya me-reset counter akun. Counter IP dibiarkan menurun sendiri,
+// supaya satu akun valid tidak bisa dipakai untuk me-reset counter IP penyerang.
+func (g *loginGuard) recordSuccess(idUser string) {
+	if err := g.repo.DeleteLoginAttempt(attemptKeyUser, idUser); err != nil {
+		fmt.Printf("⚠️ Failed to reset login attempts for %s: %v\n", idUser, err)
+	}
+}
+
+// unlock dipakai admin untuk membuka kunci akun dan/atau IP
+func (g *loginGuard) unlock(idUser, clientIP string) error {
+	if idUser != "" {
+		if err := g.repo.DeleteLoginAttempt(attemptKeyUser, idUser); err != nil {
+			return err
+		}
+	}
+	if clientIP != "" {
+		if err := g.repo.DeleteLoginAttempt(attemptKeyIP, clientIP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
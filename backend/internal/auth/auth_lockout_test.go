@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProgressiveDelay(t *testing.T) {
+	tests := []struct {
+		failedCount int
+		want        time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{7, 16 * time.Second},
+		{8, maxProgressiveDelay},
+		{64, maxProgressiveDelay},
+		{1000, maxProgressiveDelay},
+	}
+
+	for _, tt := range tests {
+		if got := progressiveDelay(tt.failedCount); got != tt.want {
+			t.Errorf("progressiveDelay(%d) = %v, want %v", tt.failedCount, got, tt.want)
+		}
+	}
+}
+
+func TestLoginGuardCheck(t *testing.T) {
+	now := time.Now()
+	future := now.Add(10 * time.Minute)
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		attempt    *LoginAttempt
+		wantLocked bool
+		wantErr    bool
+	}{
+		{"no attempts", nil, false, false},
+		{"locked account", &LoginAttempt{KeyType: attemptKeyUser, KeyValue: "dr01", FailedCount: 5, LastFailedAt: now, LockedUntil: &future}, true, true},
+		{"expired lock", &LoginAttempt{KeyType: attemptKeyUser, KeyValue: "dr01", FailedCount: 5, LastFailedAt: now.Add(-time.Hour), LockedUntil: &past}, false, false},
+		{"within progressive delay", &LoginAttempt{KeyType: attemptKeyUser, KeyValue: "dr01", FailedCount: 4, LastFailedAt: now}, false, true},
+		{"progressive delay elapsed", &LoginAttempt{KeyType: attemptKeyUser, KeyValue: "dr01", FailedCount: 3, LastFailedAt: now.Add(-2 * time.Second)}, false, false},
+		{"counter outside lockout window", &LoginAttempt{KeyType: attemptKeyUser, KeyValue: "dr01", FailedCount: 4, LastFailedAt: now.Add(-time.Hour)}, false, false},
+		{"locked ip", &LoginAttempt{KeyType: attemptKeyIP, KeyValue: "10.0.0.9", FailedCount: 20, LastFailedAt: now, LockedUntil: &future}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeAuthRepo()
+			if tt.attempt != nil {
+				repo.attempts[tt.attempt.KeyType+"|"+tt.attempt.KeyValue] = tt.attempt
+			}
+			guard := &loginGuard{repo: repo, maxAttempts: 5, ipMaxAttempts: 20, lockout: 15 * time.Minute}
+
+			err := guard.check("dr01", "10.0.0.9")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var throttled *LoginThrottledError
+			if err != nil && (!errors.As(err, &throttled) || throttled.Locked != tt.wantLocked) {
+				t.Errorf("check() error = %#v, want LoginThrottledError{Locked: %v}", err, tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestLoginGuardLocksAfterMaxAttempts(t *testing.T) {
+	repo := newFakeAuthRepo()
+	guard := &loginGuard{repo: repo, maxAttempts: 3, ipMaxAttempts: 20, lockout: 15 * time.Minute}
+
+	for i := 1; i < 3; i++ {
+		if err := guard.recordFailure("dr01", "10.0.0.9"); err != nil {
+			t.Fatalf("recordFailure #%d locked too early: %v", i, err)
+		}
+	}
+
+	var throttled *LoginThrottledError
+	if err := guard.recordFailure("dr01", "10.0.0.9"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("recordFailure #3 = %v, want account locked", err)
+	}
+
+	// Login berhasil hanya me-reset counter akun, counter IP tetap
+	guard.recordSuccess("dr01")
+	if _, ok := repo.attempts[attemptKeyUser+"|dr01"]; ok {
+		t.Error("recordSuccess did not reset the account counter")
+	}
+	if attempt, ok := repo.attempts[attemptKeyIP+"|10.0.0.9"]; !ok || attempt.FailedCount != 3 {
+		t.Errorf("ip counter = %+v, want 3 failures kept", attempt)
+	}
+}
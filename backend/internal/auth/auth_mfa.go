@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	totpPeriod        = 30 // detik
+	totpDigits        = 6
+	totpSkew          = 1 // toleransi ±1 step untuk jam HP yang tidak sinkron
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+
+	tokenTypeAccess = "access"
+	tokenTypeMFA    = "mfa"
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode menghitung kode TOTP (RFC 6238, HMAC-SHA1) untuk satu step waktu
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// validateTOTP mengembalikan step yang cocok, atau -1 jika kode salah
+func validateTOTP(secretBase32, code string, now time.Time) int64 {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return -1
+	}
+
+	secret, err := base32NoPadding.DecodeString(strings.ToUpper(secretBase32))
+	if err != nil {
+		return -1
+	}
+
+	current := now.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := current + int64(i)
+		if hmac.Equal([]byte(totpCode(secret, step)), []byte(code)) {
+			return step
+		}
+	}
+	return -1
+}
+
+// generateRecoveryCode menghasilkan kode format "xxxx-xxxx"
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32NoPadding.EncodeToString(b))
+	return code[:4] + "-" + code[4:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// ✅ EnrollMFA membuat secret baru (belum aktif sampai dikonfirmasi dengan ConfirmMFA)
+func (s *authService) EnrollMFA(idUser string) (*MFAEnrollResponse, error) {
+	existing, err := s.authRepo.GetMFA(idUser)
+	if err == nil && existing.Enabled {
+		return nil, errors.New("mfa already enabled")
+	}
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, errors.New("failed to generate secret")
+	}
+	secret := base32NoPadding.EncodeToString(raw)
+
+	if err := s.authRepo.SaveMFA(&MFATOTP{
+		IDUser:    idUser,
+		Secret:    secret,
+		Enabled:   false,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		fmt.Printf("❌ Failed to save MFA secret: %v\n", err)
+		return nil, errors.New("failed to enroll mfa")
+	}
+
+	label := url.PathEscape(s.mfaIssuer + ":" + idUser)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", s.mfaIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	fmt.Printf("✅ MFA enrollment started for user: %s\n", idUser)
+	return &MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: "otpauth://totp/" + label + "?" + query.Encode(),
+	}, nil
+}
+
+// ✅ ConfirmMFA mengaktifkan TOTP setelah kode pertama benar, lalu mengembalikan recovery code
+func (s *authService) ConfirmMFA(idUser, code string) ([]string, error) {
+	mfa, err := s.authRepo.GetMFA(idUser)
+	if err != nil {
+		return nil, errors.New("mfa enrollment not found")
+	}
+	if mfa.Enabled {
+		return nil, errors.New("mfa already enabled")
+	}
+
+	step := validateTOTP(mfa.Secret, code, time.Now())
+	if step < 0 {
+		return nil, errors.New("invalid mfa code")
+	}
+
+	now := time.Now()
+	mfa.Enabled = true
+	mfa.EnabledAt = &now
+	mfa.LastUsedStep = step
+	if err := s.authRepo.SaveMFA(mfa); err != nil {
+		fmt.Printf("❌ Failed to enable MFA: %v\n", err)
+		return nil, errors.New("failed to enable mfa")
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			return nil, errors.New("failed to generate recovery codes")
+		}
+		codes = append(codes, recoveryCode)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+	if err := s.authRepo.ReplaceRecoveryCodes(idUser, hashes); err != nil {
+		fmt.Printf("❌ Failed to save recovery codes: %v\n", err)
+		return nil, errors.New("failed to generate recovery codes")
+	}
+
+	fmt.Printf("✅ MFA enabled for user: %s\n", idUser)
+	return codes, nil
+}
+
+// ✅ DisableMFA mematikan TOTP, wajib dengan kode TOTP yang valid
+func (s *authService) DisableMFA(idUser, code string) error {
+	mfa, err := s.authRepo.GetMFA(idUser)
+	if err != nil || !mfa.Enabled {
+		return errors.New("mfa is not enabled")
+	}
+
+	step := validateTOTP(mfa.Secret, code, time.Now())
+	if step < 0 {
+		return errors.New("invalid mfa code")
+	}
+
+	// Sama seperti VerifyMFA: kode yang sudah dipakai tidak boleh diputar ulang
+	fresh, err := s.authRepo.MarkTOTPStepUsed(idUser, step)
+	if err != nil {
+		fmt.Printf("❌ Failed to verify MFA: %v\n", err)
+		return errors.New("failed to disable mfa")
+	}
+	if !fresh {
+		return errors.New("invalid mfa code")
+	}
+
+	if err := s.authRepo.DeleteMFA(idUser); err != nil {
+		fmt.Printf("❌ Failed to disable MFA: %v\n", err)
+		return errors.New("failed to disable mfa")
+	}
+
+	fmt.Printf("✅ MFA disabled for user: %s\n", idUser)
+	return nil
+}
+
+// ✅ VerifyMFA menukar challenge token + kode TOTP/recovery code dengan JWT penuh
+func (s *authService) VerifyMFA(mfaToken, code, recoveryCode string, client ClientInfo) (*LoginResponse, error) {
+	claims, err := s.parseToken(mfaToken)
+	if err != nil || claims.TokenType != tokenTypeMFA {
+		return nil, errors.New("invalid mfa token")
+	}
+	idUser := claims.IDUser
+
+	if err := s.guard.check(idUser, client.IP); err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.authRepo.GetMFA(idUser)
+	if err != nil || !mfa.Enabled {
+		return nil, errors.New("invalid mfa token")
+	}
+
+	verified := false
+	switch {
+	case code != "":
+		if step := validateTOTP(mfa.Secret, code, time.Now()); step >= 0 {
+			verified, err = s.authRepo.MarkTOTPStepUsed(idUser, step)
+		}
+	case recoveryCode != "":
+		verified, err = s.authRepo.UseRecoveryCode(idUser, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if verified {
+			fmt.Printf("⚠️ Recovery code used by user: %s\n", idUser)
+		}
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to verify MFA: %v\n", err)
+		return nil, errors.New("failed to verify mfa")
+	}
+
+	if !verified {
+		fmt.Printf("❌ Invalid MFA code for user: %s\n", idUser)
+		if lockErr := s.guard.recordFailure(idUser, client.IP); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, errors.New("invalid mfa code")
+	}
+	s.guard.recordSuccess(idUser)
+
+	// Challenge token hanya boleh dipakai sekali
+	if err := s.authRepo.RevokeAccessToken(claims.ID, idUser, claims.ExpiresAt.Time); err != nil {
+		fmt.Printf("⚠️ Failed to revoke mfa token: %v\n", err)
+	}
+
+	user, err := s.lookupUser(claims.Source, idUser)
+	if err != nil {
+		return nil, errors.New("invalid mfa token")
+	}
+
+	fmt.Printf("✅ MFA verified for user: %s\n", idUser)
+	return s.completeLogin(idUser, user, client)
+}
+
+// isMFAEnabled: error selain "tidak ada" dianggap gagal (fail closed)
+func (s *authService) isMFAEnabled(idUser string) (bool, error) {
+	mfa, err := s.authRepo.GetMFA(idUser)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mfa.Enabled, nil
+}
+
+// issueMFAChallenge membuat token pendek yang HANYA bisa dipakai di /auth/mfa/verify
+func (s *authService) issueMFAChallenge(idUser, source string) (*LoginResponse, error) {
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	expirationTime := time.Now().Add(mfaChallengeTTL)
+	claims := &JWTClaims{
+		IDUser:    idUser,
+		TokenType: tokenTypeMFA,
+		Source:    source,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	fmt.Printf("🔐 MFA required for user: %s\n", idUser)
+	return &LoginResponse{
+		MFARequired: true,
+		MFAToken:    tokenString,
+		IDUser:      idUser,
+		ExpiresAt:   expirationTime.Unix(),
+	}, nil
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Vektor uji RFC 6238 Appendix B (HMAC-SHA1, secret ASCII "12345678901234567890").
+// RFC memakai 8 digit; kode 6 digit adalah 6 digit terakhirnya.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+const rfc6238Secret = "12345678901234567890"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		if got := totpCode([]byte(rfc6238Secret), tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := base32NoPadding.EncodeToString([]byte(rfc6238Secret))
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	key := []byte(rfc6238Secret)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		want   int64
+	}{
+		{"current step", secret, "050471", current},
+		{"lowercase secret", strings.ToLower(secret), "050471", current},
+		{"surrounding spaces", secret, " 050471 ", current},
+		{"previous step within skew", secret, totpCode(key, current-1), current - 1},
+		{"next step within skew", secret, totpCode(key, current+1), current + 1},
+		{"outside skew", secret, totpCode(key, current-2), -1},
+		{"wrong code", secret, "000000", -1},
+		{"too short", secret, "05047", -1},
+		{"too long", secret, "0504711", -1},
+		{"invalid secret", "not base32!", "050471", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateTOTP(tt.secret, tt.code, now); got != tt.want {
+				t.Errorf("validateTOTP(%q) = %d, want %d", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisableMFARejectsReplayedCode(t *testing.T) {
+	secret := base32NoPadding.EncodeToString([]byte(rfc6238Secret))
+	step := time.Now().Unix() / totpPeriod
+	code := totpCode([]byte(rfc6238Secret), step)
+
+	repo := newFakeAuthRepo()
+	repo.mfa["dr01"] = &MFATOTP{IDUser: "dr01", Secret: secret, Enabled: true, LastUsedStep: step}
+	service := &authService{authRepo: repo}
+
+	// Kode yang sama baru saja dipakai untuk login
+	if err := service.DisableMFA("dr01", code); err == nil {
+		t.Fatal("DisableMFA accepted a code whose step was already used")
+	}
+	if _, ok := repo.mfa["dr01"]; !ok {
+		t.Fatal("MFA was disabled by a replayed code")
+	}
+
+	repo.mfa["dr01"].LastUsedStep = step - totpSkew - 1
+	if err := service.DisableMFA("dr01", code); err != nil {
+		t.Fatalf("DisableMFA with a fresh code: %v", err)
+	}
+	if _, ok := repo.mfa["dr01"]; ok {
+		t.Fatal("MFA still enabled after DisableMFA")
+	}
+}
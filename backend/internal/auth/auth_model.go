@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"time"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -10,6 +12,11 @@ type User struct {
 	Password   string `json:"-" gorm:"column:password"`          // Hidden dari JSON response
 	KodeDokter string `json:"kd_dokter" gorm:"column:kd_dokter"` // ✅ Kode dokter
 	NamaDokter string `json:"nm_dokter" gorm:"column:nm_dokter"` // ✅ TAMBAH: Nama dokter
+
+	// ✅ Diisi oleh service setelah login (bukan kolom tabel user)
+	Source      string   `json:"-" gorm:"-"` // Verifier yang memverifikasi akun (khanza, local, ...)
+	Roles       []string `json:"roles" gorm:"-"`
+	Permissions []string `json:"permissions" gorm:"-"`
 }
 
 // Request login
@@ -18,24 +25,363 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// Request unlock akun/IP oleh admin (isi salah satu)
+type UnlockRequest struct {
+	IDUser string `json:"id_user"`
+	IP     string `json:"ip"`
+}
+
+// Request refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Request verifikasi MFA (isi code ATAU recovery_code)
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// Request konfirmasi/nonaktifkan TOTP
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// PINRegisterRequest: PIN 4-6 digit untuk quick-unlock di perangkat ini
+type PINRegisterRequest struct {
+	PIN string `json:"pin" binding:"required"`
+}
+
+type PINUnlockRequest struct {
+	DeviceKeyID  string `json:"device_key_id" binding:"required"`
+	DeviceSecret string `json:"device_secret" binding:"required"`
+	PIN          string `json:"pin" binding:"required"`
+}
+
+// PINRegisterResponse: device_secret hanya dikirim sekali, disimpan di perangkat
+type PINRegisterResponse struct {
+	DeviceKeyID  string `json:"device_key_id"`
+	DeviceSecret string `json:"device_secret"`
+	ExpiresAt    int64  `json:"expires_at"` // mengikuti masa berlaku sesi
+}
+
+// OIDCCallbackRequest: code & state dari redirect IdP, diteruskan frontend
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+	ExpiresAt        int64  `json:"expires_at"`
+}
+
+// OIDCLinkRequest: admin menghubungkan subject IdP ke kd_dokter
+type OIDCLinkRequest struct {
+	Subject    string `json:"subject" binding:"required"`
+	KodeDokter string `json:"kd_dokter" binding:"required"`
+}
+
+// APIKeyCreateRequest: allowed_routes berisi "METHOD /api/v1/path" atau "/api/v1/path";
+// akhiran "/*" berarti semua route di bawahnya. Path mengikuti pola route (misal :no_rawat).
+type APIKeyCreateRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Permissions   []string `json:"permissions" binding:"required"`
+	AllowedRoutes []string `json:"allowed_routes" binding:"required"`
+	KodeBangsal   string   `json:"kd_bangsal"`      // Opsional: batasi ke satu bangsal
+	ExpiresInDays int      `json:"expires_in_days"` // Default 90, maksimal 365
+}
+
+// APIKeyCreateResponse: key hanya ditampilkan sekali saat dibuat
+type APIKeyCreateResponse struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
+
+// ImpersonateRequest: admin melihat aplikasi sebagai dokter tertentu (read-only)
+type ImpersonateRequest struct {
+	KodeDokter string `json:"kd_dokter" binding:"required"`
+	Alasan     string `json:"alasan" binding:"required"` // Misal nomor tiket laporan dokter
+}
+
+// Response enrollment TOTP (secret ditampilkan sekali untuk dipindai sebagai QR)
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth://totp/... untuk QR code
+}
+
 // Response login
 type LoginResponse struct {
-	Token      string `json:"token"`
-	IDUser     string `json:"id_user"`
-	KodeDokter string `json:"kd_dokter"` // ✅ Include kode dokter di response
-	NamaDokter string `json:"nm_dokter"` // ✅ TAMBAH: Include nama dokter di response
-	ExpiresAt  int64  `json:"expires_at"`
+	// ✅ Jika MFARequired = true, hanya MFAToken yang terisi dan harus ditukar di /auth/mfa/verify
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+
+	Token            string   `json:"token"`
+	RefreshToken     string   `json:"refresh_token"` // ✅ Refresh token (rotasi setiap dipakai)
+	IDUser           string   `json:"id_user"`
+	KodeDokter       string   `json:"kd_dokter"` // ✅ Include kode dokter di response
+	NamaDokter       string   `json:"nm_dokter"` // ✅ TAMBAH: Include nama dokter di response
+	Roles            []string `json:"roles"`
+	Permissions      []string `json:"permissions"`
+	ExpiresAt        int64    `json:"expires_at"`
+	RefreshExpiresAt int64    `json:"refresh_expires_at"`
 }
 
 // JWT Claims - tambah nama dokter
 type JWTClaims struct {
-	IDUser     string `json:"id_user"`
-	KodeDokter string `json:"kd_dokter"` // ✅ Kode dokter
-	NamaDokter string `json:"nm_dokter"` // ✅ TAMBAH: Nama dokter
+	IDUser      string   `json:"id_user"`
+	KodeDokter  string   `json:"kd_dokter"`     // ✅ Kode dokter
+	NamaDokter  string   `json:"nm_dokter"`     // ✅ TAMBAH: Nama dokter
+	SessionID   string   `json:"sid"`           // Family refresh token tempat access token ini diterbitkan
+	Roles       []string `json:"roles"`         // ✅ dokter, petugas, kepala_ruang, komite_medik, admin
+	Permissions []string `json:"perms"`         // ✅ Hasil resolve role + kolom hak akses user Khanza
+	TokenType   string   `json:"typ"`           // "access" atau "mfa" (challenge sebelum MFA diverifikasi)
+	Source      string   `json:"src,omitempty"` // Sumber akun, hanya di challenge MFA
+	Actor       *Actor   `json:"act,omitempty"` // Diisi jika token adalah impersonasi oleh admin
 	jwt.RegisteredClaims
 }
 
+// Actor: claim "act" (RFC 8693), id_user admin yang sebenarnya memakai token
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// RefreshToken disimpan di tabel milik aplikasi (hanya hash-nya, bukan token asli).
+// Satu FamilyID mewakili satu sesi login; setiap rotasi membuat baris baru di family yang sama.
+type RefreshToken struct {
+	ID         uint64     `gorm:"column:id;primaryKey;autoIncrement"`
+	TokenHash  string     `gorm:"column:token_hash;size:64;uniqueIndex"`
+	FamilyID   string     `gorm:"column:family_id;size:64;index"`
+	IDUser     string     `gorm:"column:id_user;size:64;index"`
+	Source     string     `gorm:"column:source;size:20"` // Verifier yang menerbitkan sesi, dipakai saat refresh
+	KodeDokter string     `gorm:"column:kd_dokter;size:20"`
+	NamaDokter string     `gorm:"column:nm_dokter;size:100"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	ReplacedBy *uint64    `gorm:"column:replaced_by"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+}
+
+// RevokedToken mencatat access token (jti) yang sudah di-logout sebelum kedaluwarsa
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;size:64;primaryKey"`
+	IDUser    string    `gorm:"column:id_user;size:64"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index"`
+	RevokedAt time.Time `gorm:"column:revoked_at"`
+}
+
 // Specify table name untuk GORM
 func (User) TableName() string {
 	return "user"
 }
+
+// LocalAccount: akun milik aplikasi dengan password bcrypt (misal untuk staf IT / akun non-Khanza)
+type LocalAccount struct {
+	IDUser       string    `gorm:"column:id_user;size:64;primaryKey"`
+	PasswordHash string    `gorm:"column:password_hash;size:100"`
+	KodeDokter   string    `gorm:"column:kd_dokter;size:20"` // Kosong jika bukan dokter
+	Active       bool      `gorm:"column:active;default:true"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+}
+
+func (LocalAccount) TableName() string {
+	return "pwa_local_account"
+}
+
+// MFATOTP menyimpan secret TOTP per user. Enabled baru true setelah kode pertama dikonfirmasi.
+type MFATOTP struct {
+	IDUser       string     `gorm:"column:id_user;size:64;primaryKey"`
+	Secret       string     `gorm:"column:secret;size:64"`
+	Enabled      bool       `gorm:"column:enabled"`
+	LastUsedStep int64      `gorm:"column:last_used_step"` // Mencegah kode yang sama dipakai dua kali
+	EnabledAt    *time.Time `gorm:"column:enabled_at"`
+	CreatedAt    time.Time  `gorm:"column:created_at"`
+}
+
+func (MFATOTP) TableName() string {
+	return "pwa_mfa_totp"
+}
+
+// MFARecoveryCode: kode cadangan sekali pakai (hanya hash yang disimpan)
+type MFARecoveryCode struct {
+	ID        uint64     `gorm:"column:id;primaryKey;autoIncrement"`
+	IDUser    string     `gorm:"column:id_user;size:64;index"`
+	CodeHash  string     `gorm:"column:code_hash;size:64"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "pwa_mfa_recovery_code"
+}
+
+// SigningKey: private key JWT (RS256/EdDSA) milik aplikasi, diidentifikasi dengan kid.
+// Key baru sudah tampil di JWKS sebelum ActivatesAt, tapi belum dipakai menandatangani.
+// Key yang sudah di-retire tetap dipakai verifikasi sampai grace period habis.
+type SigningKey struct {
+	KID           string     `gorm:"column:kid;size:64;primaryKey"`
+	Algorithm     string     `gorm:"column:algorithm;size:10"`
+	PrivateKeyPEM string     `gorm:"column:private_key_pem;type:text"`
+	CreatedAt     time.Time  `gorm:"column:created_at"`
+	ActivatesAt   *time.Time `gorm:"column:activates_at"` // NULL = aktif sejak dibuat
+	RetiredAt     *time.Time `gorm:"column:retired_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "pwa_jwt_signing_key"
+}
+
+func (k SigningKey) activation() time.Time {
+	if k.ActivatesAt != nil {
+		return *k.ActivatesAt
+	}
+	return k.CreatedAt
+}
+
+// JWK / JWKS untuk endpoint /.well-known/jwks.json (hanya public key)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // Ed25519
+	X   string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ClientInfo: informasi perangkat dari request (dipakai untuk sesi & lockout)
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	DeviceID  string // ID instalasi PWA dari header X-Device-ID
+}
+
+// Session: satu login di satu perangkat. ID sama dengan FamilyID refresh token dan claim "sid".
+type Session struct {
+	ID         string     `json:"id" gorm:"column:id;size:64;primaryKey"`
+	IDUser     string     `json:"id_user" gorm:"column:id_user;size:64;index"`
+	Source     string     `json:"-" gorm:"column:source;size:20"`
+	DeviceID   string     `json:"device_id" gorm:"column:device_id;size:64"`
+	UserAgent  string     `json:"user_agent" gorm:"column:user_agent;size:255"`
+	IPAddress  string     `json:"ip_address" gorm:"column:ip_address;size:45"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"column:last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+	Current    bool       `json:"current" gorm:"-"` // true untuk sesi milik token yang dipakai request ini
+}
+
+func (Session) TableName() string {
+	return "pwa_session"
+}
+
+// DevicePIN: registrasi quick-unlock satu perangkat. Terikat ke sesi login penuh (session_id),
+// sehingga ikut tidak berlaku saat sesi dicabut/logout. Secret dan PIN hanya disimpan dalam bentuk hash.
+type DevicePIN struct {
+	ID          string     `json:"device_key_id" gorm:"column:id;size:64;primaryKey"`
+	IDUser      string     `json:"id_user" gorm:"column:id_user;size:64;index"`
+	SessionID   string     `json:"session_id" gorm:"column:session_id;size:64;index"`
+	DeviceID    string     `json:"device_id" gorm:"column:device_id;size:64"`
+	SecretHash  string     `json:"-" gorm:"column:secret_hash;size:64"`
+	PINHash     string     `json:"-" gorm:"column:pin_hash;size:100"`
+	FailedCount int        `json:"failed_count" gorm:"column:failed_count"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" gorm:"column:last_used_at"`
+}
+
+func (DevicePIN) TableName() string {
+	return "pwa_device_pin"
+}
+
+// OIDCState: state login SSO yang sedang berjalan (sekali pakai). code_verifier PKCE
+// disimpan di server agar tidak perlu dititipkan ke browser.
+type OIDCState struct {
+	State        string    `gorm:"column:state;size:64;primaryKey"`
+	Nonce        string    `gorm:"column:nonce;size:64"`
+	CodeVerifier string    `gorm:"column:code_verifier;size:128"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;index"`
+}
+
+func (OIDCState) TableName() string {
+	return "pwa_oidc_state"
+}
+
+// OIDCIdentity memetakan subject IdP ke dokter Khanza (id_user dokter = kd_dokter)
+type OIDCIdentity struct {
+	Issuer     string     `json:"issuer" gorm:"column:issuer;size:191;primaryKey"`
+	Subject    string     `json:"subject" gorm:"column:subject;size:191;primaryKey"`
+	KodeDokter string     `json:"kd_dokter" gorm:"column:kd_dokter;size:20;index"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at"`
+	LastLogin  *time.Time `json:"last_login,omitempty" gorm:"column:last_login"`
+}
+
+func (OIDCIdentity) TableName() string {
+	return "pwa_oidc_identity"
+}
+
+// APIKey: kredensial service account untuk integrasi (papan informasi nurse station, job laporan).
+// Key lengkap "rsbw_<id>.<secret>" hanya disimpan dalam bentuk hash.
+type APIKey struct {
+	ID            string     `json:"id" gorm:"column:id;size:32;primaryKey"`
+	Name          string     `json:"name" gorm:"column:name;size:100"`
+	KeyHash       string     `json:"-" gorm:"column:key_hash;size:64"`
+	Permissions   []string   `json:"permissions" gorm:"column:permissions;type:text;serializer:json"`
+	AllowedRoutes []string   `json:"allowed_routes" gorm:"column:allowed_routes;type:text;serializer:json"`
+	KodeBangsal   string     `json:"kd_bangsal,omitempty" gorm:"column:kd_bangsal;size:5"`
+	CreatedBy     string     `json:"created_by" gorm:"column:created_by;size:64"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty" gorm:"column:last_used_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+}
+
+func (APIKey) TableName() string {
+	return "pwa_api_key"
+}
+
+// ImpersonationLog: jejak setiap token impersonasi yang diterbitkan (append-only)
+type ImpersonationLog struct {
+	ID         uint      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	AdminID    string    `json:"admin_id_user" gorm:"column:admin_id_user;size:64;index"`
+	KodeDokter string    `json:"kd_dokter" gorm:"column:kd_dokter;size:20;index"`
+	Alasan     string    `json:"alasan" gorm:"column:alasan;size:500"`
+	TokenID    string    `json:"token_id" gorm:"column:token_id;size:64"`
+	IPAddress  string    `json:"ip_address" gorm:"column:ip_address;size:45"`
+	UserAgent  string    `json:"user_agent" gorm:"column:user_agent;size:255"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;index"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"column:expires_at"`
+}
+
+func (ImpersonationLog) TableName() string {
+	return "pwa_impersonation_log"
+}
+
+// LoginAttempt mencatat gagal login berturut-turut per akun (key_type "user") atau per IP (key_type "ip").
+// id_user dicatat apa adanya walaupun tidak terdaftar, agar respon lockout tidak membocorkan akun yang ada.
+type LoginAttempt struct {
+	KeyType      string     `json:"key_type" gorm:"column:key_type;size:10;primaryKey"`
+	KeyValue     string     `json:"key_value" gorm:"column:key_value;size:100;primaryKey"`
+	FailedCount  int        `json:"failed_count" gorm:"column:failed_count"`
+	LastFailedAt time.Time  `json:"last_failed_at" gorm:"column:last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty" gorm:"column:locked_until"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "pwa_login_attempt"
+}
+
+func (RefreshToken) TableName() string {
+	return "pwa_refresh_token"
+}
+
+func (RevokedToken) TableName() string {
+	return "pwa_revoked_token"
+}
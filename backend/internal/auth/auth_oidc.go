@@ -0,0 +1,537 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"pwa-rsbw/internal/config"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateTTL      = 10 * time.Minute
+	oidcStateCookie   = "oidc_state" // HttpOnly, mengikat state ke browser yang memulai login
+	oidcJWKSMinReload = time.Minute  // batas refresh JWKS saat kid tidak dikenal
+)
+
+var (
+	ErrOIDCDisabled     = errors.New("sso is not configured")
+	ErrOIDCNotLinked    = errors.New("sso account is not linked to a doctor")
+	ErrOIDCInvalidState = errors.New("invalid or expired sso state")
+	ErrOIDCLinkNotFound = errors.New("sso account not found")
+)
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider: client OpenID Connect (authorization code + PKCE S256).
+// Discovery dan JWKS IdP di-cache di memori.
+type oidcProvider struct {
+	issuer        string
+	clientID      string
+	clientSecret  string
+	redirectURL   string
+	scopes        []string
+	kdDokterClaim string
+	mfaACR        []string
+	httpClient    *http.Client
+
+	mu            sync.RWMutex
+	discovery     *oidcDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func newOIDCProvider(cfg *config.Config) *oidcProvider {
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+
+	fmt.Printf("✅ OIDC SSO enabled (issuer: %s)\n", cfg.OIDCIssuer)
+	return &oidcProvider{
+		issuer:        strings.TrimSuffix(cfg.OIDCIssuer, "/"),
+		clientID:      cfg.OIDCClientID,
+		clientSecret:  cfg.OIDCClientSecret,
+		redirectURL:   cfg.OIDCRedirectURL,
+		scopes:        cfg.OIDCScopes,
+		kdDokterClaim: cfg.OIDCKdDokterClaim,
+		mfaACR:        cfg.OIDCMFAACR,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		keys:          make(map[string]crypto.PublicKey),
+	}
+}
+
+func (p *oidcProvider) getJSON(endpoint string, out interface{}) error {
+	resp, err := p.httpClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (p *oidcProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mu.RLock()
+	discovery := p.discovery
+	p.mu.RUnlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	var fetched oidcDiscovery
+	if err := p.getJSON(p.issuer+"/.well-known/openid-configuration", &fetched); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(fetched.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("issuer mismatch: %s", fetched.Issuer)
+	}
+
+	p.mu.Lock()
+	p.discovery = &fetched
+	p.mu.Unlock()
+	return &fetched, nil
+}
+
+func (p *oidcProvider) authorizationURL(state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.clientID)
+	query.Set("redirect_uri", p.redirectURL)
+	query.Set("scope", strings.Join(p.scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// exchangeCode menukar authorization code dengan id_token di token endpoint
+func (p *oidcProvider) exchangeCode(code, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("client_id", p.clientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return tokenResp.IDToken, nil
+}
+
+// verifyIDToken memeriksa signature (JWKS IdP), iss, aud, exp dan nonce
+func (p *oidcProvider) verifyIDToken(rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, p.keyfunc,
+		jwt.WithValidMethods([]string{algRS256, algEdDSA}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// oidcMFAMethods: nilai amr (RFC 8176) yang menunjukkan faktor kedua sudah diminta IdP
+var oidcMFAMethods = map[string]bool{"mfa": true, "otp": true, "hwk": true}
+
+// idTokenHasMFA: true jika amr berisi metode MFA atau acr termasuk OIDC_MFA_ACR
+func idTokenHasMFA(claims jwt.MapClaims, mfaACR []string) bool {
+	if amr, ok := claims["amr"].([]interface{}); ok {
+		for _, method := range amr {
+			if value, _ := method.(string); oidcMFAMethods[value] {
+				return true
+			}
+		}
+	}
+
+	acr, _ := claims["acr"].(string)
+	if acr == "" {
+		return false
+	}
+	for _, value := range mfaACR {
+		if acr == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *oidcProvider) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.RLock()
+	key := p.keys[kid]
+	fetchedAt := p.keysFetchedAt
+	p.mu.RUnlock()
+	if key != nil {
+		return key, nil
+	}
+
+	// kid baru (IdP rotasi key): muat ulang JWKS, dibatasi agar tidak dipakai untuk membanjiri IdP
+	if time.Since(fetchedAt) < oidcJWKSMinReload {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+	if err := p.reloadKeys(); err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	key = p.keys[kid]
+	p.mu.RUnlock()
+	if key == nil {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+	return key, nil
+}
+
+func (p *oidcProvider) reloadKeys() error {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return err
+	}
+
+	var set JWKSet
+	if err := p.getJSON(discovery.JWKSURI, &set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			fmt.Printf("⚠️ Ignoring IdP key %s: %v\n", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// parseJWK kebalikan dari KeyManager.JWKS: RSA (n, e) dan OKP Ed25519 (x)
+func parseJWK(jwk JWK) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+	}
+}
+
+// ✅ StartOIDCLogin membuat state + nonce + PKCE verifier dan mengembalikan URL login IdP
+func (s *authService) StartOIDCLogin() (*OIDCLoginResponse, error) {
+	if s.oidc == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	state, err := generateRandomToken(24)
+	if err != nil {
+		return nil, errors.New("failed to start sso")
+	}
+	nonce, err := generateRandomToken(24)
+	if err != nil {
+		return nil, errors.New("failed to start sso")
+	}
+	codeVerifier, err := generateRandomToken(48) // 64 karakter, sesuai RFC 7636 (43-128)
+	if err != nil {
+		return nil, errors.New("failed to start sso")
+	}
+
+	authURL, err := s.oidc.authorizationURL(state, nonce, codeVerifier)
+	if err != nil {
+		fmt.Printf("❌ OIDC discovery failed: %v\n", err)
+		return nil, errors.New("sso provider unavailable")
+	}
+
+	now := time.Now()
+	if err := s.authRepo.CreateOIDCState(&OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcStateTTL),
+	}); err != nil {
+		fmt.Printf("❌ Failed to save OIDC state: %v\n", err)
+		return nil, errors.New("failed to start sso")
+	}
+
+	return &OIDCLoginResponse{
+		AuthorizationURL: authURL,
+		State:            state,
+		ExpiresAt:        now.Add(oidcStateTTL).Unix(),
+	}, nil
+}
+
+// ✅ CompleteOIDCLogin: tukar code, verifikasi id_token, petakan subject ke kd_dokter,
+// lalu terbitkan JWT biasa. boundState berasal dari cookie oidc_state dan harus sama dengan
+// state di redirect (mencegah login CSRF). TOTP tetap diminta kecuali IdP sudah melakukan MFA.
+func (s *authService) CompleteOIDCLogin(code, state, boundState string, client ClientInfo) (*LoginResponse, error) {
+	if s.oidc == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	if boundState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(boundState)) != 1 {
+		fmt.Printf("❌ OIDC state does not match the browser cookie\n")
+		return nil, ErrOIDCInvalidState
+	}
+
+	stored, err := s.authRepo.ConsumeOIDCState(state, time.Now())
+	if err != nil {
+		return nil, ErrOIDCInvalidState
+	}
+
+	rawIDToken, err := s.oidc.exchangeCode(code, stored.CodeVerifier)
+	if err != nil {
+		fmt.Printf("❌ OIDC code exchange failed: %v\n", err)
+		return nil, errors.New("sso login failed")
+	}
+
+	claims, err := s.oidc.verifyIDToken(rawIDToken, stored.Nonce)
+	if err != nil {
+		fmt.Printf("❌ Invalid OIDC id_token: %v\n", err)
+		return nil, errors.New("sso login failed")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("sso login failed")
+	}
+
+	kdDokter, err := s.resolveOIDCSubject(subject, claims)
+	if err != nil {
+		fmt.Printf("❌ OIDC subject %s not linked: %v\n", subject, err)
+		return nil, ErrOIDCNotLinked
+	}
+
+	user, err := s.lookupDokterUser(kdDokter)
+	if err != nil {
+		fmt.Printf("❌ OIDC subject %s maps to unknown dokter %s\n", subject, kdDokter)
+		return nil, ErrOIDCNotLinked
+	}
+
+	user.Source = sourceOIDC
+
+	if err := s.authRepo.MarkOIDCLogin(s.oidc.issuer, subject, time.Now()); err != nil {
+		fmt.Printf("⚠️ Failed to update OIDC last login: %v\n", err)
+	}
+
+	// ✅ Sama seperti login password: TOTP aktif wajib diverifikasi, kecuali IdP sudah MFA
+	if !idTokenHasMFA(claims, s.oidc.mfaACR) {
+		mfaEnabled, err := s.isMFAEnabled(user.IDUser)
+		if err != nil {
+			fmt.Printf("❌ Failed to check MFA status: %v\n", err)
+			return nil, errors.New("sso login failed")
+		}
+		if mfaEnabled {
+			fmt.Printf("🔐 SSO login for dokter %s without IdP MFA, TOTP required\n", kdDokter)
+			return s.issueMFAChallenge(user.IDUser, sourceOIDC)
+		}
+	}
+
+	fmt.Printf("✅ SSO login for dokter %s (subject: %s)\n", kdDokter, subject)
+	return s.completeLogin(user.IDUser, user, client)
+}
+
+// resolveOIDCSubject: pakai mapping di pwa_oidc_identity; jika belum ada dan
+// OIDC_KD_DOKTER_CLAIM diatur, link otomatis dari claim tersebut
+func (s *authService) resolveOIDCSubject(subject string, claims jwt.MapClaims) (string, error) {
+	identity, err := s.authRepo.GetOIDCIdentity(s.oidc.issuer, subject)
+	if err == nil {
+		return identity.KodeDokter, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	if s.oidc.kdDokterClaim == "" {
+		return "", err
+	}
+	kdDokter, _ := claims[s.oidc.kdDokterClaim].(string)
+	if kdDokter == "" {
+		return "", fmt.Errorf("claim %s is empty", s.oidc.kdDokterClaim)
+	}
+
+	if err := s.authRepo.SaveOIDCIdentity(&OIDCIdentity{
+		Issuer:     s.oidc.issuer,
+		Subject:    subject,
+		KodeDokter: kdDokter,
+		CreatedAt:  time.Now(),
+	}); err != nil {
+		return "", err
+	}
+	fmt.Printf("✅ OIDC subject %s auto-linked to dokter %s\n", subject, kdDokter)
+	return kdDokter, nil
+}
+
+// lookupDokterUser: id_user dokter di Khanza sama dengan kd_dokter
+func (s *authService) lookupDokterUser(kdDokter string) (*User, error) {
+	if user, err := s.verifier.LookupUser(sourceKhanza, kdDokter); err == nil && user.KodeDokter != "" {
+		return user, nil
+	}
+
+	nmDokter, err := s.authRepo.GetNamaDokter(kdDokter)
+	if err != nil {
+		return nil, err
+	}
+	if nmDokter == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &User{IDUser: kdDokter, KodeDokter: kdDokter, NamaDokter: nmDokter, Source: sourceKhanza}, nil
+}
+
+// ✅ Admin: daftar mapping subject IdP -> kd_dokter
+func (s *authService) GetOIDCIdentities() ([]OIDCIdentity, error) {
+	if s.oidc == nil {
+		return nil, ErrOIDCDisabled
+	}
+	return s.authRepo.GetOIDCIdentities(s.oidc.issuer)
+}
+
+// ✅ Admin: hubungkan subject IdP ke dokter
+func (s *authService) LinkOIDCIdentity(subject, kdDokter string) error {
+	if s.oidc == nil {
+		return ErrOIDCDisabled
+	}
+
+	nmDokter, err := s.authRepo.GetNamaDokter(kdDokter)
+	if err != nil || nmDokter == "" {
+		return errors.New("dokter not found")
+	}
+
+	if err := s.authRepo.SaveOIDCIdentity(&OIDCIdentity{
+		Issuer:     s.oidc.issuer,
+		Subject:    subject,
+		KodeDokter: kdDokter,
+		CreatedAt:  time.Now(),
+	}); err != nil {
+		fmt.Printf("❌ Failed to link OIDC identity: %v\n", err)
+		return errors.New("failed to link sso account")
+	}
+
+	fmt.Printf("✅ OIDC subject %s linked to dokter %s\n", subject, kdDokter)
+	return nil
+}
+
+// ✅ Admin: putuskan mapping subject IdP
+func (s *authService) UnlinkOIDCIdentity(subject string) error {
+	if s.oidc == nil {
+		return ErrOIDCDisabled
+	}
+
+	err := s.authRepo.DeleteOIDCIdentity(s.oidc.issuer, subject)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrOIDCLinkNotFound
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to unlink OIDC identity: %v\n", err)
+		return errors.New("failed to unlink sso account")
+	}
+
+	fmt.Printf("✅ OIDC subject %s unlinked\n", subject)
+	return nil
+}
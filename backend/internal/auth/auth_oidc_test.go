@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"pwa-rsbw/internal/config"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIdP: IdP OIDC minimal di httptest (discovery, JWKS, token endpoint + PKCE)
+type testIdP struct {
+	server *httptest.Server
+	key    ed25519.PrivateKey
+
+	mu        sync.Mutex
+	nonce     string
+	challenge string
+	claims    jwt.MapClaims // claim tambahan di id_token (amr, acr, kd_dokter)
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(JWKSet{Keys: []JWK{{
+			Kty: "OKP",
+			Kid: "idp-key",
+			Use: "sig",
+			Alg: algEdDSA,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if r.PostFormValue("code") != "good-code" || base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"sub":   "subject-1",
+		"aud":   "pwa-rsbw",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": idp.nonce,
+	}
+	for name, value := range idp.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = "idp-key"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+// authorize mensimulasikan redirect IdP: simpan nonce & challenge dari authorization_url
+func (idp *testIdP) authorize(t *testing.T, authURL string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.nonce = parsed.Query().Get("nonce")
+	idp.challenge = parsed.Query().Get("code_challenge")
+	idp.mu.Unlock()
+}
+
+func newOIDCTestService(t *testing.T, idp *testIdP) (*authService, *fakeAuthRepo) {
+	t.Helper()
+
+	repo := newFakeAuthRepo()
+	repo.khanzaUsers["D001"] = &User{IDUser: "D001", KodeDokter: "D001", NamaDokter: "dr. Andi"}
+	cfg := &config.Config{
+		CredentialVerifiers: []string{sourceKhanza},
+		OIDCIssuer:          idp.server.URL,
+		OIDCClientID:        "pwa-rsbw",
+		OIDCRedirectURL:     "http://localhost:3000/sso/callback",
+		OIDCScopes:          []string{"openid"},
+		OIDCKdDokterClaim:   "kd_dokter",
+		OIDCMFAACR:          []string{"urn:rsbw:mfa"},
+	}
+	service := &authService{
+		authRepo:   repo,
+		verifier:   NewCredentialVerifier(cfg, repo),
+		keys:       newTestKeyManager(t, repo, algEdDSA),
+		accessTTL:  time.Minute,
+		refreshTTL: time.Hour,
+		oidc:       newOIDCProvider(cfg),
+	}
+	return service, repo
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		claims      jwt.MapClaims
+		totpEnabled bool
+		cookie      func(state string) string
+		wantErr     error
+		wantMFA     bool
+	}{
+		{
+			name:   "linked dokter without totp",
+			claims: jwt.MapClaims{"kd_dokter": "D001", "amr": []string{"pwd"}},
+		},
+		{
+			name:    "missing state cookie",
+			claims:  jwt.MapClaims{"kd_dokter": "D001"},
+			cookie:  func(string) string { return "" },
+			wantErr: ErrOIDCInvalidState,
+		},
+		{
+			// Login CSRF: state milik penyerang dipakai di browser korban
+			name:    "state from another browser",
+			claims:  jwt.MapClaims{"kd_dokter": "D001"},
+			cookie:  func(string) string { return "attacker-state" },
+			wantErr: ErrOIDCInvalidState,
+		},
+		{
+			name:        "totp required when idp did not do mfa",
+			claims:      jwt.MapClaims{"kd_dokter": "D001", "amr": []string{"pwd"}},
+			totpEnabled: true,
+			wantMFA:     true,
+		},
+		{
+			name:        "idp mfa via amr skips totp",
+			claims:      jwt.MapClaims{"kd_dokter": "D001", "amr": []string{"pwd", "otp"}},
+			totpEnabled: true,
+		},
+		{
+			name:        "idp mfa via configured acr skips totp",
+			claims:      jwt.MapClaims{"kd_dokter": "D001", "acr": "urn:rsbw:mfa"},
+			totpEnabled: true,
+		},
+		{
+			name:    "unlinked subject",
+			claims:  jwt.MapClaims{"amr": []string{"mfa"}},
+			wantErr: ErrOIDCNotLinked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIdP(t)
+			idp.claims = tt.claims
+			service, repo := newOIDCTestService(t, idp)
+			if tt.totpEnabled {
+				repo.mfa["D001"] = &MFATOTP{IDUser: "D001", Enabled: true}
+			}
+
+			start, err := service.StartOIDCLogin()
+			if err != nil {
+				t.Fatalf("StartOIDCLogin: %v", err)
+			}
+			idp.authorize(t, start.AuthorizationURL)
+
+			cookie := start.State
+			if tt.cookie != nil {
+				cookie = tt.cookie(start.State)
+			}
+			resp, err := service.CompleteOIDCLogin("good-code", start.State, cookie, ClientInfo{IP: "10.0.0.1"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompleteOIDCLogin: %v", err)
+			}
+
+			if resp.MFARequired != tt.wantMFA {
+				t.Fatalf("MFARequired = %v, want %v", resp.MFARequired, tt.wantMFA)
+			}
+			if tt.wantMFA {
+				if resp.Token != "" || resp.MFAToken == "" {
+					t.Fatalf("challenge response = %+v, want only an mfa token", resp)
+				}
+				claims := &JWTClaims{}
+				if _, err := jwt.ParseWithClaims(resp.MFAToken, claims, service.keys.Keyfunc); err != nil {
+					t.Fatalf("parse mfa token: %v", err)
+				}
+				if claims.TokenType != tokenTypeMFA || claims.Source != sourceOIDC {
+					t.Fatalf("mfa token typ=%s src=%s, want %s/%s", claims.TokenType, claims.Source, tokenTypeMFA, sourceOIDC)
+				}
+				return
+			}
+			if resp.Token == "" || resp.KodeDokter != "D001" {
+				t.Fatalf("login response = %+v, want a token for D001", resp)
+			}
+
+			// state sekali pakai
+			if _, err := service.CompleteOIDCLogin("good-code", start.State, start.State, ClientInfo{}); !errors.Is(err, ErrOIDCInvalidState) {
+				t.Fatalf("replayed state: err = %v, want ErrOIDCInvalidState", err)
+			}
+		})
+	}
+}
+
+func TestIDTokenHasMFA(t *testing.T) {
+	mfaACR := []string{"urn:rsbw:mfa"}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   bool
+	}{
+		{"no amr or acr", jwt.MapClaims{}, false},
+		{"password only", jwt.MapClaims{"amr": []interface{}{"pwd"}}, false},
+		{"amr mfa", jwt.MapClaims{"amr": []interface{}{"mfa"}}, true},
+		{"amr otp", jwt.MapClaims{"amr": []interface{}{"pwd", "otp"}}, true},
+		{"amr hardware key", jwt.MapClaims{"amr": []interface{}{"hwk"}}, true},
+		{"amr as string is ignored", jwt.MapClaims{"amr": "mfa"}, false},
+		{"configured acr", jwt.MapClaims{"acr": "urn:rsbw:mfa"}, true},
+		{"other acr", jwt.MapClaims{"acr": "urn:rsbw:pwd"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idTokenHasMFA(tt.claims, mfaACR); got != tt.want {
+				t.Errorf("idTokenHasMFA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJWK(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	tests := []struct {
+		name    string
+		jwk     JWK
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{
+			name: "rsa",
+			jwk:  JWK{Kty: "RSA", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			want: &rsaKey.PublicKey,
+		},
+		{name: "ed25519", jwk: JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edPublic)}, want: edPublic},
+		{name: "ed25519 wrong size", jwk: JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edPublic[:16])}, wantErr: true},
+		{name: "unsupported curve", jwk: JWK{Kty: "OKP", Crv: "X25519", X: b64(edPublic)}, wantErr: true},
+		{name: "bad base64", jwk: JWK{Kty: "RSA", N: "!!", E: "AQAB"}, wantErr: true},
+		{name: "unsupported kty", jwk: JWK{Kty: "EC"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJWK(tt.jwk)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			type equaler interface{ Equal(x crypto.PublicKey) bool }
+			if !got.(equaler).Equal(tt.want) {
+				t.Errorf("parseJWK() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
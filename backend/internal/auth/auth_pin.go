@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/textutil"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	pinMinLength = 4
+	pinMaxLength = 6
+)
+
+var (
+	ErrInvalidPIN         = errors.New("invalid pin")
+	ErrDeviceDeregistered = errors.New("device deregistered, please login again")
+	// ErrPINUnavailable: gagal sementara (database); perangkat tetap terdaftar, client boleh retry
+	ErrPINUnavailable = errors.New("quick-unlock is temporarily unavailable, please try again")
+)
+
+func validPINFormat(pin string) bool {
+	if len(pin) < pinMinLength || len(pin) > pinMaxLength {
+		return false
+	}
+	for _, ch := range pin {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ✅ RegisterPIN: dipanggil setelah login penuh. Perangkat menyimpan device_secret,
+// dokter cukup mengetik PIN saat access token habis.
+func (s *authService) RegisterPIN(claims *JWTClaims, pin string, client ClientInfo) (*PINRegisterResponse, error) {
+	if !validPINFormat(pin) {
+		return nil, fmt.Errorf("pin must be %d-%d digits", pinMinLength, pinMaxLength)
+	}
+	if client.DeviceID == "" {
+		return nil, errors.New("X-Device-ID header is required")
+	}
+	if claims.SessionID == "" {
+		return nil, errors.New("pin requires a login session")
+	}
+
+	session, err := s.authRepo.GetSession(claims.SessionID)
+	if err != nil || session.RevokedAt != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	keyID, err := generateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("failed to register pin")
+	}
+	secret, err := generateRandomToken(32)
+	if err != nil {
+		return nil, errors.New("failed to register pin")
+	}
+	pinHash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New("failed to register pin")
+	}
+
+	if err := s.authRepo.SaveDevicePIN(&DevicePIN{
+		ID:         keyID,
+		IDUser:     claims.IDUser,
+		SessionID:  claims.SessionID,
+		DeviceID:   textutil.Truncate(client.DeviceID, 64),
+		SecretHash: hashToken(secret),
+		PINHash:    string(pinHash),
+		CreatedAt:  time.Now(),
+	}); err != nil {
+		fmt.Printf("❌ Failed to save device pin: %v\n", err)
+		return nil, errors.New("failed to register pin")
+	}
+
+	fmt.Printf("✅ Quick-unlock PIN registered for user %s (device: %s)\n", claims.IDUser, client.DeviceID)
+	return &PINRegisterResponse{
+		DeviceKeyID:  keyID,
+		DeviceSecret: secret,
+		ExpiresAt:    session.ExpiresAt.Unix(),
+	}, nil
+}
+
+// ✅ UnlockWithPIN menerbitkan access token baru di sesi yang sama (tanpa refresh token).
+// Setelah pinMaxTry kali gagal, registrasi perangkat dihapus dan dokter harus login penuh.
+func (s *authService) UnlockWithPIN(req PINUnlockRequest, client ClientInfo) (*LoginResponse, error) {
+	device, err := s.authRepo.GetDevicePIN(req.DeviceKeyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeviceDeregistered
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to get device pin: %v\n", err)
+		return nil, ErrPINUnavailable
+	}
+
+	// Secret & perangkat harus cocok; ini bukan tebakan PIN jadi tidak menambah counter.
+	// X-Device-ID wajib: tanpa itu device_secret yang bocor bisa dipakai dari perangkat lain.
+	if client.DeviceID == "" {
+		return nil, errors.New("X-Device-ID header is required")
+	}
+	secretOK := subtle.ConstantTimeCompare([]byte(device.SecretHash), []byte(hashToken(req.DeviceSecret))) == 1
+	if !secretOK || client.DeviceID != device.DeviceID {
+		fmt.Printf("❌ Invalid device secret for pin key: %s\n", req.DeviceKeyID)
+		return nil, ErrInvalidPIN
+	}
+
+	session, err := s.authRepo.GetSession(device.SessionID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("❌ Failed to get session for pin unlock: %v\n", err)
+		return nil, ErrPINUnavailable
+	}
+	if err != nil || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		s.deregisterDevice(device, "session ended")
+		return nil, ErrDeviceDeregistered
+	}
+
+	// Jatah percobaan dipesan dulu (atomik) baru bcrypt, supaya tebakan paralel tetap dihitung
+	// ErrRecordNotFound = jatah habis; error lain (database) tidak boleh menghapus perangkat
+	attempt, err := s.authRepo.ReserveDevicePINAttempt(device.ID, s.pinMaxTry)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.deregisterDevice(device, "too many failed pin attempts")
+		return nil, ErrDeviceDeregistered
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to reserve pin attempt: %v\n", err)
+		return nil, ErrPINUnavailable
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(device.PINHash), []byte(req.PIN)) != nil {
+		if attempt >= s.pinMaxTry {
+			s.deregisterDevice(device, "too many failed pin attempts")
+			return nil, ErrDeviceDeregistered
+		}
+		fmt.Printf("❌ Invalid PIN for user %s (%d/%d)\n", device.IDUser, attempt, s.pinMaxTry)
+		return nil, ErrInvalidPIN
+	}
+
+	// Akun yang sudah dihapus/tidak dikenali lagi: perangkat dilepas; gagal sementara: boleh retry
+	user, err := s.lookupUser(session.Source, device.IDUser)
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrInvalidCredentials) {
+		s.deregisterDevice(device, "account no longer available")
+		return nil, ErrDeviceDeregistered
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to look up user %s for pin unlock: %v\n", device.IDUser, err)
+		return nil, ErrPINUnavailable
+	}
+	s.resolveAccess(device.IDUser, user)
+
+	now := time.Now()
+	if err := s.authRepo.MarkDevicePINUsed(device.ID, now); err != nil {
+		fmt.Printf("⚠️ Failed to update device pin: %v\n", err)
+	}
+	if err := s.authRepo.TouchSession(session.ID, now, client.IP, nil); err != nil {
+		fmt.Printf("⚠️ Failed to update session: %v\n", err)
+	}
+
+	fmt.Printf("✅ Quick-unlock with PIN for user: %s\n", device.IDUser)
+	return s.buildLoginResponse(device.IDUser, user, session.ID, "", session.ExpiresAt)
+}
+
+// ✅ RemovePIN menghapus registrasi PIN untuk perangkat yang sedang dipakai
+func (s *authService) RemovePIN(idUser string, client ClientInfo) error {
+	if client.DeviceID == "" {
+		return errors.New("X-Device-ID header is required")
+	}
+	if err := s.authRepo.DeleteDevicePINsForDevice(idUser, client.DeviceID); err != nil {
+		fmt.Printf("❌ Failed to remove device pin: %v\n", err)
+		return errors.New("failed to remove pin")
+	}
+
+	fmt.Printf("✅ Quick-unlock PIN removed for user %s (device: %s)\n", idUser, client.DeviceID)
+	return nil
+}
+
+func (s *authService) deregisterDevice(device *DevicePIN, reason string) {
+	if err := s.authRepo.DeleteDevicePIN(device.ID); err != nil {
+		fmt.Printf("⚠️ Failed to deregister device: %v\n", err)
+		return
+	}
+	fmt.Printf("🔒 Quick-unlock device deregistered for user %s: %s\n", device.IDUser, reason)
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func newPINTestService(t *testing.T, failedCount int) (*authService, *fakeAuthRepo) {
+	t.Helper()
+	pinHash, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newFakeAuthRepo()
+	repo.sessions["sess1"] = &Session{ID: "sess1", IDUser: "dr01", ExpiresAt: time.Now().Add(time.Hour)}
+	repo.devicePINs["key1"] = &DevicePIN{
+		ID:          "key1",
+		IDUser:      "dr01",
+		SessionID:   "sess1",
+		DeviceID:    "device-a",
+		SecretHash:  hashToken("device-secret"),
+		PINHash:     string(pinHash),
+		FailedCount: failedCount,
+	}
+	return &authService{authRepo: repo, pinMaxTry: 3}, repo
+}
+
+func TestUnlockWithPINRejectsDevice(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		deviceID string
+	}{
+		{"missing device header", "device-secret", ""},
+		{"other device", "device-secret", "device-b"},
+		{"wrong secret", "wrong", "device-a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo := newPINTestService(t, 0)
+			req := PINUnlockRequest{DeviceKeyID: "key1", DeviceSecret: tt.secret, PIN: "123456"}
+
+			if _, err := service.UnlockWithPIN(req, ClientInfo{DeviceID: tt.deviceID}); err == nil {
+				t.Fatal("UnlockWithPIN accepted the request")
+			}
+			if got := repo.devicePINs["key1"].FailedCount; got != 0 {
+				t.Errorf("FailedCount = %d, want 0 (not a PIN guess)", got)
+			}
+		})
+	}
+}
+
+func TestUnlockWithPINDeregistersAfterMaxTry(t *testing.T) {
+	service, repo := newPINTestService(t, 0)
+	client := ClientInfo{DeviceID: "device-a"}
+	req := PINUnlockRequest{DeviceKeyID: "key1", DeviceSecret: "device-secret", PIN: "000000"}
+
+	for i := 1; i < service.pinMaxTry; i++ {
+		if _, err := service.UnlockWithPIN(req, client); !errors.Is(err, ErrInvalidPIN) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidPIN", i, err)
+		}
+	}
+	if _, err := service.UnlockWithPIN(req, client); !errors.Is(err, ErrDeviceDeregistered) {
+		t.Fatalf("last attempt: err = %v, want ErrDeviceDeregistered", err)
+	}
+	if _, ok := repo.devicePINs["key1"]; ok {
+		t.Fatal("device still registered after pinMaxTry failures")
+	}
+}
+
+func TestUnlockWithPINRefusesWhenAttemptsExhausted(t *testing.T) {
+	// Tebakan paralel sudah menghabiskan jatah: PIN yang benar pun tidak diperiksa lagi
+	service, repo := newPINTestService(t, 3)
+	req := PINUnlockRequest{DeviceKeyID: "key1", DeviceSecret: "device-secret", PIN: "123456"}
+
+	if _, err := service.UnlockWithPIN(req, ClientInfo{DeviceID: "device-a"}); !errors.Is(err, ErrDeviceDeregistered) {
+		t.Fatalf("err = %v, want ErrDeviceDeregistered", err)
+	}
+	if _, ok := repo.devicePINs["key1"]; ok {
+		t.Fatal("device still registered with exhausted attempts")
+	}
+}
+
+func TestUnlockWithPINKeepsDeviceOnDatabaseError(t *testing.T) {
+	dbErr := errors.New("connection refused")
+	tests := []struct {
+		name       string
+		sessionErr error
+		reserveErr error
+		userErr    error
+		noUser     bool
+		wantErr    error
+		wantKept   bool
+	}{
+		{name: "session lookup fails", sessionErr: dbErr, wantErr: ErrPINUnavailable, wantKept: true},
+		{name: "attempt reservation fails", reserveErr: dbErr, wantErr: ErrPINUnavailable, wantKept: true},
+		{name: "user lookup fails", userErr: dbErr, wantErr: ErrPINUnavailable, wantKept: true},
+		{name: "session gone", sessionErr: gorm.ErrRecordNotFound, wantErr: ErrDeviceDeregistered},
+		{name: "account deleted", noUser: true, wantErr: ErrDeviceDeregistered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo := newPINTestService(t, 0)
+			service.verifier = &khanzaVerifier{authRepo: repo}
+			repo.sessions["sess1"].Source = sourceKhanza
+			if !tt.noUser {
+				repo.khanzaUsers["dr01"] = &User{IDUser: "dr01"}
+			}
+			repo.sessionErr, repo.reserveErr, repo.userErr = tt.sessionErr, tt.reserveErr, tt.userErr
+			req := PINUnlockRequest{DeviceKeyID: "key1", DeviceSecret: "device-secret", PIN: "123456"}
+
+			if _, err := service.UnlockWithPIN(req, ClientInfo{DeviceID: "device-a"}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if _, kept := repo.devicePINs["key1"]; kept != tt.wantKept {
+				t.Errorf("device registered = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
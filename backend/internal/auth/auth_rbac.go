@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role pengguna, di-resolve saat login dari tabel dokter, petugas, dan jabatan
+const (
+	RoleDokter      = "dokter"
+	RolePetugas     = "petugas"
+	RoleKepalaRuang = "kepala_ruang"
+	RoleKomiteMedik = "komite_medik"
+	RoleAdmin       = "admin"
+	RoleService     = "service" // API key integrasi (bukan user), lihat auth_apikey.go
+)
+
+// Permission yang dicek oleh RequirePermission di routing
+const (
+	PermRanapRead     = "ranap:read"
+	PermCpptRead      = "cppt:read"
+	PermCpptWrite     = "cppt:write"
+	PermLabRead       = "lab:read"
+	PermRadiologiRead = "radiologi:read"
+	PermObatRead      = "obat:read"
+	PermResepWrite    = "resep:write"
+	PermAuditRead     = "audit:read"
+	PermAdmin         = "admin"
+)
+
+// Permission bawaan setiap role
+var rolePermissions = map[string][]string{
+	RoleDokter:      {PermRanapRead, PermCpptRead, PermCpptWrite, PermLabRead, PermRadiologiRead, PermObatRead, PermResepWrite},
+	RolePetugas:     {PermRanapRead, PermCpptRead, PermCpptWrite},
+	RoleKepalaRuang: {PermRanapRead, PermCpptRead, PermCpptWrite},
+	RoleKomiteMedik: {PermRanapRead, PermCpptRead, PermAuditRead},
+	RoleAdmin:       {PermAdmin, PermAuditRead},
+}
+
+// Permission baca saja (tanpa admin/audit): batas untuk API key dan token impersonasi
+var readOnlyPermissions = map[string]bool{
+	PermRanapRead:     true,
+	PermCpptRead:      true,
+	PermLabRead:       true,
+	PermRadiologiRead: true,
+	PermObatRead:      true,
+}
+
+// Permission yang boleh diberikan ke API key: hanya yang route-nya bisa dipakai tanpa kd_dokter
+// (daftar pasien per bangsal, pencarian obat). Handler cppt/lab/radiologi/resep menolak caller tanpa kd_dokter.
+var apiKeyPermissions = map[string]bool{
+	PermRanapRead: true,
+	PermObatRead:  true,
+}
+
+// Kolom hak akses di tabel user Khanza (enum 'true'/'false') -> permission aplikasi.
+// Kolom yang tidak ada di versi Khanza yang terpasang akan diabaikan.
+var khanzaPermissionColumns = map[string]string{
+	"kamar_inap":        PermRanapRead,
+	"tindakan_ranap":    PermCpptWrite,
+	"periksa_lab":       PermLabRead,
+	"periksa_radiologi": PermRadiologiRead,
+	"resep_obat":        PermObatRead,
+	"resep_dokter":      PermResepWrite,
+}
+
+// resolvePermissions menggabungkan permission dari role dan dari kolom user Khanza
+func resolvePermissions(roles []string, khanzaPerms []string) []string {
+	set := make(map[string]bool)
+	for _, role := range roles {
+		for _, perm := range rolePermissions[role] {
+			set[perm] = true
+		}
+	}
+	for _, perm := range khanzaPerms {
+		set[perm] = true
+	}
+
+	perms := make([]string, 0, len(set))
+	for perm := range set {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// HasPermission mengecek permission milik user yang sedang login (diisi oleh JWTMiddleware)
+func HasPermission(c *gin.Context, perm string) bool {
+	for _, p := range c.GetStringSlice("permissions") {
+		if p == perm || p == PermAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole mengecek role milik user yang sedang login
+func HasRole(c *gin.Context, role string) bool {
+	for _, r := range c.GetStringSlice("roles") {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ✅ RequirePermission: middleware untuk route group, dipasang SETELAH JWTMiddleware.
+// Semua permission yang disebut wajib dimiliki (role admin selalu lolos).
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if !HasPermission(c, perm) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"status":  "error",
+					"message": "Access denied: missing permission " + strings.Join(perms, ", "),
+				})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
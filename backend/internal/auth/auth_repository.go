@@ -1,20 +1,102 @@
 package auth
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type AuthRepository interface {
 	GetUserByCredentials(idUser, password string) (*User, error)
+	GetUserByID(idUser string) (*User, error)
+	GetLocalAccount(idUser string) (*LocalAccount, error)
+	GetNamaDokter(kdDokter string) (string, error)
+
+	// Role & hak akses
+	GetJabatanPetugas(nip string) (string, bool, error)
+	GetKhanzaPermissionFlags(idUser string) (map[string]bool, error)
+
+	// Refresh token & logout
+	CreateRefreshToken(token *RefreshToken) error
+	GetRefreshTokenByHash(tokenHash string) (*RefreshToken, error)
+	RotateRefreshToken(oldToken *RefreshToken, newToken *RefreshToken) error
+	RevokeRefreshFamily(familyID string) error
+	RevokeAccessToken(jti, idUser string, expiresAt time.Time) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	DeleteExpiredTokens() error
+
+	// Proteksi brute-force
+	GetLoginAttempt(keyType, keyValue string) (*LoginAttempt, error)
+	RecordFailedLogin(keyType, keyValue string, now, resetBefore time.Time, maxAttempts int, lockedUntil time.Time) (*LoginAttempt, error)
+	DeleteLoginAttempt(keyType, keyValue string) error
+	GetLockedLoginAttempts(now time.Time) ([]LoginAttempt, error)
+
+	// Sesi per perangkat
+	CreateSession(session *Session) error
+	GetSession(id string) (*Session, error)
+	GetActiveSessions(idUser string, now time.Time) ([]Session, error)
+	TouchSession(id string, lastSeen time.Time, ip string, expiresAt *time.Time) error
+	RevokeSessions(idUser string, ids []string) error
+
+	// Quick-unlock PIN
+	SaveDevicePIN(pin *DevicePIN) error
+	GetDevicePIN(id string) (*DevicePIN, error)
+	ReserveDevicePINAttempt(id string, maxTry int) (int, error)
+	MarkDevicePINUsed(id string, usedAt time.Time) error
+	DeleteDevicePIN(id string) error
+	DeleteDevicePINsForDevice(idUser, deviceID string) error
+
+	// OIDC SSO
+	CreateOIDCState(state *OIDCState) error
+	ConsumeOIDCState(state string, now time.Time) (*OIDCState, error)
+	GetOIDCIdentity(issuer, subject string) (*OIDCIdentity, error)
+	GetOIDCIdentities(issuer string) ([]OIDCIdentity, error)
+	SaveOIDCIdentity(identity *OIDCIdentity) error
+	DeleteOIDCIdentity(issuer, subject string) error
+	MarkOIDCLogin(issuer, subject string, at time.Time) error
+
+	// API key service account
+	CreateAPIKey(key *APIKey) error
+	GetAPIKey(id string) (*APIKey, error)
+	GetAPIKeys() ([]APIKey, error)
+	RevokeAPIKey(id string, revokedAt time.Time) error
+	TouchAPIKey(id string, usedAt time.Time) error
+
+	// Impersonasi admin
+	CreateImpersonationLog(log *ImpersonationLog) error
+	GetImpersonationLogs(limit int) ([]ImpersonationLog, error)
+
+	// JWT signing key
+	GetSigningKeys(retiredAfter time.Time) ([]SigningKey, error)
+	CreateSigningKeyIfDue(key *SigningKey, dueBefore time.Time) (bool, error)
+	RetireSigningKeysCreatedBefore(createdBefore, retiredAt time.Time) error
+	DeleteSigningKeysRetiredBefore(before time.Time) error
+
+	// TOTP / MFA
+	GetMFA(idUser string) (*MFATOTP, error)
+	SaveMFA(mfa *MFATOTP) error
+	DeleteMFA(idUser string) error
+	MarkTOTPStepUsed(idUser string, step int64) (bool, error)
+	ReplaceRecoveryCodes(idUser string, codeHashes []string) error
+	UseRecoveryCode(idUser, codeHash string) (bool, error)
 }
 
+// signingKeyLockName: named lock MySQL untuk rotasi JWT signing key
+const signingKeyLockName = "pwa_jwt_signing_key_rotation"
+
 type authRepository struct {
 	db *gorm.DB
+
+	// Kunci AES Khanza untuk kolom user.id_user dan user.password
+	aesKeyUser     string
+	aesKeyPassword string
 }
 
-func NewAuthRepository(db *gorm.DB) AuthRepository {
+func NewAuthRepository(db *gorm.DB, aesKeyUser, aesKeyPassword string) AuthRepository {
 	return &authRepository{
-		db: db,
+		db:             db,
+		aesKeyUser:     aesKeyUser,
+		aesKeyPassword: aesKeyPassword,
 	}
 }
 
@@ -29,10 +111,10 @@ func (r *authRepository) GetUserByCredentials(idUser, password string) (*User, e
 			COALESCE(d.kd_dokter, '') as kd_dokter,
 			COALESCE(d.nm_dokter, '') as nm_dokter
 		FROM user u
-		LEFT JOIN dokter d ON AES_DECRYPT(u.id_user, 'nur') = d.kd_dokter
-		WHERE AES_DECRYPT(u.id_user, 'nur') = ?
-		AND AES_DECRYPT(u.password, 'windi') = ?
-	`, idUser, password).Scan(&user).Error
+		LEFT JOIN dokter d ON AES_DECRYPT(u.id_user, ?) = d.kd_dokter
+		WHERE AES_DECRYPT(u.id_user, ?) = ?
+		AND AES_DECRYPT(u.password, ?) = ?
+	`, r.aesKeyUser, r.aesKeyUser, idUser, r.aesKeyPassword, password).Scan(&user).Error
 
 	if err != nil {
 		return nil, err
@@ -45,3 +127,542 @@ func (r *authRepository) GetUserByCredentials(idUser, password string) (*User, e
 
 	return &user, nil
 }
+
+// GetUserByID: sama seperti GetUserByCredentials tanpa cek password (dipakai saat refresh token)
+func (r *authRepository) GetUserByID(idUser string) (*User, error) {
+	var user User
+
+	err := r.db.Raw(`
+		SELECT 
+			u.id_user,
+			COALESCE(d.kd_dokter, '') as kd_dokter,
+			COALESCE(d.nm_dokter, '') as nm_dokter
+		FROM user u
+		LEFT JOIN dokter d ON AES_DECRYPT(u.id_user, ?) = d.kd_dokter
+		WHERE AES_DECRYPT(u.id_user, ?) = ?
+	`, r.aesKeyUser, r.aesKeyUser, idUser).Scan(&user).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IDUser == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &user, nil
+}
+
+// GetLocalAccount mengambil akun milik aplikasi (password bcrypt), bukan dari tabel user Khanza
+func (r *authRepository) GetLocalAccount(idUser string) (*LocalAccount, error) {
+	var account LocalAccount
+	err := r.db.Where("id_user = ? AND active = ?", idUser, true).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *authRepository) GetNamaDokter(kdDokter string) (string, error) {
+	var nmDokter string
+	err := r.db.Raw(`SELECT nm_dokter FROM dokter WHERE kd_dokter = ?`, kdDokter).Scan(&nmDokter).Error
+	if err != nil {
+		return "", err
+	}
+	return nmDokter, nil
+}
+
+// GetJabatanPetugas mengembalikan nama jabatan petugas aktif (nip = id_user)
+func (r *authRepository) GetJabatanPetugas(nip string) (string, bool, error) {
+	var result struct {
+		NIP    string `gorm:"column:nip"`
+		NmJbtn string `gorm:"column:nm_jbtn"`
+	}
+
+	err := r.db.Raw(`
+		SELECT p.nip, COALESCE(j.nm_jbtn, '') as nm_jbtn
+		FROM petugas p
+		LEFT JOIN jabatan j ON p.kd_jbtn = j.kd_jbtn
+		WHERE p.nip = ? AND p.status = '1'
+	`, nip).Scan(&result).Error
+	if err != nil {
+		return "", false, err
+	}
+
+	return result.NmJbtn, result.NIP != "", nil
+}
+
+// GetKhanzaPermissionFlags membaca kolom hak akses (enum 'true'/'false') dari tabel user.
+// Dipakai SELECT * karena jumlah kolom berbeda antar versi Khanza.
+func (r *authRepository) GetKhanzaPermissionFlags(idUser string) (map[string]bool, error) {
+	row := map[string]interface{}{}
+	err := r.db.Raw(`SELECT * FROM user WHERE AES_DECRYPT(id_user, ?) = ?`, r.aesKeyUser, idUser).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make(map[string]bool, len(row))
+	for column, value := range row {
+		switch v := value.(type) {
+		case string:
+			flags[column] = v == "true"
+		case []byte:
+			flags[column] = string(v) == "true"
+		}
+	}
+	return flags, nil
+}
+
+func (r *authRepository) CreateRefreshToken(token *RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *authRepository) GetRefreshTokenByHash(tokenHash string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ✅ Rotasi: token lama ditandai revoked + replaced_by, token baru disimpan (satu transaksi)
+func (r *authRepository) RotateRefreshToken(oldToken *RefreshToken, newToken *RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newToken).Error; err != nil {
+			return err
+		}
+
+		// WHERE revoked_at IS NULL mencegah dua request refresh paralel memakai token yang sama
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", oldToken.ID).
+			Updates(map[string]interface{}{
+				"revoked_at":  time.Now(),
+				"replaced_by": newToken.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *authRepository) RevokeRefreshFamily(familyID string) error {
+	return r.db.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *authRepository) RevokeAccessToken(jti, idUser string, expiresAt time.Time) error {
+	return r.db.Save(&RevokedToken{
+		JTI:       jti,
+		IDUser:    idUser,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}).Error
+}
+
+func (r *authRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Bersihkan baris yang sudah tidak relevan (token sudah kedaluwarsa dengan sendirinya)
+func (r *authRepository) DeleteExpiredTokens() error {
+	now := time.Now()
+	if err := r.db.Where("expires_at < ?", now).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("expires_at < ?", now).Delete(&RefreshToken{}).Error
+}
+
+func (r *authRepository) GetLoginAttempt(keyType, keyValue string) (*LoginAttempt, error) {
+	var attempt LoginAttempt
+	err := r.db.Where("key_type = ? AND key_value = ?", keyType, keyValue).First(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// ✅ RecordFailedLogin menambah counter secara atomik (aman untuk tebakan paralel).
+// Counter di-reset ke 1 jika gagal terakhir lebih lama dari resetBefore.
+func (r *authRepository) RecordFailedLogin(keyType, keyValue string, now, resetBefore time.Time, maxAttempts int, lockedUntil time.Time) (*LoginAttempt, error) {
+	// MySQL mengevaluasi assignment dari kiri ke kanan, jadi locked_until memakai failed_count yang baru
+	err := r.db.Exec(`
+		INSERT INTO pwa_login_attempt (key_type, key_value, failed_count, last_failed_at, locked_until)
+		VALUES (?, ?, 1, ?, NULL)
+		ON DUPLICATE KEY UPDATE
+			failed_count = IF(last_failed_at < ?, 1, failed_count + 1),
+			last_failed_at = VALUES(last_failed_at),
+			locked_until = IF(failed_count >= ?, ?, locked_until)
+	`, keyType, keyValue, now, resetBefore, maxAttempts, lockedUntil).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetLoginAttempt(keyType, keyValue)
+}
+
+func (r *authRepository) DeleteLoginAttempt(keyType, keyValue string) error {
+	return r.db.Where("key_type = ? AND key_value = ?", keyType, keyValue).Delete(&LoginAttempt{}).Error
+}
+
+func (r *authRepository) GetLockedLoginAttempts(now time.Time) ([]LoginAttempt, error) {
+	var attempts []LoginAttempt
+	err := r.db.Where("locked_until > ?", now).Order("locked_until DESC").Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (r *authRepository) GetMFA(idUser string) (*MFATOTP, error) {
+	var mfa MFATOTP
+	err := r.db.Where("id_user = ?", idUser).First(&mfa).Error
+	if err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+func (r *authRepository) SaveMFA(mfa *MFATOTP) error {
+	return r.db.Save(mfa).Error
+}
+
+func (r *authRepository) DeleteMFA(idUser string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id_user = ?", idUser).Delete(&MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id_user = ?", idUser).Delete(&MFATOTP{}).Error
+	})
+}
+
+// MarkTOTPStepUsed: false jika step ini (atau yang lebih baru) sudah pernah dipakai
+func (r *authRepository) MarkTOTPStepUsed(idUser string, step int64) (bool, error) {
+	result := r.db.Model(&MFATOTP{}).
+		Where("id_user = ? AND last_used_step < ?", idUser, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *authRepository) ReplaceRecoveryCodes(idUser string, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id_user = ?", idUser).Delete(&MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]MFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, MFARecoveryCode{IDUser: idUser, CodeHash: hash, CreatedAt: time.Now()})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *authRepository) UseRecoveryCode(idUser, codeHash string) (bool, error) {
+	result := r.db.Model(&MFARecoveryCode{}).
+		Where("id_user = ? AND code_hash = ? AND used_at IS NULL", idUser, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetSigningKeys: key aktif + key yang di-retire setelah retiredAfter, terbaru dulu
+func (r *authRepository) GetSigningKeys(retiredAfter time.Time) ([]SigningKey, error) {
+	var keys []SigningKey
+	err := r.db.Where("retired_at IS NULL OR retired_at > ?", retiredAfter).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateSigningKeyIfDue menyimpan key hanya jika belum ada key (algoritma sama, belum di-retire)
+// yang dibuat setelah dueBefore. Named lock MySQL memastikan hanya satu instance yang merotasi;
+// instance yang tidak mendapat lock mengembalikan false dan memakai key buatan instance lain.
+func (r *authRepository) CreateSigningKeyIfDue(key *SigningKey, dueBefore time.Time) (bool, error) {
+	created := false
+	err := r.db.Connection(func(conn *gorm.DB) error {
+		var locked int
+		if err := conn.Raw("SELECT GET_LOCK(?, 0)", signingKeyLockName).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if locked != 1 {
+			return nil
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", signingKeyLockName)
+
+		var recent int64
+		if err := conn.Model(&SigningKey{}).
+			Where("algorithm = ? AND retired_at IS NULL AND created_at > ?", key.Algorithm, dueBefore).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent > 0 {
+			return nil
+		}
+
+		if err := conn.Create(key).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// RetireSigningKeysCreatedBefore me-retire key yang lebih lama dari key aktif (idempoten antar instance)
+func (r *authRepository) RetireSigningKeysCreatedBefore(createdBefore, retiredAt time.Time) error {
+	return r.db.Model(&SigningKey{}).
+		Where("created_at < ? AND retired_at IS NULL", createdBefore).
+		Update("retired_at", retiredAt).Error
+}
+
+func (r *authRepository) DeleteSigningKeysRetiredBefore(before time.Time) error {
+	return r.db.Where("retired_at IS NOT NULL AND retired_at < ?", before).Delete(&SigningKey{}).Error
+}
+
+func (r *authRepository) CreateSession(session *Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *authRepository) GetSession(id string) (*Session, error) {
+	var session Session
+	err := r.db.Where("id = ?", id).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *authRepository) GetActiveSessions(idUser string, now time.Time) ([]Session, error) {
+	var sessions []Session
+	err := r.db.Where("id_user = ? AND revoked_at IS NULL AND expires_at > ?", idUser, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// TouchSession memperbarui last_seen (dan IP/expires_at jika diisi)
+func (r *authRepository) TouchSession(id string, lastSeen time.Time, ip string, expiresAt *time.Time) error {
+	updates := map[string]interface{}{"last_seen_at": lastSeen}
+	if ip != "" {
+		updates["ip_address"] = ip
+	}
+	if expiresAt != nil {
+		updates["expires_at"] = *expiresAt
+	}
+	return r.db.Model(&Session{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// ✅ RevokeSessions mencabut sesi milik idUser beserta refresh token-nya (satu transaksi)
+func (r *authRepository) RevokeSessions(idUser string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Session{}).
+			Where("id_user = ? AND id IN ? AND revoked_at IS NULL", idUser, ids).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&RefreshToken{}).
+			Where("id_user = ? AND family_id IN ? AND revoked_at IS NULL", idUser, ids).
+			Update("revoked_at", now).Error
+	})
+}
+
+// SaveDevicePIN mengganti registrasi lama untuk user + perangkat yang sama
+func (r *authRepository) SaveDevicePIN(pin *DevicePIN) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id_user = ? AND device_id = ?", pin.IDUser, pin.DeviceID).Delete(&DevicePIN{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(pin).Error
+	})
+}
+
+func (r *authRepository) GetDevicePIN(id string) (*DevicePIN, error) {
+	var pin DevicePIN
+	err := r.db.Where("id = ?", id).First(&pin).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// ReserveDevicePINAttempt menambah counter secara atomik SEBELUM PIN dicek, hanya jika
+// masih di bawah maxTry. Tebakan paralel tidak bisa melewati batas; jatah habis → ErrRecordNotFound.
+// Counter di-reset oleh MarkDevicePINUsed saat PIN benar.
+func (r *authRepository) ReserveDevicePINAttempt(id string, maxTry int) (int, error) {
+	var count int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&DevicePIN{}).Where("id = ? AND failed_count < ?", id, maxTry).
+			UpdateColumn("failed_count", gorm.Expr("failed_count + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&DevicePIN{}).Where("id = ?", id).Select("failed_count").Scan(&count).Error
+	})
+	return count, err
+}
+
+func (r *authRepository) MarkDevicePINUsed(id string, usedAt time.Time) error {
+	return r.db.Model(&DevicePIN{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_count": 0,
+		"last_used_at": usedAt,
+	}).Error
+}
+
+func (r *authRepository) DeleteDevicePIN(id string) error {
+	return r.db.Where("id = ?", id).Delete(&DevicePIN{}).Error
+}
+
+func (r *authRepository) DeleteDevicePINsForDevice(idUser, deviceID string) error {
+	return r.db.Where("id_user = ? AND device_id = ?", idUser, deviceID).Delete(&DevicePIN{}).Error
+}
+
+func (r *authRepository) CreateOIDCState(state *OIDCState) error {
+	// Bersihkan state kedaluwarsa sekalian
+	if err := r.db.Where("expires_at < ?", state.CreatedAt).Delete(&OIDCState{}).Error; err != nil {
+		return err
+	}
+	return r.db.Create(state).Error
+}
+
+// ✅ ConsumeOIDCState mengambil lalu menghapus state; state yang sama tidak bisa dipakai dua kali
+func (r *authRepository) ConsumeOIDCState(state string, now time.Time) (*OIDCState, error) {
+	var stored OIDCState
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ? AND expires_at > ?", state, now).First(&stored).Error; err != nil {
+			return err
+		}
+		result := tx.Where("state = ?", state).Delete(&OIDCState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (r *authRepository) GetOIDCIdentity(issuer, subject string) (*OIDCIdentity, error) {
+	var identity OIDCIdentity
+	err := r.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *authRepository) GetOIDCIdentities(issuer string) ([]OIDCIdentity, error) {
+	var identities []OIDCIdentity
+	err := r.db.Where("issuer = ?", issuer).Order("kd_dokter").Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *authRepository) SaveOIDCIdentity(identity *OIDCIdentity) error {
+	return r.db.Save(identity).Error
+}
+
+func (r *authRepository) DeleteOIDCIdentity(issuer, subject string) error {
+	result := r.db.Where("issuer = ? AND subject = ?", issuer, subject).Delete(&OIDCIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *authRepository) MarkOIDCLogin(issuer, subject string, at time.Time) error {
+	return r.db.Model(&OIDCIdentity{}).
+		Where("issuer = ? AND subject = ?", issuer, subject).
+		Update("last_login", at).Error
+}
+
+func (r *authRepository) CreateAPIKey(key *APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *authRepository) GetAPIKey(id string) (*APIKey, error) {
+	var key APIKey
+	err := r.db.Where("id = ?", id).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *authRepository) GetAPIKeys() ([]APIKey, error) {
+	var keys []APIKey
+	err := r.db.Order("created_at DESC").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *authRepository) RevokeAPIKey(id string, revokedAt time.Time) error {
+	result := r.db.Model(&APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *authRepository) TouchAPIKey(id string, usedAt time.Time) error {
+	return r.db.Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
+
+func (r *authRepository) CreateImpersonationLog(log *ImpersonationLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *authRepository) GetImpersonationLogs(limit int) ([]ImpersonationLog, error) {
+	var logs []ImpersonationLog
+	err := r.db.Order("created_at DESC").Limit(limit).Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
@@ -1,56 +1,417 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"pwa-rsbw/internal/config"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthService interface {
-	Login(idUser, password string) (*LoginResponse, error)
+	Login(idUser, password string, client ClientInfo) (*LoginResponse, error)
+	Refresh(refreshToken string, client ClientInfo) (*LoginResponse, error)
+	Logout(claims *JWTClaims) error
 	ValidateToken(tokenString string) (*JWTClaims, error)
+	JWKS() JWKSet
+
+	// TOTP / MFA
+	EnrollMFA(idUser string) (*MFAEnrollResponse, error)
+	ConfirmMFA(idUser, code string) ([]string, error)
+	DisableMFA(idUser, code string) error
+	VerifyMFA(mfaToken, code, recoveryCode string, client ClientInfo) (*LoginResponse, error)
+
+	// Sesi per perangkat
+	ListSessions(idUser, currentSessionID string) ([]Session, error)
+	RevokeSession(idUser, sessionID string) error
+	RevokeAllSessions(idUser, keepSessionID string) (int, error)
+
+	// Quick-unlock PIN (PWA terpasang)
+	RegisterPIN(claims *JWTClaims, pin string, client ClientInfo) (*PINRegisterResponse, error)
+	UnlockWithPIN(req PINUnlockRequest, client ClientInfo) (*LoginResponse, error)
+	RemovePIN(idUser string, client ClientInfo) error
+
+	// SSO OpenID Connect
+	StartOIDCLogin() (*OIDCLoginResponse, error)
+	CompleteOIDCLogin(code, state, boundState string, client ClientInfo) (*LoginResponse, error)
+	GetOIDCIdentities() ([]OIDCIdentity, error)
+	LinkOIDCIdentity(subject, kdDokter string) error
+	UnlinkOIDCIdentity(subject string) error
+
+	// API key service account
+	CreateAPIKey(req APIKeyCreateRequest, createdBy string) (*APIKeyCreateResponse, error)
+	ListAPIKeys() ([]APIKey, error)
+	RevokeAPIKey(id string) error
+	ValidateAPIKey(rawKey string) (*APIKey, error)
+
+	// Impersonasi admin (read-only)
+	Impersonate(admin *JWTClaims, kdDokter, alasan string, client ClientInfo) (*LoginResponse, error)
+	GetImpersonationLogs(limit int) ([]ImpersonationLog, error)
+
+	// Admin: kelola lockout login
+	UnlockLogin(idUser, clientIP string) error
+	GetLoginLockouts() ([]LoginAttempt, error)
 }
 
 type authService struct {
-	authRepo  AuthRepository
-	jwtSecret []byte
+	authRepo   AuthRepository
+	verifier   CredentialVerifier
+	keys       *KeyManager
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	adminUsers map[string]bool
+	guard      *loginGuard
+	mfaIssuer  string
+	pinMaxTry  int
+	oidc       *oidcProvider // nil jika SSO tidak dikonfigurasi
 }
 
-func NewAuthService(authRepo AuthRepository, jwtSecret string) AuthService {
+func NewAuthService(authRepo AuthRepository, verifier CredentialVerifier, keys *KeyManager, cfg *config.Config) AuthService {
+	admins := make(map[string]bool, len(cfg.AdminUsers))
+	for _, id := range cfg.AdminUsers {
+		admins[id] = true
+	}
+
 	return &authService{
-		authRepo:  authRepo,
-		jwtSecret: []byte(jwtSecret),
+		authRepo:   authRepo,
+		verifier:   verifier,
+		keys:       keys,
+		accessTTL:  cfg.JWTAccessTTL,
+		refreshTTL: cfg.JWTRefreshTTL,
+		adminUsers: admins,
+		guard: &loginGuard{
+			repo:          authRepo,
+			maxAttempts:   cfg.LoginMaxAttempts,
+			ipMaxAttempts: cfg.LoginIPMaxAttempts,
+			lockout:       cfg.LoginLockoutDuration,
+		},
+		mfaIssuer: cfg.MFAIssuer,
+		pinMaxTry: cfg.PINMaxAttempts,
+		oidc:      newOIDCProvider(cfg),
 	}
 }
 
-func (s *authService) Login(idUser, password string) (*LoginResponse, error) {
-	fmt.Printf("🔍 Login attempt - ID: %s\n", idUser)
+func (s *authService) Login(idUser, password string, client ClientInfo) (*LoginResponse, error) {
+	fmt.Printf("🔍 Login attempt - ID: %s, IP: %s\n", idUser, client.IP)
+
+	// ✅ Tolak dulu jika akun/IP sedang dikunci (sebelum password dicek)
+	if err := s.guard.check(idUser, client.IP); err != nil {
+		fmt.Printf("🔒 Login throttled for %s (%s): %v\n", idUser, client.IP, err)
+		return nil, err
+	}
 
 	// ✅ Get user dengan kode dokter DAN nama dokter
-	user, err := s.authRepo.GetUserByCredentials(idUser, password)
+	user, err := s.verifier.Verify(idUser, password)
 	if err != nil {
 		fmt.Printf("❌ Login failed: %v\n", err)
+		if lockErr := s.guard.recordFailure(idUser, client.IP); lockErr != nil {
+			return nil, lockErr
+		}
 		return nil, errors.New("invalid credentials")
 	}
+	s.guard.recordSuccess(idUser)
 
 	fmt.Printf("✅ Login successful for user: %s, Dokter: %s - %s\n", idUser, user.KodeDokter, user.NamaDokter)
 
+	// ✅ User dengan TOTP aktif harus verifikasi kode dulu di /auth/mfa/verify
+	mfaEnabled, err := s.isMFAEnabled(idUser)
+	if err != nil {
+		fmt.Printf("❌ Failed to check MFA status: %v\n", err)
+		return nil, errors.New("failed to generate token")
+	}
+	if mfaEnabled {
+		return s.issueMFAChallenge(idUser, user.Source)
+	}
+
+	return s.completeLogin(idUser, user, client)
+}
+
+// completeLogin menerbitkan sesi baru (access + refresh token) untuk user yang sudah terverifikasi
+func (s *authService) completeLogin(idUser string, user *User, client ClientInfo) (*LoginResponse, error) {
+	// ✅ Resolve role & permission (dokter, petugas, kolom hak akses Khanza)
+	s.resolveAccess(idUser, user)
+
+	// ✅ Sesi baru = family refresh token baru
+	familyID, err := generateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	if err := s.createSession(familyID, idUser, user.Source, client); err != nil {
+		fmt.Printf("❌ Failed to create session: %v\n", err)
+		return nil, errors.New("failed to generate token")
+	}
+
+	refreshToken, refreshExpiresAt, err := s.issueRefreshToken(idUser, user, familyID, nil)
+	if err != nil {
+		fmt.Printf("❌ Failed to store refresh token: %v\n", err)
+		return nil, errors.New("failed to generate token")
+	}
+
+	return s.buildLoginResponse(idUser, user, familyID, refreshToken, refreshExpiresAt)
+}
+
+// ✅ Tukar refresh token dengan pasangan token baru (refresh token lama langsung tidak berlaku)
+func (s *authService) Refresh(refreshToken string, client ClientInfo) (*LoginResponse, error) {
+	stored, err := s.authRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	// Token yang sudah dirotasi dipakai lagi = kemungkinan dicuri, matikan seluruh sesi
+	if stored.RevokedAt != nil {
+		fmt.Printf("⚠️ Refresh token reuse detected for user %s (family %s), revoking session\n", stored.IDUser, stored.FamilyID)
+		if err := s.authRepo.RevokeRefreshFamily(stored.FamilyID); err != nil {
+			fmt.Printf("❌ Failed to revoke session: %v\n", err)
+		}
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, errors.New("refresh token expired")
+	}
+
+	// ✅ Ambil ulang data user agar perubahan role/hak akses ikut terbawa
+	user, err := s.lookupUser(stored.Source, stored.IDUser)
+	if err != nil {
+		fmt.Printf("❌ User %s no longer exists: %v\n", stored.IDUser, err)
+		return nil, errors.New("invalid refresh token")
+	}
+	s.resolveAccess(stored.IDUser, user)
+
+	newRefreshToken, refreshExpiresAt, err := s.issueRefreshToken(stored.IDUser, user, stored.FamilyID, stored)
+	if err != nil {
+		fmt.Printf("❌ Failed to rotate refresh token: %v\n", err)
+		return nil, errors.New("invalid refresh token")
+	}
+
+	// Sesi diperpanjang sesuai masa berlaku refresh token yang baru
+	if err := s.authRepo.TouchSession(stored.FamilyID, time.Now(), client.IP, &refreshExpiresAt); err != nil {
+		fmt.Printf("⚠️ Failed to update session: %v\n", err)
+	}
+
+	fmt.Printf("✅ Token refreshed for user: %s\n", stored.IDUser)
+	return s.buildLoginResponse(stored.IDUser, user, stored.FamilyID, newRefreshToken, refreshExpiresAt)
+}
+
+// ✅ Logout: access token saat ini (jti) dan seluruh refresh token di sesi ini dicabut
+func (s *authService) Logout(claims *JWTClaims) error {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.authRepo.RevokeAccessToken(claims.ID, claims.IDUser, claims.ExpiresAt.Time); err != nil {
+			fmt.Printf("❌ Failed to revoke access token: %v\n", err)
+			return errors.New("failed to logout")
+		}
+	}
+
+	if claims.SessionID != "" {
+		if err := s.authRepo.RevokeSessions(claims.IDUser, []string{claims.SessionID}); err != nil {
+			fmt.Printf("❌ Failed to revoke session: %v\n", err)
+			return errors.New("failed to logout")
+		}
+	}
+
+	if err := s.authRepo.DeleteExpiredTokens(); err != nil {
+		fmt.Printf("⚠️ Failed to clean up expired tokens: %v\n", err)
+	}
+
+	fmt.Printf("✅ Logout successful for user: %s\n", claims.IDUser)
+	return nil
+}
+
+func (s *authService) UnlockLogin(idUser, clientIP string) error {
+	if idUser == "" && clientIP == "" {
+		return errors.New("id_user or ip is required")
+	}
+
+	if err := s.guard.unlock(idUser, clientIP); err != nil {
+		fmt.Printf("❌ Failed to unlock login: %v\n", err)
+		return errors.New("failed to unlock")
+	}
+
+	fmt.Printf("🔓 Login unlocked - ID: %s, IP: %s\n", idUser, clientIP)
+	return nil
+}
+
+func (s *authService) GetLoginLockouts() ([]LoginAttempt, error) {
+	return s.authRepo.GetLockedLoginAttempts(time.Now())
+}
+
+func (s *authService) ValidateToken(tokenString string) (*JWTClaims, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// ✅ Challenge MFA (typ "mfa") tidak boleh dipakai sebagai access token
+	if claims.TokenType != tokenTypeAccess {
+		return nil, errors.New("invalid token")
+	}
+
+	// ✅ Sesi perangkat yang sudah dicabut tidak boleh dipakai lagi
+	if claims.SessionID != "" {
+		if err := s.checkSession(claims.SessionID); err != nil {
+			return nil, errors.New("invalid token")
+		}
+	}
+
+	return claims, nil
+}
+
+// JWKS: public key untuk layanan lain yang ingin memverifikasi token kita
+func (s *authService) JWKS() JWKSet {
+	return s.keys.JWKS()
+}
+
+// parseToken memvalidasi signature, masa berlaku, dan status revoke (tanpa cek jenis token)
+func (s *authService) parseToken(tokenString string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keys.Keyfunc, jwt.WithValidMethods(s.keys.ValidMethods()))
+
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	// ✅ Token yang sudah di-logout tidak boleh dipakai lagi
+	if claims.ID != "" {
+		revoked, err := s.authRepo.IsAccessTokenRevoked(claims.ID)
+		if err != nil || revoked {
+			return nil, errors.New("invalid token")
+		}
+	}
+
+	return claims, nil
+}
+
+// lookupUser mengambil ulang user dari sumber yang menerbitkan sesi (bukan sumber lain
+// yang kebetulan punya id_user sama)
+func (s *authService) lookupUser(source, idUser string) (*User, error) {
+	if source == sourceOIDC {
+		user, err := s.lookupDokterUser(idUser)
+		if err != nil {
+			return nil, err
+		}
+		user.Source = sourceOIDC
+		return user, nil
+	}
+	return s.verifier.LookupUser(source, idUser)
+}
+
+// resolveAccess mengisi user.Roles dan user.Permissions.
+// Kegagalan query role tidak menggagalkan login, user hanya mendapat role yang berhasil di-resolve.
+// Role petugas dan kolom hak akses Khanza hanya untuk akun yang berasal dari Khanza.
+func (s *authService) resolveAccess(idUser string, user *User) {
+	var roles []string
+
+	if user.KodeDokter != "" {
+		roles = append(roles, RoleDokter)
+	}
+
+	khanzaAccount := user.Source == sourceKhanza || user.Source == sourceOIDC
+
+	var nmJbtn string
+	var isPetugas bool
+	if khanzaAccount {
+		var err error
+		nmJbtn, isPetugas, err = s.authRepo.GetJabatanPetugas(idUser)
+		if err != nil {
+			fmt.Printf("⚠️ Failed to resolve petugas role for %s: %v\n", idUser, err)
+		}
+	}
+	if isPetugas {
+		roles = append(roles, RolePetugas)
+
+		jabatan := strings.ToLower(nmJbtn)
+		if strings.Contains(jabatan, "kepala ruang") || strings.Contains(jabatan, "karu") {
+			roles = append(roles, RoleKepalaRuang)
+		}
+		if strings.Contains(jabatan, "komite medik") {
+			roles = append(roles, RoleKomiteMedik)
+		}
+	}
+
+	if s.adminUsers[idUser] {
+		roles = append(roles, RoleAdmin)
+	}
+
+	var khanzaPerms []string
+	if khanzaAccount {
+		flags, err := s.authRepo.GetKhanzaPermissionFlags(idUser)
+		if err != nil {
+			fmt.Printf("⚠️ Failed to read Khanza permissions for %s: %v\n", idUser, err)
+		}
+		for column, perm := range khanzaPermissionColumns {
+			if flags[column] {
+				khanzaPerms = append(khanzaPerms, perm)
+			}
+		}
+	}
+
+	user.Roles = roles
+	user.Permissions = resolvePermissions(roles, khanzaPerms)
+	fmt.Printf("✅ Access resolved for %s: roles=%v\n", idUser, roles)
+}
+
+// issueRefreshToken membuat refresh token baru. Jika previous diisi, token lama dirotasi.
+func (s *authService) issueRefreshToken(idUser string, user *User, familyID string, previous *RefreshToken) (string, time.Time, error) {
+	rawToken, err := generateRandomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(s.refreshTTL)
+	record := &RefreshToken{
+		TokenHash:  hashToken(rawToken),
+		FamilyID:   familyID,
+		IDUser:     idUser,
+		Source:     user.Source,
+		KodeDokter: user.KodeDokter,
+		NamaDokter: user.NamaDokter,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+	}
+
+	if previous != nil {
+		err = s.authRepo.RotateRefreshToken(previous, record)
+	} else {
+		err = s.authRepo.CreateRefreshToken(record)
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return rawToken, expiresAt, nil
+}
+
+func (s *authService) buildLoginResponse(idUser string, user *User, familyID, refreshToken string, refreshExpiresAt time.Time) (*LoginResponse, error) {
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
 	// ✅ Generate JWT dengan kode dokter DAN nama dokter
-	expirationTime := time.Now().Add(30 * time.Minute)
+	expirationTime := time.Now().Add(s.accessTTL)
 	claims := &JWTClaims{
-		IDUser:     idUser,
-		KodeDokter: user.KodeDokter, // ✅ Include kode dokter di JWT
-		NamaDokter: user.NamaDokter, // ✅ TAMBAH: Include nama dokter di JWT
+		IDUser:      idUser,
+		KodeDokter:  user.KodeDokter, // ✅ Include kode dokter di JWT
+		NamaDokter:  user.NamaDokter, // ✅ TAMBAH: Include nama dokter di JWT
+		SessionID:   familyID,
+		Roles:       user.Roles,
+		Permissions: user.Permissions,
+		TokenType:   tokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.jwtSecret)
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
 		fmt.Printf("❌ Failed to generate token: %v\n", err)
 		return nil, errors.New("failed to generate token")
@@ -58,23 +419,29 @@ func (s *authService) Login(idUser, password string) (*LoginResponse, error) {
 
 	fmt.Printf("✅ JWT token generated successfully\n")
 	return &LoginResponse{
-		Token:      tokenString,
-		IDUser:     idUser,
-		KodeDokter: user.KodeDokter, // ✅ Include di response
-		NamaDokter: user.NamaDokter, // ✅ TAMBAH: Include nama dokter di response
-		ExpiresAt:  expirationTime.Unix(),
+		Token:            tokenString,
+		RefreshToken:     refreshToken,
+		IDUser:           idUser,
+		KodeDokter:       user.KodeDokter, // ✅ Include di response
+		NamaDokter:       user.NamaDokter, // ✅ TAMBAH: Include nama dokter di response
+		Roles:            user.Roles,
+		Permissions:      user.Permissions,
+		ExpiresAt:        expirationTime.Unix(),
+		RefreshExpiresAt: refreshExpiresAt.Unix(),
 	}, nil
 }
 
-func (s *authService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	claims := &JWTClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return s.jwtSecret, nil
-	})
-
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid token")
+// generateRandomToken menghasilkan string acak base64url dari n byte
+func generateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	return claims, nil
+// hashToken: hanya hash SHA-256 yang disimpan di database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
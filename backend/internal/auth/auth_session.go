@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/textutil"
+	"time"
+)
+
+// last_seen tidak di-update di setiap request, cukup sekali per interval ini
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func (s *authService) createSession(sessionID, idUser, source string, client ClientInfo) error {
+	now := time.Now()
+	return s.authRepo.CreateSession(&Session{
+		ID:         sessionID,
+		IDUser:     idUser,
+		Source:     source,
+		DeviceID:   textutil.Truncate(client.DeviceID, 64),
+		UserAgent:  textutil.Truncate(client.UserAgent, 255),
+		IPAddress:  client.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.refreshTTL),
+	})
+}
+
+// checkSession dipanggil dari ValidateToken: sesi harus ada dan belum dicabut
+func (s *authService) checkSession(sessionID string) error {
+	session, err := s.authRepo.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		return errors.New("session revoked")
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.authRepo.TouchSession(sessionID, time.Now(), "", nil); err != nil {
+			fmt.Printf("⚠️ Failed to update session last seen: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// ✅ ListSessions: sesi aktif milik user, sesi yang sedang dipakai ditandai current
+func (s *authService) ListSessions(idUser, currentSessionID string) ([]Session, error) {
+	sessions, err := s.authRepo.GetActiveSessions(idUser, time.Now())
+	if err != nil {
+		fmt.Printf("❌ Failed to get sessions: %v\n", err)
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// ✅ RevokeSession: cabut satu sesi milik user (misal HP yang hilang)
+func (s *authService) RevokeSession(idUser, sessionID string) error {
+	session, err := s.authRepo.GetSession(sessionID)
+	if err != nil || session.IDUser != idUser {
+		return ErrSessionNotFound
+	}
+
+	if err := s.authRepo.RevokeSessions(idUser, []string{sessionID}); err != nil {
+		fmt.Printf("❌ Failed to revoke session: %v\n", err)
+		return errors.New("failed to revoke session")
+	}
+
+	fmt.Printf("✅ Session revoked for user %s: %s\n", idUser, sessionID)
+	return nil
+}
+
+// ✅ RevokeAllSessions: cabut semua sesi user, opsional kecuali sesi yang sedang dipakai
+func (s *authService) RevokeAllSessions(idUser, keepSessionID string) (int, error) {
+	sessions, err := s.authRepo.GetActiveSessions(idUser, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	for _, session := range sessions {
+		if session.ID != keepSessionID {
+			ids = append(ids, session.ID)
+		}
+	}
+
+	if err := s.authRepo.RevokeSessions(idUser, ids); err != nil {
+		fmt.Printf("❌ Failed to revoke sessions: %v\n", err)
+		return 0, errors.New("failed to revoke sessions")
+	}
+
+	fmt.Printf("✅ %d sessions revoked for user %s\n", len(ids), idUser)
+	return len(ids), nil
+}
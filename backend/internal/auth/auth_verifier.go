@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/config"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Sumber akun, dicatat di User.Source, sesi, dan refresh token
+const (
+	sourceKhanza      = "khanza"
+	sourceLocal       = "local"
+	sourceLDAPStandIn = "ldap_standin"
+	sourceOIDC        = "oidc" // SSO, user tetap dokter Khanza (id_user = kd_dokter)
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// CredentialVerifier memeriksa id_user + password dari satu sumber akun.
+// Verify mengembalikan ErrInvalidCredentials jika akun tidak ada atau password salah.
+// LookupUser dipakai saat refresh token, PIN, dan MFA (tanpa password); source adalah Name()
+// verifier yang memverifikasi login awal, sehingga id_user yang sama di sumber lain tidak ikut cocok.
+type CredentialVerifier interface {
+	Name() string
+	Verify(idUser, password string) (*User, error)
+	LookupUser(source, idUser string) (*User, error)
+}
+
+// NewCredentialVerifier merakit verifier sesuai urutan CREDENTIAL_VERIFIERS
+func NewCredentialVerifier(cfg *config.Config, authRepo AuthRepository) CredentialVerifier {
+	var verifiers []CredentialVerifier
+
+	for _, name := range cfg.CredentialVerifiers {
+		switch name {
+		case sourceKhanza:
+			verifiers = append(verifiers, &khanzaVerifier{authRepo: authRepo})
+		case sourceLocal:
+			verifiers = append(verifiers, &localVerifier{authRepo: authRepo})
+		case sourceLDAPStandIn:
+			// Password plaintext di env: harus diaktifkan eksplisit dan tidak pernah di production
+			if !cfg.LDAPStandInEnabled || cfg.Environment == "production" {
+				fmt.Printf("⚠️ ldap_standin verifier ignored (set LDAP_STANDIN_ENABLED=true outside production)\n")
+				continue
+			}
+			verifiers = append(verifiers, newLDAPStandInVerifier(cfg.LDAPStandInUsers, authRepo))
+		default:
+			fmt.Printf("⚠️ Unknown credential verifier: %s\n", name)
+		}
+	}
+
+	fmt.Printf("✅ Credential verifiers: %v\n", cfg.CredentialVerifiers)
+	return &chainVerifier{verifiers: verifiers, authRepo: authRepo}
+}
+
+// chainVerifier mencoba verifier satu per satu, yang pertama berhasil dipakai
+type chainVerifier struct {
+	verifiers []CredentialVerifier
+	authRepo  AuthRepository
+}
+
+func (v *chainVerifier) Name() string {
+	return "chain"
+}
+
+func (v *chainVerifier) Verify(idUser, password string) (*User, error) {
+	for _, verifier := range v.verifiers {
+		user, err := verifier.Verify(idUser, password)
+		if err == nil {
+			if err := v.checkCollision(verifier.Name(), idUser); err != nil {
+				fmt.Printf("⚠️ Verifier %s rejected: %v\n", verifier.Name(), err)
+				continue
+			}
+			fmt.Printf("✅ Credentials verified by %s\n", verifier.Name())
+			user.Source = verifier.Name()
+			return user, nil
+		}
+		if !errors.Is(err, ErrInvalidCredentials) {
+			fmt.Printf("⚠️ Verifier %s error: %v\n", verifier.Name(), err)
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// LookupUser hanya bertanya ke verifier yang menerbitkan sesi
+func (v *chainVerifier) LookupUser(source, idUser string) (*User, error) {
+	for _, verifier := range v.verifiers {
+		if verifier.Name() != source {
+			continue
+		}
+		user, err := verifier.LookupUser(source, idUser)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.checkCollision(source, idUser); err != nil {
+			return nil, err
+		}
+		user.Source = source
+		return user, nil
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// checkCollision menolak akun non-Khanza yang id_user-nya juga ada di tabel user Khanza.
+// Role petugas, ADMIN_USERS, dan kolom hak akses Khanza di-resolve dari id_user, sehingga
+// akun lokal dengan id yang sama akan ikut mewarisi hak akses user Khanza tersebut.
+func (v *chainVerifier) checkCollision(source, idUser string) error {
+	if source == sourceKhanza {
+		return nil
+	}
+
+	_, err := v.authRepo.GetUserByID(idUser)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("id_user %s from %s collides with a Khanza account", idUser, source)
+}
+
+// khanzaVerifier: tabel user SIMRS Khanza (AES_DECRYPT dengan kunci dari config)
+type khanzaVerifier struct {
+	authRepo AuthRepository
+}
+
+func (v *khanzaVerifier) Name() string {
+	return sourceKhanza
+}
+
+func (v *khanzaVerifier) Verify(idUser, password string) (*User, error) {
+	user, err := v.authRepo.GetUserByCredentials(idUser, password)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (v *khanzaVerifier) LookupUser(source, idUser string) (*User, error) {
+	if source != sourceKhanza {
+		return nil, ErrInvalidCredentials
+	}
+	return v.authRepo.GetUserByID(idUser)
+}
+
+// localVerifier: akun milik aplikasi di tabel pwa_local_account (bcrypt)
+type localVerifier struct {
+	authRepo AuthRepository
+}
+
+func (v *localVerifier) Name() string {
+	return sourceLocal
+}
+
+func (v *localVerifier) Verify(idUser, password string) (*User, error) {
+	account, err := v.authRepo.GetLocalAccount(idUser)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return v.toUser(account)
+}
+
+func (v *localVerifier) LookupUser(source, idUser string) (*User, error) {
+	if source != sourceLocal {
+		return nil, ErrInvalidCredentials
+	}
+	account, err := v.authRepo.GetLocalAccount(idUser)
+	if err != nil {
+		return nil, err
+	}
+	return v.toUser(account)
+}
+
+func (v *localVerifier) toUser(account *LocalAccount) (*User, error) {
+	user := &User{IDUser: account.IDUser, KodeDokter: account.KodeDokter}
+	if account.KodeDokter != "" {
+		nmDokter, err := v.authRepo.GetNamaDokter(account.KodeDokter)
+		if err != nil {
+			return nil, err
+		}
+		user.NamaDokter = nmDokter
+	}
+	return user, nil
+}
+
+// ldapStandInVerifier: pengganti sementara LDAP untuk development/testing.
+// Akun dibaca dari LDAP_STANDIN_USERS ("id_user:password[:kd_dokter]"), hanya aktif jika
+// LDAP_STANDIN_ENABLED=true dan tidak pernah di production.
+type ldapStandInVerifier struct {
+	authRepo  AuthRepository
+	passwords map[string]string
+	kdDokter  map[string]string
+}
+
+func newLDAPStandInVerifier(entries []string, authRepo AuthRepository) *ldapStandInVerifier {
+	v := &ldapStandInVerifier{
+		authRepo:  authRepo,
+		passwords: make(map[string]string),
+		kdDokter:  make(map[string]string),
+	}
+
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 {
+			fmt.Printf("⚠️ Invalid LDAP_STANDIN_USERS entry ignored\n")
+			continue
+		}
+		v.passwords[parts[0]] = parts[1]
+		if len(parts) == 3 {
+			v.kdDokter[parts[0]] = parts[2]
+		}
+	}
+	return v
+}
+
+func (v *ldapStandInVerifier) Name() string {
+	return sourceLDAPStandIn
+}
+
+func (v *ldapStandInVerifier) Verify(idUser, password string) (*User, error) {
+	expected, ok := v.passwords[idUser]
+	if !ok || expected != password {
+		return nil, ErrInvalidCredentials
+	}
+	return v.LookupUser(sourceLDAPStandIn, idUser)
+}
+
+func (v *ldapStandInVerifier) LookupUser(source, idUser string) (*User, error) {
+	if _, ok := v.passwords[idUser]; !ok || source != sourceLDAPStandIn {
+		return nil, ErrInvalidCredentials
+	}
+
+	user := &User{IDUser: idUser, KodeDokter: v.kdDokter[idUser]}
+	if user.KodeDokter != "" {
+		nmDokter, err := v.authRepo.GetNamaDokter(user.KodeDokter)
+		if err != nil {
+			return nil, err
+		}
+		user.NamaDokter = nmDokter
+	}
+	return user, nil
+}
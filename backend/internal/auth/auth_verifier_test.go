@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"pwa-rsbw/internal/config"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestVerifier(t *testing.T, cfg *config.Config) (*chainVerifier, *fakeAuthRepo) {
+	t.Helper()
+
+	repo := newFakeAuthRepo()
+	repo.khanzaUsers["dr01"] = &User{IDUser: "dr01", Password: "khanza-pass", KodeDokter: "dr01"}
+	repo.khanzaUsers["perawat01"] = &User{IDUser: "perawat01", Password: "khanza-pass"}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("local-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.localAccounts["it01"] = &LocalAccount{IDUser: "it01", PasswordHash: string(hash), Active: true}
+	repo.localAccounts["perawat01"] = &LocalAccount{IDUser: "perawat01", PasswordHash: string(hash), Active: true}
+
+	return NewCredentialVerifier(cfg, repo).(*chainVerifier), repo
+}
+
+func TestChainVerifierVerify(t *testing.T) {
+	verifier, _ := newTestVerifier(t, &config.Config{
+		CredentialVerifiers: []string{sourceKhanza, sourceLocal},
+	})
+
+	tests := []struct {
+		name       string
+		idUser     string
+		password   string
+		wantSource string
+		wantErr    bool
+	}{
+		{"khanza account", "dr01", "khanza-pass", sourceKhanza, false},
+		{"local account", "it01", "local-pass", sourceLocal, false},
+		{"wrong password", "it01", "khanza-pass", "", true},
+		{"unknown account", "nobody", "local-pass", "", true},
+		// Akun lokal dengan id_user milik user Khanza tidak boleh mewarisi hak akses Khanza
+		{"local account colliding with khanza", "perawat01", "local-pass", "", true},
+		{"khanza password on colliding id", "perawat01", "khanza-pass", sourceKhanza, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := verifier.Verify(tt.idUser, tt.password)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("Verify() = %+v, %v, want ErrInvalidCredentials", user, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if user.Source != tt.wantSource {
+				t.Errorf("Verify() source = %q, want %q", user.Source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestChainVerifierLookupUserUsesSource(t *testing.T) {
+	verifier, _ := newTestVerifier(t, &config.Config{
+		CredentialVerifiers: []string{sourceKhanza, sourceLocal},
+	})
+
+	tests := []struct {
+		name    string
+		source  string
+		idUser  string
+		wantErr bool
+	}{
+		{"khanza session", sourceKhanza, "dr01", false},
+		{"local session", sourceLocal, "it01", false},
+		{"local session cannot resolve khanza user", sourceLocal, "dr01", true},
+		{"khanza session cannot resolve local user", sourceKhanza, "it01", true},
+		{"colliding local account", sourceLocal, "perawat01", true},
+		{"unrecorded source", "", "dr01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := verifier.LookupUser(tt.source, tt.idUser)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LookupUser(%q, %q) = %+v, %v, wantErr %v", tt.source, tt.idUser, user, err, tt.wantErr)
+			}
+			if err == nil && user.Source != tt.source {
+				t.Errorf("LookupUser() source = %q, want %q", user.Source, tt.source)
+			}
+		})
+	}
+}
+
+func TestLDAPStandInIsOptIn(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		environment string
+		want        bool
+	}{
+		{"default development", false, "development", false},
+		{"enabled in development", true, "development", true},
+		{"enabled in production", true, "production", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, _ := newTestVerifier(t, &config.Config{
+				CredentialVerifiers: []string{sourceLDAPStandIn},
+				LDAPStandInEnabled:  tt.enabled,
+				LDAPStandInUsers:    []string{"tester:secret"},
+				Environment:         tt.environment,
+			})
+
+			_, err := verifier.Verify("tester", "secret")
+			if got := err == nil; got != tt.want {
+				t.Errorf("ldap_standin login allowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
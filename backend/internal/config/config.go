@@ -1,12 +1,19 @@
 package config
 
 import (
+	"errors"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// insecureJWTSecret: default lama JWT_SECRET yang tersebar di contoh konfigurasi, tidak boleh dipakai
+const insecureJWTSecret = "your-super-secret-jwt-key"
+
 type Config struct {
 	// Database Config
 	DBHost     string
@@ -17,10 +24,60 @@ type Config struct {
 	DBDSN      string
 
 	// Server Config
-	ServerPort string
+	ServerPort     string
+	TrustedProxies []string // IP/CIDR reverse proxy yang boleh mengisi X-Forwarded-For (kosong = tidak ada)
 
 	// JWT Config
-	JWTSecret string
+	JWTSecret     string
+	JWTAccessTTL  time.Duration // Masa berlaku access token
+	JWTRefreshTTL time.Duration // Masa berlaku refresh token
+
+	JWTAlgorithm      string        // HS256 (secret), RS256, atau EdDSA
+	JWTKeyRotation    time.Duration // Interval rotasi signing key (RS256/EdDSA)
+	JWTKeyGracePeriod time.Duration // Key lama masih diterima untuk verifikasi selama ini
+
+	// Credential Config
+	KhanzaAESKeyUser     string   // Kunci AES_DECRYPT untuk user.id_user
+	KhanzaAESKeyPassword string   // Kunci AES_DECRYPT untuk user.password
+	CredentialVerifiers  []string // Urutan verifier: khanza, local, ldap_standin
+	LDAPStandInEnabled   bool     // Opt-in eksplisit untuk verifier ldap_standin (diabaikan di production)
+	LDAPStandInUsers     []string // Hanya untuk development: "id_user:password[:kd_dokter]"
+
+	// MFA Config
+	MFAIssuer string // Nama issuer yang tampil di aplikasi authenticator
+
+	// OIDC SSO Config (kosongkan OIDCIssuer untuk menonaktifkan)
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string   // Opsional, client publik cukup dengan PKCE
+	OIDCRedirectURL   string   // URL callback di frontend
+	OIDCScopes        []string // Default: openid, profile
+	OIDCKdDokterClaim string   // Claim id_token berisi kd_dokter untuk auto-link (opsional)
+	OIDCMFAACR        []string // Nilai acr yang dianggap sudah MFA di IdP (selain amr "mfa"/"otp"/"hwk")
+
+	// Akses darurat (break-the-glass)
+	EmergencyAccessTTL time.Duration
+
+	// CPPT
+	CpptEditWindow time.Duration // Batas waktu penulis boleh mengoreksi CPPT
+
+	// Radiologi
+	RadiologiStoragePath string        // Root folder gambar_radiologi.lokasi_gambar (kosong = gambar tidak ditampilkan)
+	RadiologiURLSecret   string        // Kunci HMAC URL gambar, terpisah dari JWT_SECRET (wajib jika storage path diisi)
+	RadiologiURLTTL      time.Duration // Masa berlaku URL gambar
+
+	// E-resep
+	ResepKdDepo      string        // gudangbarang.kd_bangsal depo farmasi rawat inap untuk cek stok (kosong = semua gudang)
+	ObatIndexRefresh time.Duration // Interval muat ulang indeks pencarian obat
+
+	// RBAC Config
+	AdminUsers []string // id_user Khanza yang mendapat role admin
+
+	// Login Protection Config
+	LoginMaxAttempts     int           // Gagal berturut-turut per akun sebelum dikunci
+	LoginIPMaxAttempts   int           // Gagal berturut-turut per IP sebelum dikunci
+	LoginLockoutDuration time.Duration // Lama akun/IP dikunci
+	PINMaxAttempts       int           // Gagal PIN berturut-turut sebelum perangkat dihapus
 
 	// App Config
 	Environment string // development, production
@@ -48,10 +105,60 @@ func Load() *Config {
 		DBName:     getEnv("DB_NAME", "sik"),
 
 		// Server
-		ServerPort: getEnv("SERVER_PORT", "8080"),
+		ServerPort:     getEnv("SERVER_PORT", "8080"),
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
 
 		// JWT
-		JWTSecret: getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
+		JWTSecret:     getEnv("JWT_SECRET", ""),
+		JWTAccessTTL:  getEnvDuration("JWT_ACCESS_TTL", 30*time.Minute),
+		JWTRefreshTTL: getEnvDuration("JWT_REFRESH_TTL", 7*24*time.Hour),
+
+		JWTAlgorithm:      getEnv("JWT_ALGORITHM", "HS256"),
+		JWTKeyRotation:    getEnvDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTKeyGracePeriod: getEnvDuration("JWT_KEY_GRACE_PERIOD", 2*time.Hour),
+
+		// Credential
+		KhanzaAESKeyUser:     getEnv("KHANZA_AES_KEY_USER", "nur"),
+		KhanzaAESKeyPassword: getEnv("KHANZA_AES_KEY_PASSWORD", "windi"),
+		CredentialVerifiers:  getEnvList("CREDENTIAL_VERIFIERS"),
+		LDAPStandInEnabled:   getEnvBool("LDAP_STANDIN_ENABLED", false),
+		LDAPStandInUsers:     getEnvList("LDAP_STANDIN_USERS"),
+
+		// MFA
+		MFAIssuer: getEnv("MFA_ISSUER", "RS Bumi Waras"),
+
+		// OIDC SSO
+		OIDCIssuer:        getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:        getEnvList("OIDC_SCOPES"),
+		OIDCKdDokterClaim: getEnv("OIDC_KD_DOKTER_CLAIM", ""),
+		OIDCMFAACR:        getEnvList("OIDC_MFA_ACR"),
+
+		// Akses darurat
+		EmergencyAccessTTL: getEnvDuration("EMERGENCY_ACCESS_TTL", 4*time.Hour),
+
+		// CPPT
+		CpptEditWindow: getEnvDuration("CPPT_EDIT_WINDOW", 24*time.Hour),
+
+		// Radiologi
+		RadiologiStoragePath: getEnv("RADIOLOGI_STORAGE_PATH", ""),
+		RadiologiURLSecret:   getEnv("RADIOLOGI_URL_SECRET", ""),
+		RadiologiURLTTL:      getEnvDuration("RADIOLOGI_URL_TTL", 5*time.Minute),
+
+		// E-resep
+		ResepKdDepo:      getEnv("RESEP_KD_DEPO", ""),
+		ObatIndexRefresh: getEnvDuration("OBAT_INDEX_REFRESH", 15*time.Minute),
+
+		// RBAC
+		AdminUsers: getEnvList("ADMIN_USERS"),
+
+		// Login Protection
+		LoginMaxAttempts:     getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts:   getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginLockoutDuration: getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		PINMaxAttempts:       getEnvInt("PIN_MAX_ATTEMPTS", 5),
 
 		// Environment
 		Environment: getEnv("ENVIRONMENT", "development"),
@@ -69,6 +176,18 @@ func Load() *Config {
 		"@tcp(" + config.DBHost + ":" + config.DBPort + ")/" +
 		config.DBName + "?charset=utf8mb4&parseTime=True&loc=Local"
 
+	if err := config.validate(); err != nil {
+		log.Fatalf("❌ Konfigurasi tidak valid: %v", err)
+	}
+
+	if len(config.CredentialVerifiers) == 0 {
+		config.CredentialVerifiers = []string{"khanza"}
+	}
+
+	if len(config.OIDCScopes) == 0 {
+		config.OIDCScopes = []string{"openid", "profile"}
+	}
+
 	if config.OneSignalAppID == "" || config.OneSignalAPIKey == "" {
 		log.Println("⚠️ PERINGATAN: ONESIGNAL_APP_ID atau ONESIGNAL_API_KEY tidak diatur di .env. Notifikasi tidak akan berfungsi.")
 	}
@@ -76,9 +195,82 @@ func Load() *Config {
 	return config
 }
 
+// validate menolak konfigurasi yang membuat server berjalan dengan kunci yang bisa ditebak
+// atau membuat worker gagal saat berjalan
+func (c *Config) validate() error {
+	if c.JWTAlgorithm == "HS256" && (c.JWTSecret == "" || c.JWTSecret == insecureJWTSecret) {
+		return errors.New("JWT_SECRET wajib diatur (bukan nilai default) untuk JWT_ALGORITHM=HS256, atau gunakan JWT_ALGORITHM=RS256/EdDSA")
+	}
+	// Kunci acak per proses membuat link gambar putus setelah restart dan antar instance
+	if c.RadiologiStoragePath != "" && c.RadiologiURLSecret == "" {
+		return errors.New("RADIOLOGI_URL_SECRET wajib diatur jika RADIOLOGI_STORAGE_PATH diisi")
+	}
+	// Kunci URL gambar tidak boleh sama dengan kunci token: URL gambar tersebar di HTML/log
+	if c.RadiologiURLSecret != "" && (c.RadiologiURLSecret == insecureJWTSecret || c.RadiologiURLSecret == c.JWTSecret) {
+		return errors.New("RADIOLOGI_URL_SECRET harus kunci tersendiri, bukan JWT_SECRET atau nilai default")
+	}
+	// Dipakai time.NewTicker di worker indeks obat, yang panic untuk durasi <= 0
+	if c.ObatIndexRefresh <= 0 {
+		return errors.New("OBAT_INDEX_REFRESH harus lebih dari 0, mis. 15m")
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️ PERINGATAN: %s tidak valid (%q), memakai default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvBool membaca "true"/"false" (juga "1"/"0")
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️ PERINGATAN: %s tidak valid (%q), memakai default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getEnvDuration membaca durasi dengan format time.ParseDuration (misal "30m", "12h")
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️ PERINGATAN: %s tidak valid (%q), memakai default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvList membaca daftar yang dipisah koma (misal "admin,it01")
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
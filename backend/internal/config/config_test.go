@@ -0,0 +1,73 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateJWTSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		secret    string
+		wantErr   bool
+	}{
+		{"hs256 without secret", "HS256", "", true},
+		{"hs256 with old default secret", "HS256", insecureJWTSecret, true},
+		{"hs256 with configured secret", "HS256", "a-real-secret-from-the-environment", false},
+		{"rs256 without secret", "RS256", "", false},
+		{"eddsa without secret", "EdDSA", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{JWTAlgorithm: tt.algorithm, JWTSecret: tt.secret, ObatIndexRefresh: 15 * time.Minute}
+			if err := cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRadiologiURLSecret(t *testing.T) {
+	tests := []struct {
+		name        string
+		storagePath string
+		secret      string
+		wantErr     bool
+	}{
+		{"dedicated secret", "/data/radiologi", "radiologi-only-secret", false},
+		{"radiology disabled", "", "", false},
+		{"storage without secret", "/data/radiologi", "", true},
+		{"old default", "/data/radiologi", insecureJWTSecret, true},
+		{"same as JWT_SECRET", "/data/radiologi", "jwt-secret-from-the-environment", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{JWTAlgorithm: "HS256", JWTSecret: "jwt-secret-from-the-environment",
+				RadiologiStoragePath: tt.storagePath, RadiologiURLSecret: tt.secret, ObatIndexRefresh: 15 * time.Minute}
+			if err := cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateObatIndexRefresh(t *testing.T) {
+	tests := []struct {
+		refresh time.Duration
+		wantErr bool
+	}{
+		{15 * time.Minute, false},
+		{0, true},
+		{-time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{JWTAlgorithm: "RS256", ObatIndexRefresh: tt.refresh}
+		if err := cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate() with OBAT_INDEX_REFRESH=%s error = %v, wantErr %v", tt.refresh, err, tt.wantErr)
+		}
+	}
+}
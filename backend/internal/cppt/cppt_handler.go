@@ -0,0 +1,453 @@
+package cppt
+
+import (
+	"errors"
+	"net/http"
+	"pwa-rsbw/internal/listranap"
+	"pwa-rsbw/internal/textutil"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CpptHandler struct {
+	cpptService CpptService
+}
+
+func NewCpptHandler(cpptService CpptService) *CpptHandler {
+	return &CpptHandler{
+		cpptService: cpptService,
+	}
+}
+
+// ✅ Riwayat CPPT (SOAP lengkap) untuk satu pasien, filter penulis dan rentang tanggal
+func (h *CpptHandler) GetCpptHistory(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var filter CpptFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid query parameters",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response, err := h.cpptService.GetCpptHistory(noRawat, kdDokter, filter)
+	if errors.Is(err, listranap.ErrAksesDitolak) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get CPPT history",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ✅ Tulis CPPT (SOAP + tanda vital) dari PWA
+func (h *CpptHandler) CreateCppt(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var req CpptCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	entry, err := h.cpptService.CreateCppt(noRawat, kdDokter, c.GetString("nm_dokter"), req)
+	if errors.Is(err, ErrWriteDenied) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrValidation) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrDuplicateEntry) {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to save CPPT",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "CPPT saved",
+		"data":    entry,
+	})
+}
+
+// ✅ Koreksi CPPT oleh penulisnya (dalam batas waktu koreksi)
+func (h *CpptHandler) UpdateCppt(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var req CpptUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	entry, err := h.cpptService.UpdateCppt(c.Param("no_rawat"), c.Param("tgl_perawatan"), c.Param("jam_rawat"), kdDokter, req)
+	if err != nil {
+		respondEntryError(c, err, "Failed to edit CPPT")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "CPPT updated",
+		"data":    entry,
+	})
+}
+
+// ✅ Riwayat revisi satu entri CPPT
+func (h *CpptHandler) GetRevisions(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	revisions, err := h.cpptService.GetRevisions(c.Param("no_rawat"), c.Param("tgl_perawatan"), c.Param("jam_rawat"), kdDokter)
+	if err != nil {
+		respondEntryError(c, err, "Failed to get CPPT revisions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   revisions,
+	})
+}
+
+// ✅ Verifikasi DPJP atas catatan residen/perawat
+func (h *CpptHandler) VerifyCppt(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	// Body opsional, hanya berisi catatan
+	var req CpptVerifyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid request body",
+				"error":   err.Error(),
+			})
+			return
+		}
+	}
+
+	verification, err := h.cpptService.VerifyCppt(c.Param("no_rawat"), c.Param("tgl_perawatan"), c.Param("jam_rawat"), kdDokter, c.GetString("nm_dokter"), req)
+	if err != nil {
+		respondEntryError(c, err, "Failed to verify CPPT")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "CPPT verified",
+		"data":    verification,
+	})
+}
+
+// ✅ Sync draft CPPT offline dari service worker. Selalu 200 dengan hasil per item.
+func (h *CpptHandler) SyncDrafts(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var req CpptSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response := h.cpptService.SyncDrafts(kdDokter, c.GetString("nm_dokter"), req)
+
+	// Audit: pasien yang disentuh sync (route ini tidak punya :no_rawat)
+	pasien := make([]string, 0, len(req.Items))
+	for _, item := range req.Items {
+		pasien = append(pasien, item.NoRawat)
+	}
+	c.Set("audit_detail", textutil.Truncate("sync: "+strings.Join(pasien, ","), 500))
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ✅ Daftar template (milik sendiri + departemen) dan placeholder yang tersedia
+func (h *CpptHandler) ListTemplates(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var filter CpptTemplateFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid query parameters",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	templates, err := h.cpptService.ListTemplates(kdDokter, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get templates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "success",
+		"data":         templates,
+		"placeholders": TemplatePlaceholders(),
+	})
+}
+
+func (h *CpptHandler) CreateTemplate(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var req CpptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	template, err := h.cpptService.CreateTemplate(kdDokter, req)
+	if err != nil {
+		respondTemplateError(c, err, "Failed to create template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "Template created",
+		"data":    template,
+	})
+}
+
+func (h *CpptHandler) UpdateTemplate(c *gin.Context) {
+	id, ok := templateID(c)
+	if !ok {
+		return
+	}
+
+	var req CpptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	template, err := h.cpptService.UpdateTemplate(id, c.GetString("kd_dokter"), req)
+	if err != nil {
+		respondTemplateError(c, err, "Failed to update template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Template updated",
+		"data":    template,
+	})
+}
+
+func (h *CpptHandler) DeleteTemplate(c *gin.Context) {
+	id, ok := templateID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.cpptService.DeleteTemplate(id, c.GetString("kd_dokter")); err != nil {
+		respondTemplateError(c, err, "Failed to delete template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Template deleted",
+	})
+}
+
+// ✅ Expand template untuk satu pasien (placeholder diisi server)
+func (h *CpptHandler) ExpandTemplate(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+	id, ok := templateID(c)
+	if !ok {
+		return
+	}
+
+	expansion, err := h.cpptService.ExpandTemplate(id, c.Param("no_rawat"), kdDokter, c.GetString("nm_dokter"))
+	if err != nil {
+		respondTemplateError(c, err, "Failed to expand template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   expansion,
+	})
+}
+
+func templateID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("template_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid template id",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func respondTemplateError(c *gin.Context, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+
+	switch {
+	case errors.Is(err, ErrTemplateNotFound), errors.Is(err, ErrPasienNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, ErrValidation):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrTemplateForbidden), errors.Is(err, listranap.ErrAksesDitolak):
+		status, message = http.StatusForbidden, err.Error()
+	}
+
+	c.JSON(status, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// respondEntryError memetakan error edit/verifikasi ke HTTP status
+func respondEntryError(c *gin.Context, err error, fallback string) {
+	status := http.StatusInternalServerError
+	message := fallback
+
+	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, ErrValidation):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, ErrNotAuthor), errors.Is(err, ErrWriteDenied), errors.Is(err, listranap.ErrAksesDitolak),
+		errors.Is(err, ErrVerifyDenied), errors.Is(err, ErrVerifyOwnEntry):
+		status, message = http.StatusForbidden, err.Error()
+	case errors.Is(err, ErrEditWindowExpired), errors.Is(err, ErrAlreadyVerified):
+		status, message = http.StatusConflict, err.Error()
+	}
+
+	c.JSON(status, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
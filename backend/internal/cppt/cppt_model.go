@@ -0,0 +1,264 @@
+package cppt
+
+import "time"
+
+// Tanda vital yang dicatat bersama CPPT (kolom varchar di pemeriksaan_ranap Khanza)
+type CpptVitals struct {
+	SuhuTubuh string `json:"suhu_tubuh" gorm:"column:suhu_tubuh"`
+	Tensi     string `json:"tensi" gorm:"column:tensi"`
+	Nadi      string `json:"nadi" gorm:"column:nadi"`
+	Respirasi string `json:"respirasi" gorm:"column:respirasi"`
+	Tinggi    string `json:"tinggi" gorm:"column:tinggi"`
+	Berat     string `json:"berat" gorm:"column:berat"`
+	SpO2      string `json:"spo2" gorm:"column:spo2"`
+	GCS       string `json:"gcs" gorm:"column:gcs"`
+	Kesadaran string `json:"kesadaran" gorm:"column:kesadaran"`
+}
+
+// CpptEntry: satu catatan SOAP dari tabel pemeriksaan_ranap
+type CpptEntry struct {
+	NoRawat      string `json:"no_rawat" gorm:"column:no_rawat"`
+	TglPerawatan string `json:"tgl_perawatan" gorm:"column:tgl_perawatan"` // YYYY-MM-DD
+	JamRawat     string `json:"jam_rawat" gorm:"column:jam_rawat"`         // HH:MM:SS
+
+	// SOAP
+	Subjektif string `json:"subjektif" gorm:"column:keluhan"`    // S: keluhan
+	Objektif  string `json:"objektif" gorm:"column:pemeriksaan"` // O: pemeriksaan
+	Asesmen   string `json:"asesmen" gorm:"column:penilaian"`    // A: penilaian
+	Plan      string `json:"plan" gorm:"column:rtl"`             // P: rencana tindak lanjut
+	Instruksi string `json:"instruksi" gorm:"column:instruksi"`  // Instruksi untuk perawat
+	Evaluasi  string `json:"evaluasi" gorm:"column:evaluasi"`    // Evaluasi
+	Alergi    string `json:"alergi" gorm:"column:alergi"`
+
+	Vitals CpptVitals `json:"vitals" gorm:"embedded"`
+
+	// Penulis
+	Nip         string `json:"nip" gorm:"column:nip"`
+	NamaPenulis string `json:"nama_penulis" gorm:"column:nama_penulis"`
+	RolePenulis string `json:"role_penulis" gorm:"column:role_penulis"` // dokter, nama jabatan petugas, atau lainnya
+
+	Verifikasi *CpptVerification `json:"verifikasi,omitempty" gorm:"-"` // nil = belum diverifikasi DPJP
+	Versi      string            `json:"versi" gorm:"-"`                // Hash isi, dipakai deteksi konflik sync offline
+}
+
+// CpptCreateRequest: catatan SOAP baru. Waktu dan nip diisi server, bukan dari client.
+type CpptCreateRequest struct {
+	Subjektif string     `json:"subjektif"`
+	Objektif  string     `json:"objektif"`
+	Asesmen   string     `json:"asesmen" binding:"required"`
+	Plan      string     `json:"plan" binding:"required"`
+	Instruksi string     `json:"instruksi"`
+	Evaluasi  string     `json:"evaluasi"`
+	Alergi    string     `json:"alergi"`
+	Vitals    CpptVitals `json:"vitals"`
+}
+
+// CpptUpdateRequest: isi pengganti (bukan patch) untuk koreksi oleh penulis
+type CpptUpdateRequest struct {
+	CpptCreateRequest
+	Alasan string `json:"alasan"` // Opsional, mis. "salah ketik dosis"
+}
+
+type CpptVerifyRequest struct {
+	Catatan string `json:"catatan"`
+}
+
+// CpptRevision: isi CPPT sebelum dan sesudah setiap koreksi (append-only)
+type CpptRevision struct {
+	ID           uint64            `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	NoRawat      string            `json:"no_rawat" gorm:"column:no_rawat;size:17;index:idx_cppt_revision_entry"`
+	TglPerawatan string            `json:"tgl_perawatan" gorm:"column:tgl_perawatan;size:10;index:idx_cppt_revision_entry"`
+	JamRawat     string            `json:"jam_rawat" gorm:"column:jam_rawat;size:8;index:idx_cppt_revision_entry"`
+	Sebelum      CpptCreateRequest `json:"sebelum" gorm:"column:sebelum;type:text;serializer:json"`
+	Sesudah      CpptCreateRequest `json:"sesudah" gorm:"column:sesudah;type:text;serializer:json"`
+	Alasan       string            `json:"alasan,omitempty" gorm:"column:alasan;size:255"`
+	EditedBy     string            `json:"edited_by" gorm:"column:edited_by;size:20"`
+	EditedAt     time.Time         `json:"edited_at" gorm:"column:edited_at"`
+}
+
+func (CpptRevision) TableName() string {
+	return "pwa_cppt_revision"
+}
+
+// CpptVerification: verifikasi (tanda tangan) DPJP atas satu baris pemeriksaan_ranap
+type CpptVerification struct {
+	ID           uint64    `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	NoRawat      string    `json:"-" gorm:"column:no_rawat;size:17;uniqueIndex:idx_cppt_verification_entry"`
+	TglPerawatan string    `json:"-" gorm:"column:tgl_perawatan;size:10;uniqueIndex:idx_cppt_verification_entry"`
+	JamRawat     string    `json:"-" gorm:"column:jam_rawat;size:8;uniqueIndex:idx_cppt_verification_entry"`
+	KodeDokter   string    `json:"kd_dokter" gorm:"column:kd_dokter;size:20"`
+	NamaDokter   string    `json:"nm_dokter" gorm:"column:nm_dokter;size:100"`
+	Catatan      string    `json:"catatan,omitempty" gorm:"column:catatan;size:500"`
+	VerifiedAt   time.Time `json:"verified_at" gorm:"column:verified_at"`
+}
+
+func (CpptVerification) TableName() string {
+	return "pwa_cppt_verification"
+}
+
+// Operasi draft offline
+const (
+	SyncOperationCreate = "create"
+	SyncOperationUpdate = "update"
+)
+
+// Hasil per item sync
+const (
+	SyncStatusApplied  = "applied"
+	SyncStatusConflict = "conflict" // Perlu keputusan dokter, jangan dikirim ulang dengan key yang sama
+	SyncStatusRejected = "rejected" // Isi/akses tidak valid
+	SyncStatusError    = "error"    // Gagal sementara, boleh dikirim ulang dengan key yang sama
+	SyncStatusPending  = "pending"  // Klaim key selama draft diterapkan, tidak pernah dikirim ke client
+)
+
+// Alasan konflik
+const (
+	ConflictPatientDischarged = "patient_discharged"
+	ConflictChangedOnServer   = "changed_on_server"
+	ConflictVerified          = "verified" // Sudah diverifikasi DPJP, tidak bisa dikoreksi
+)
+
+// CpptSyncItem: satu draft CPPT yang ditulis saat offline.
+// Tidak memakai binding tag: item yang tidak valid dilaporkan per item, bukan menggagalkan batch.
+type CpptSyncItem struct {
+	DraftID         string    `json:"draft_id"`         // ID dari client (IndexedDB)
+	IdempotencyKey  string    `json:"idempotency_key"`  // Sama untuk setiap pengiriman ulang draft yang sama
+	ClientTimestamp time.Time `json:"client_timestamp"` // Waktu draft di perangkat, hanya dicatat di pwa_cppt_sync
+	Operation       string    `json:"operation"`        // create atau update
+	NoRawat         string    `json:"no_rawat"`
+
+	// Khusus update: entri yang dikoreksi dan versi yang dilihat client saat draft dibuat
+	TglPerawatan string `json:"tgl_perawatan"`
+	JamRawat     string `json:"jam_rawat"`
+	BaseVersi    string `json:"base_versi"`
+	Alasan       string `json:"alasan"`
+
+	CpptCreateRequest
+}
+
+type CpptSyncRequest struct {
+	Items []CpptSyncItem `json:"items" binding:"required,min=1,max=50"`
+}
+
+type CpptSyncResult struct {
+	DraftID        string     `json:"draft_id"`
+	IdempotencyKey string     `json:"idempotency_key"`
+	Status         string     `json:"status"`
+	Reason         string     `json:"reason,omitempty"`
+	Message        string     `json:"message,omitempty"`
+	Replayed       bool       `json:"replayed"`               // Hasil dari pengiriman sebelumnya
+	Entry          *CpptEntry `json:"entry,omitempty"`        // Entri hasil (applied)
+	ServerEntry    *CpptEntry `json:"server_entry,omitempty"` // Versi server saat konflik
+}
+
+type CpptSyncResponse struct {
+	Status  string           `json:"status"`
+	Message string           `json:"message"`
+	Results []CpptSyncResult `json:"results"`
+}
+
+// CpptSyncRecord: klaim lalu hasil final per idempotency key, supaya pengiriman ulang tidak menulis dua kali
+type CpptSyncRecord struct {
+	ID              uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+	IdempotencyKey  string    `gorm:"column:idempotency_key;size:64;uniqueIndex:idx_cppt_sync_key"`
+	KodeDokter      string    `gorm:"column:kd_dokter;size:20;uniqueIndex:idx_cppt_sync_key"`
+	DraftID         string    `gorm:"column:draft_id;size:64"`
+	Operation       string    `gorm:"column:operation;size:10"`
+	NoRawat         string    `gorm:"column:no_rawat;size:17"`
+	TglPerawatan    string    `gorm:"column:tgl_perawatan;size:10"`
+	JamRawat        string    `gorm:"column:jam_rawat;size:8"`
+	Status          string    `gorm:"column:status;size:20"`
+	Reason          string    `gorm:"column:reason;size:30"`
+	Message         string    `gorm:"column:message;size:500"`
+	ClientTimestamp time.Time `gorm:"column:client_timestamp"`
+	SyncedAt        time.Time `gorm:"column:synced_at"`
+}
+
+func (CpptSyncRecord) TableName() string {
+	return "pwa_cppt_sync"
+}
+
+// Cakupan template
+const (
+	TemplateScopePersonal   = "personal"   // Hanya pembuatnya
+	TemplateScopeDepartment = "department" // Semua dokter dengan spesialis (kd_sps) yang sama
+)
+
+// CpptTemplate: template SOAP / smart phrase. Isi boleh memuat placeholder {{...}}
+// yang di-expand server (lihat templatePlaceholders di cppt_template.go).
+type CpptTemplate struct {
+	ID         uint64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	Nama       string     `json:"nama" gorm:"column:nama;size:100"`
+	Shortcut   string     `json:"shortcut,omitempty" gorm:"column:shortcut;size:30;index"` // Smart phrase, mis. ".dbd"
+	Scope      string     `json:"scope" gorm:"column:scope;size:20"`
+	KodeDokter string     `json:"kd_dokter" gorm:"column:kd_dokter;size:20;index"` // Pembuat
+	KodeSps    string     `json:"kd_sps,omitempty" gorm:"column:kd_sps;size:5;index"`
+	Subjektif  string     `json:"subjektif" gorm:"column:subjektif;type:text"`
+	Objektif   string     `json:"objektif" gorm:"column:objektif;type:text"`
+	Asesmen    string     `json:"asesmen" gorm:"column:asesmen;type:text"`
+	Plan       string     `json:"plan" gorm:"column:plan;type:text"`
+	Instruksi  string     `json:"instruksi" gorm:"column:instruksi;type:text"`
+	Evaluasi   string     `json:"evaluasi" gorm:"column:evaluasi;type:text"`
+	UsageCount int64      `json:"usage_count" gorm:"column:usage_count"`
+	LastUsedAt *time.Time `json:"last_used_at" gorm:"column:last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (CpptTemplate) TableName() string {
+	return "pwa_cppt_template"
+}
+
+type CpptTemplateRequest struct {
+	Nama      string `json:"nama" binding:"required"`
+	Shortcut  string `json:"shortcut"`
+	Scope     string `json:"scope"` // Default personal
+	Subjektif string `json:"subjektif"`
+	Objektif  string `json:"objektif"`
+	Asesmen   string `json:"asesmen"`
+	Plan      string `json:"plan"`
+	Instruksi string `json:"instruksi"`
+	Evaluasi  string `json:"evaluasi"`
+}
+
+type CpptTemplateFilter struct {
+	Q     string `form:"q"`     // Cari di nama atau shortcut
+	Scope string `form:"scope"` // personal, department, atau kosong (semua)
+}
+
+// PasienTemplateContext: data pasien untuk expand placeholder
+type PasienTemplateContext struct {
+	NoRawat    string     `gorm:"column:no_rawat"`
+	NoRkmMedis string     `gorm:"column:no_rkm_medis"`
+	NmPasien   string     `gorm:"column:nm_pasien"`
+	Jk         string     `gorm:"column:jk"`
+	Umur       string     `gorm:"column:umur"`
+	NmBangsal  string     `gorm:"column:nm_bangsal"`
+	KdKamar    string     `gorm:"column:kd_kamar"`
+	Diagnosa   string     `gorm:"column:diagnosa_awal"`
+	TglMasuk   *time.Time `gorm:"column:tgl_masuk"`
+}
+
+// CpptTemplateExpansion: isi template siap dipakai sebagai CpptCreateRequest di form
+type CpptTemplateExpansion struct {
+	TemplateID uint64            `json:"template_id"`
+	Content    CpptCreateRequest `json:"content"`
+}
+
+// Filter riwayat CPPT
+type CpptFilter struct {
+	Nip      string `form:"nip"`       // Hanya catatan penulis ini
+	DateFrom string `form:"date_from"` // YYYY-MM-DD
+	DateTo   string `form:"date_to"`   // YYYY-MM-DD (inklusif)
+	Page     int    `form:"page"`
+	Limit    int    `form:"limit"`
+}
+
+type CpptListResponse struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Total   int64       `json:"total"`
+	Page    int         `json:"page"`
+	Limit   int         `json:"limit"`
+	Data    []CpptEntry `json:"data"`
+}
@@ -0,0 +1,416 @@
+package cppt
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrDuplicateEntry: sudah ada CPPT dengan no_rawat + tanggal + jam yang sama
+	ErrDuplicateEntry  = errors.New("cppt entry already exists for this time")
+	ErrEntryNotFound   = errors.New("cppt entry not found")
+	ErrAlreadyVerified = errors.New("cppt entry has already been verified")
+	ErrPasienNotFound  = errors.New("inpatient stay not found")
+	// ErrSyncKeyUsed: idempotency key sudah diklaim request lain (selesai atau masih berjalan)
+	ErrSyncKeyUsed = errors.New("idempotency key already used")
+)
+
+// Kolom CPPT + penulis (dokter atau petugas), dipakai list dan ambil satu entri
+const cpptSelect = `
+	SELECT
+		pr.no_rawat,
+		DATE_FORMAT(pr.tgl_perawatan, '%Y-%m-%d') as tgl_perawatan,
+		CAST(pr.jam_rawat AS CHAR) as jam_rawat,
+		COALESCE(pr.keluhan, '') as keluhan,
+		COALESCE(pr.pemeriksaan, '') as pemeriksaan,
+		COALESCE(pr.penilaian, '') as penilaian,
+		COALESCE(pr.rtl, '') as rtl,
+		COALESCE(pr.instruksi, '') as instruksi,
+		COALESCE(pr.evaluasi, '') as evaluasi,
+		COALESCE(pr.alergi, '') as alergi,
+		COALESCE(pr.suhu_tubuh, '') as suhu_tubuh,
+		COALESCE(pr.tensi, '') as tensi,
+		COALESCE(pr.nadi, '') as nadi,
+		COALESCE(pr.respirasi, '') as respirasi,
+		COALESCE(pr.tinggi, '') as tinggi,
+		COALESCE(pr.berat, '') as berat,
+		COALESCE(pr.spo2, '') as spo2,
+		COALESCE(pr.gcs, '') as gcs,
+		COALESCE(pr.kesadaran, '') as kesadaran,
+		pr.nip,
+		COALESCE(d.nm_dokter, pt.nama, pr.nip) as nama_penulis,
+		CASE
+			WHEN d.kd_dokter IS NOT NULL THEN 'dokter'
+			WHEN pt.nip IS NOT NULL THEN COALESCE(j.nm_jbtn, 'petugas')
+			ELSE 'lainnya'
+		END as role_penulis
+	FROM pemeriksaan_ranap pr
+	LEFT JOIN dokter d ON pr.nip = d.kd_dokter
+	LEFT JOIN petugas pt ON pr.nip = pt.nip
+	LEFT JOIN jabatan j ON pt.kd_jbtn = j.kd_jbtn`
+
+type CpptRepository interface {
+	FindCppt(noRawat string, filter CpptFilter) ([]CpptEntry, int64, error)
+	CreateCppt(entry *CpptEntry) error
+	GetCppt(noRawat, tglPerawatan, jamRawat string) (*CpptEntry, error)
+	UpdateCppt(entry *CpptEntry, revision *CpptRevision) error
+	GetRevisions(noRawat, tglPerawatan, jamRawat string) ([]CpptRevision, error)
+
+	// Verifikasi DPJP
+	CreateVerification(verification *CpptVerification) error
+	GetVerification(noRawat, tglPerawatan, jamRawat string) (*CpptVerification, error)
+	GetVerifications(noRawat string) ([]CpptVerification, error)
+
+	// Sync offline
+	GetSyncRecord(kdDokter, idempotencyKey string) (*CpptSyncRecord, error)
+	ClaimSyncRecord(record *CpptSyncRecord) error
+	FinishSyncRecord(record *CpptSyncRecord) error
+	Transaction(fn func(repo CpptRepository) error) error
+
+	// Template
+	GetKodeSps(kdDokter string) (string, error)
+	FindTemplates(kdDokter, kdSps string, filter CpptTemplateFilter) ([]CpptTemplate, error)
+	GetTemplate(id uint64) (*CpptTemplate, error)
+	CreateTemplate(template *CpptTemplate) error
+	SaveTemplate(template *CpptTemplate) error
+	DeleteTemplate(id uint64) error
+	IncrementTemplateUsage(id uint64, usedAt time.Time) error
+	GetPasienTemplateContext(noRawat string) (*PasienTemplateContext, error)
+	GetLastVitals(noRawat string) (*CpptVitals, error)
+}
+
+type cpptRepository struct {
+	db *gorm.DB
+}
+
+func NewCpptRepository(db *gorm.DB) CpptRepository {
+	return &cpptRepository{
+		db: db,
+	}
+}
+
+// ✅ Riwayat CPPT satu rawat inap, terbaru di atas. Penulis di-resolve dari dokter atau petugas.
+func (r *cpptRepository) FindCppt(noRawat string, filter CpptFilter) ([]CpptEntry, int64, error) {
+	where := " WHERE pr.no_rawat = ?"
+	args := []interface{}{noRawat}
+
+	if filter.Nip != "" {
+		where += " AND pr.nip = ?"
+		args = append(args, filter.Nip)
+	}
+	if filter.DateFrom != "" {
+		where += " AND pr.tgl_perawatan >= ?"
+		args = append(args, filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		where += " AND pr.tgl_perawatan <= ?"
+		args = append(args, filter.DateTo)
+	}
+
+	var total int64
+	err := r.db.Raw(`SELECT COUNT(*) FROM pemeriksaan_ranap pr`+where, args...).Scan(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := cpptSelect + where + `
+	ORDER BY pr.tgl_perawatan DESC, pr.jam_rawat DESC
+	LIMIT ? OFFSET ?`
+
+	var entries []CpptEntry
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	err = r.db.Raw(query, args...).Scan(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// ✅ Insert CPPT ke tabel Khanza. PK (no_rawat, tgl_perawatan, jam_rawat), kolom vital kosong disimpan sebagai string kosong
+func (r *cpptRepository) CreateCppt(entry *CpptEntry) error {
+	err := r.db.Exec(`
+		INSERT INTO pemeriksaan_ranap (
+			no_rawat, tgl_perawatan, jam_rawat,
+			suhu_tubuh, tensi, nadi, respirasi, tinggi, berat, spo2, gcs, kesadaran,
+			keluhan, pemeriksaan, alergi, penilaian, rtl, instruksi, evaluasi, nip
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		entry.NoRawat, entry.TglPerawatan, entry.JamRawat,
+		entry.Vitals.SuhuTubuh, entry.Vitals.Tensi, entry.Vitals.Nadi, entry.Vitals.Respirasi,
+		entry.Vitals.Tinggi, entry.Vitals.Berat, entry.Vitals.SpO2, entry.Vitals.GCS, entry.Vitals.Kesadaran,
+		entry.Subjektif, entry.Objektif, entry.Alergi, entry.Asesmen, entry.Plan, entry.Instruksi, entry.Evaluasi,
+		entry.Nip,
+	).Error
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrDuplicateEntry
+	}
+	return err
+}
+
+func (r *cpptRepository) GetCppt(noRawat, tglPerawatan, jamRawat string) (*CpptEntry, error) {
+	var entries []CpptEntry
+	err := r.db.Raw(cpptSelect+`
+	WHERE pr.no_rawat = ? AND pr.tgl_perawatan = ? AND pr.jam_rawat = ?`,
+		noRawat, tglPerawatan, jamRawat,
+	).Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	return &entries[0], nil
+}
+
+// ✅ Koreksi CPPT: update pemeriksaan_ranap dan catat revisi dalam satu transaksi.
+// Baris CPPT dikunci lalu status verifikasi dicek ulang di transaksi yang sama, sehingga
+// verifikasi yang masuk bersamaan (CreateVerification mengunci baris yang sama) tidak terlewat.
+func (r *cpptRepository) UpdateCppt(entry *CpptEntry, revision *CpptRevision) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockCppt(tx, entry.NoRawat, entry.TglPerawatan, entry.JamRawat, entry.Nip); err != nil {
+			return err
+		}
+
+		var verified int64
+		if err := tx.Model(&CpptVerification{}).
+			Where("no_rawat = ? AND tgl_perawatan = ? AND jam_rawat = ?", entry.NoRawat, entry.TglPerawatan, entry.JamRawat).
+			Count(&verified).Error; err != nil {
+			return err
+		}
+		if verified > 0 {
+			return ErrAlreadyVerified
+		}
+
+		result := tx.Exec(`
+			UPDATE pemeriksaan_ranap SET
+				suhu_tubuh = ?, tensi = ?, nadi = ?, respirasi = ?, tinggi = ?, berat = ?, spo2 = ?, gcs = ?, kesadaran = ?,
+				keluhan = ?, pemeriksaan = ?, alergi = ?, penilaian = ?, rtl = ?, instruksi = ?, evaluasi = ?
+			WHERE no_rawat = ? AND tgl_perawatan = ? AND jam_rawat = ? AND nip = ?
+		`,
+			entry.Vitals.SuhuTubuh, entry.Vitals.Tensi, entry.Vitals.Nadi, entry.Vitals.Respirasi,
+			entry.Vitals.Tinggi, entry.Vitals.Berat, entry.Vitals.SpO2, entry.Vitals.GCS, entry.Vitals.Kesadaran,
+			entry.Subjektif, entry.Objektif, entry.Alergi, entry.Asesmen, entry.Plan, entry.Instruksi, entry.Evaluasi,
+			entry.NoRawat, entry.TglPerawatan, entry.JamRawat, entry.Nip,
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		// Isi yang sama sudah ditolak service, jadi 0 baris berarti entri/penulis berubah
+		if result.RowsAffected == 0 {
+			return ErrEntryNotFound
+		}
+		return tx.Create(revision).Error
+	})
+}
+
+// lockCppt mengunci baris pemeriksaan_ranap (FOR UPDATE) sampai transaksi selesai.
+// nip kosong = penulis siapa pun.
+func lockCppt(tx *gorm.DB, noRawat, tglPerawatan, jamRawat, nip string) error {
+	query := tx.Table("pemeriksaan_ranap").
+		Where("no_rawat = ? AND tgl_perawatan = ? AND jam_rawat = ?", noRawat, tglPerawatan, jamRawat)
+	if nip != "" {
+		query = query.Where("nip = ?", nip)
+	}
+
+	var locked []string
+	if err := query.Clauses(clause.Locking{Strength: "UPDATE"}).Pluck("no_rawat", &locked).Error; err != nil {
+		return err
+	}
+	if len(locked) == 0 {
+		return ErrEntryNotFound
+	}
+	return nil
+}
+
+func (r *cpptRepository) GetRevisions(noRawat, tglPerawatan, jamRawat string) ([]CpptRevision, error) {
+	var revisions []CpptRevision
+	err := r.db.Where("no_rawat = ? AND tgl_perawatan = ? AND jam_rawat = ?", noRawat, tglPerawatan, jamRawat).
+		Order("edited_at ASC, id ASC").
+		Find(&revisions).Error
+	return revisions, err
+}
+
+// CreateVerification mengunci baris CPPT yang sama dengan UpdateCppt, jadi verifikasi
+// selalu untuk isi yang sudah final (tidak bisa menyalip koreksi yang sedang berjalan)
+func (r *cpptRepository) CreateVerification(verification *CpptVerification) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockCppt(tx, verification.NoRawat, verification.TglPerawatan, verification.JamRawat, ""); err != nil {
+			return err
+		}
+		return tx.Create(verification).Error
+	})
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrAlreadyVerified
+	}
+	return err
+}
+
+func (r *cpptRepository) GetVerification(noRawat, tglPerawatan, jamRawat string) (*CpptVerification, error) {
+	var verification CpptVerification
+	err := r.db.Where("no_rawat = ? AND tgl_perawatan = ? AND jam_rawat = ?", noRawat, tglPerawatan, jamRawat).
+		First(&verification).Error
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+func (r *cpptRepository) GetVerifications(noRawat string) ([]CpptVerification, error) {
+	var verifications []CpptVerification
+	err := r.db.Where("no_rawat = ?", noRawat).Find(&verifications).Error
+	return verifications, err
+}
+
+func (r *cpptRepository) GetSyncRecord(kdDokter, idempotencyKey string) (*CpptSyncRecord, error) {
+	var record CpptSyncRecord
+	err := r.db.Where("kd_dokter = ? AND idempotency_key = ?", kdDokter, idempotencyKey).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ClaimSyncRecord menyisipkan record pending lebih dulu; unique index (kd_dokter, idempotency_key)
+// membuat pengiriman ulang yang datang bersamaan menunggu lalu gagal dengan ErrSyncKeyUsed
+func (r *cpptRepository) ClaimSyncRecord(record *CpptSyncRecord) error {
+	err := r.db.Create(record).Error
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrSyncKeyUsed
+	}
+	return err
+}
+
+func (r *cpptRepository) FinishSyncRecord(record *CpptSyncRecord) error {
+	return r.db.Save(record).Error
+}
+
+// Transaction menjalankan fn dengan repository yang memakai satu transaksi database
+func (r *cpptRepository) Transaction(fn func(repo CpptRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&cpptRepository{db: tx})
+	})
+}
+
+// Spesialis dokter, dipakai untuk template bersama per departemen
+func (r *cpptRepository) GetKodeSps(kdDokter string) (string, error) {
+	var kdSps string
+	err := r.db.Raw(`SELECT COALESCE(kd_sps, '') FROM dokter WHERE kd_dokter = ?`, kdDokter).Scan(&kdSps).Error
+	return kdSps, err
+}
+
+// ✅ Template milik sendiri + template departemen, yang paling sering dipakai di atas
+func (r *cpptRepository) FindTemplates(kdDokter, kdSps string, filter CpptTemplateFilter) ([]CpptTemplate, error) {
+	query := r.db.Where("(scope = ? AND kd_dokter = ?) OR (scope = ? AND kd_sps = ? AND kd_sps <> '')",
+		TemplateScopePersonal, kdDokter, TemplateScopeDepartment, kdSps)
+
+	if filter.Scope != "" {
+		query = query.Where("scope = ?", filter.Scope)
+	}
+	if filter.Q != "" {
+		like := "%" + filter.Q + "%"
+		query = query.Where("(nama LIKE ? OR shortcut LIKE ?)", like, like)
+	}
+
+	var templates []CpptTemplate
+	err := query.Order("usage_count DESC, nama ASC").Find(&templates).Error
+	return templates, err
+}
+
+func (r *cpptRepository) GetTemplate(id uint64) (*CpptTemplate, error) {
+	var template CpptTemplate
+	err := r.db.Where("id = ?", id).First(&template).Error
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *cpptRepository) CreateTemplate(template *CpptTemplate) error {
+	return r.db.Create(template).Error
+}
+
+func (r *cpptRepository) SaveTemplate(template *CpptTemplate) error {
+	return r.db.Save(template).Error
+}
+
+func (r *cpptRepository) DeleteTemplate(id uint64) error {
+	return r.db.Where("id = ?", id).Delete(&CpptTemplate{}).Error
+}
+
+func (r *cpptRepository) IncrementTemplateUsage(id uint64, usedAt time.Time) error {
+	return r.db.Model(&CpptTemplate{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"usage_count":  gorm.Expr("usage_count + 1"),
+		"last_used_at": usedAt,
+	}).Error
+}
+
+// Identitas pasien + kamar terakhir untuk placeholder template
+func (r *cpptRepository) GetPasienTemplateContext(noRawat string) (*PasienTemplateContext, error) {
+	var rows []PasienTemplateContext
+	err := r.db.Raw(`
+	SELECT
+		rp.no_rawat,
+		p.no_rkm_medis,
+		p.nm_pasien,
+		p.jk,
+		CONCAT(rp.umurdaftar, ' ', rp.sttsumur) as umur,
+		COALESCE(b.nm_bangsal, '') as nm_bangsal,
+		COALESCE(ki.kd_kamar, '') as kd_kamar,
+		COALESCE(ki.diagnosa_awal, '') as diagnosa_awal,
+		masuk.tgl_masuk
+	FROM reg_periksa rp
+	JOIN pasien p ON rp.no_rkm_medis = p.no_rkm_medis
+	LEFT JOIN (
+		SELECT no_rawat, MIN(tgl_masuk) as tgl_masuk FROM kamar_inap WHERE no_rawat = ? GROUP BY no_rawat
+	) masuk ON masuk.no_rawat = rp.no_rawat
+	LEFT JOIN kamar_inap ki ON ki.no_rawat = rp.no_rawat
+	LEFT JOIN kamar k ON ki.kd_kamar = k.kd_kamar
+	LEFT JOIN bangsal b ON k.kd_bangsal = b.kd_bangsal
+	WHERE rp.no_rawat = ?
+	ORDER BY ki.tgl_masuk DESC, ki.jam_masuk DESC
+	LIMIT 1`, noRawat, noRawat).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrPasienNotFound
+	}
+	return &rows[0], nil
+}
+
+// Tanda vital terakhir yang tercatat (baris pemeriksaan_ranap terbaru dengan tensi/suhu terisi)
+func (r *cpptRepository) GetLastVitals(noRawat string) (*CpptVitals, error) {
+	var vitals []CpptVitals
+	err := r.db.Raw(`
+	SELECT
+		COALESCE(suhu_tubuh, '') as suhu_tubuh,
+		COALESCE(tensi, '') as tensi,
+		COALESCE(nadi, '') as nadi,
+		COALESCE(respirasi, '') as respirasi,
+		COALESCE(tinggi, '') as tinggi,
+		COALESCE(berat, '') as berat,
+		COALESCE(spo2, '') as spo2,
+		COALESCE(gcs, '') as gcs,
+		COALESCE(kesadaran, '') as kesadaran
+	FROM pemeriksaan_ranap
+	WHERE no_rawat = ? AND (tensi <> '' OR suhu_tubuh <> '')
+	ORDER BY tgl_perawatan DESC, jam_rawat DESC
+	LIMIT 1`, noRawat).Scan(&vitals).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(vitals) == 0 {
+		return &CpptVitals{}, nil
+	}
+	return &vitals[0], nil
+}
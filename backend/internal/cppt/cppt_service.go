@@ -0,0 +1,322 @@
+package cppt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/listranap"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrWriteDenied   = errors.New("only the DPJP or consultant of an admitted patient can write CPPT")
+
+	ErrNotAuthor         = errors.New("only the author can edit this cppt entry")
+	ErrEditWindowExpired = errors.New("the correction window for this cppt entry has passed")
+	ErrVerifyDenied      = errors.New("only the DPJP can verify cppt entries")
+	ErrVerifyOwnEntry    = errors.New("cppt entries cannot be verified by their own author")
+)
+
+type CpptService interface {
+	GetCpptHistory(noRawat, kdDokter string, filter CpptFilter) (*CpptListResponse, error)
+	CreateCppt(noRawat, kdDokter, nmDokter string, req CpptCreateRequest) (*CpptEntry, error)
+	UpdateCppt(noRawat, tglPerawatan, jamRawat, kdDokter string, req CpptUpdateRequest) (*CpptEntry, error)
+	GetRevisions(noRawat, tglPerawatan, jamRawat, kdDokter string) ([]CpptRevision, error)
+	VerifyCppt(noRawat, tglPerawatan, jamRawat, kdDokter, nmDokter string, req CpptVerifyRequest) (*CpptVerification, error)
+	SyncDrafts(kdDokter, nmDokter string, req CpptSyncRequest) *CpptSyncResponse
+
+	// Template & smart phrase
+	ListTemplates(kdDokter string, filter CpptTemplateFilter) ([]CpptTemplate, error)
+	CreateTemplate(kdDokter string, req CpptTemplateRequest) (*CpptTemplate, error)
+	UpdateTemplate(id uint64, kdDokter string, req CpptTemplateRequest) (*CpptTemplate, error)
+	DeleteTemplate(id uint64, kdDokter string) error
+	ExpandTemplate(id uint64, noRawat, kdDokter, nmDokter string) (*CpptTemplateExpansion, error)
+}
+
+type cpptService struct {
+	cpptRepo   CpptRepository
+	access     listranap.AccessChecker
+	editWindow time.Duration // Batas waktu koreksi oleh penulis, dihitung dari jam_rawat
+}
+
+// accessError: hanya penolakan hak akses dari listranap yang menjadi denied (403);
+// error lain (database) diteruskan apa adanya supaya handler menjawab 500
+func accessError(err, denied error) error {
+	if errors.Is(err, listranap.ErrAksesDitolak) || errors.Is(err, listranap.ErrPasienTidakAktif) {
+		return denied
+	}
+	return err
+}
+
+func NewCpptService(cpptRepo CpptRepository, access listranap.AccessChecker, editWindow time.Duration) CpptService {
+	return &cpptService{
+		cpptRepo:   cpptRepo,
+		access:     access,
+		editWindow: editWindow,
+	}
+}
+
+func (s *cpptService) GetCpptHistory(noRawat, kdDokter string, filter CpptFilter) (*CpptListResponse, error) {
+	fmt.Printf("🔍 Getting CPPT history for: %s by doctor: %s\n", noRawat, kdDokter)
+
+	if err := s.access.CheckAccess(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ CPPT access denied for %s: %v\n", kdDokter, err)
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+	if !validDate(filter.DateFrom) {
+		return nil, fmt.Errorf("%w: date_from must be YYYY-MM-DD", ErrInvalidFilter)
+	}
+	if !validDate(filter.DateTo) {
+		return nil, fmt.Errorf("%w: date_to must be YYYY-MM-DD", ErrInvalidFilter)
+	}
+
+	entries, total, err := s.cpptRepo.FindCppt(noRawat, filter)
+	if err != nil {
+		fmt.Printf("❌ Error getting CPPT history: %v\n", err)
+		return nil, err
+	}
+
+	s.attachVerifications(noRawat, entries)
+	for i := range entries {
+		entries[i].Versi = contentVersion(&entries[i])
+	}
+
+	fmt.Printf("✅ Found %d CPPT entries for %s\n", total, noRawat)
+	return &CpptListResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Found %d CPPT entries", total),
+		Total:   total,
+		Page:    filter.Page,
+		Limit:   filter.Limit,
+		Data:    entries,
+	}, nil
+}
+
+// ✅ CreateCppt menulis SOAP ke pemeriksaan_ranap dengan nip dokter dan waktu server,
+// sehingga cppt_status di list langsung menjadi "done"
+func (s *cpptService) CreateCppt(noRawat, kdDokter, nmDokter string, req CpptCreateRequest) (*CpptEntry, error) {
+	fmt.Printf("📝 Writing CPPT for: %s by doctor: %s\n", noRawat, kdDokter)
+
+	if err := s.access.CheckWriteAccess(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ CPPT write denied for %s: %v\n", kdDokter, err)
+		return nil, accessError(err, ErrWriteDenied)
+	}
+
+	if err := normalizeCreateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entry := &CpptEntry{
+		NoRawat:      noRawat,
+		TglPerawatan: now.Format("2006-01-02"),
+		JamRawat:     now.Format("15:04:05"),
+		Subjektif:    req.Subjektif,
+		Objektif:     req.Objektif,
+		Asesmen:      req.Asesmen,
+		Plan:         req.Plan,
+		Instruksi:    req.Instruksi,
+		Evaluasi:     req.Evaluasi,
+		Alergi:       req.Alergi,
+		Vitals:       req.Vitals,
+		Nip:          kdDokter,
+		NamaPenulis:  nmDokter,
+		RolePenulis:  "dokter",
+	}
+
+	if err := s.cpptRepo.CreateCppt(entry); err != nil {
+		fmt.Printf("❌ Failed to write CPPT: %v\n", err)
+		return nil, err
+	}
+
+	entry.Versi = contentVersion(entry)
+	fmt.Printf("✅ CPPT written for %s at %s %s\n", noRawat, entry.TglPerawatan, entry.JamRawat)
+	return entry, nil
+}
+
+// ✅ UpdateCppt: koreksi oleh penulis sendiri yang masih punya hak tulis, dalam batas waktu;
+// isi lama disimpan sebagai revisi.
+// Entri yang sudah diverifikasi DPJP tidak bisa diubah lagi.
+func (s *cpptService) UpdateCppt(noRawat, tglPerawatan, jamRawat, kdDokter string, req CpptUpdateRequest) (*CpptEntry, error) {
+	fmt.Printf("✏️ Editing CPPT %s %s %s by doctor: %s\n", noRawat, tglPerawatan, jamRawat, kdDokter)
+
+	entry, err := s.cpptRepo.GetCppt(noRawat, tglPerawatan, jamRawat)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Nip != kdDokter {
+		return nil, ErrNotAuthor
+	}
+	// Penulis yang bukan lagi DPJP/konsulen, atau pasien sudah pulang, tidak bisa mengoreksi (sama dengan sync)
+	if err := s.access.CheckWriteAccess(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ CPPT edit denied for %s: %v\n", kdDokter, err)
+		return nil, accessError(err, ErrWriteDenied)
+	}
+
+	writtenAt, err := time.ParseInLocation("2006-01-02 15:04:05", entry.TglPerawatan+" "+entry.JamRawat, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(writtenAt) > s.editWindow {
+		return nil, ErrEditWindowExpired
+	}
+
+	// Cek cepat; repository mengecek ulang di dalam transaksi update
+	_, err = s.cpptRepo.GetVerification(noRawat, entry.TglPerawatan, entry.JamRawat)
+	if err == nil {
+		return nil, ErrAlreadyVerified
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("❌ Failed to check CPPT verification: %v\n", err)
+		return nil, err
+	}
+
+	if err := normalizeCreateRequest(&req.CpptCreateRequest); err != nil {
+		return nil, err
+	}
+	previousVersion := contentVersion(entry)
+
+	revision := &CpptRevision{
+		NoRawat:      entry.NoRawat,
+		TglPerawatan: entry.TglPerawatan,
+		JamRawat:     entry.JamRawat,
+		Sebelum:      contentOf(entry),
+		Sesudah:      req.CpptCreateRequest,
+		Alasan:       strings.TrimSpace(req.Alasan),
+		EditedBy:     kdDokter,
+		EditedAt:     time.Now(),
+	}
+
+	entry.Subjektif = req.Subjektif
+	entry.Objektif = req.Objektif
+	entry.Asesmen = req.Asesmen
+	entry.Plan = req.Plan
+	entry.Instruksi = req.Instruksi
+	entry.Evaluasi = req.Evaluasi
+	entry.Alergi = req.Alergi
+	entry.Vitals = req.Vitals
+	if contentVersion(entry) == previousVersion {
+		return nil, fmt.Errorf("%w: no changes to save", ErrValidation)
+	}
+
+	if err := s.cpptRepo.UpdateCppt(entry, revision); err != nil {
+		fmt.Printf("❌ Failed to edit CPPT: %v\n", err)
+		return nil, err
+	}
+
+	entry.Versi = contentVersion(entry)
+	fmt.Printf("✅ CPPT edited for %s at %s %s\n", noRawat, entry.TglPerawatan, entry.JamRawat)
+	return entry, nil
+}
+
+// Riwayat revisi satu entri, bisa dilihat siapa pun yang boleh membaca CPPT pasien ini
+func (s *cpptService) GetRevisions(noRawat, tglPerawatan, jamRawat, kdDokter string) ([]CpptRevision, error) {
+	if err := s.access.CheckAccess(noRawat, kdDokter); err != nil {
+		return nil, err
+	}
+
+	entry, err := s.cpptRepo.GetCppt(noRawat, tglPerawatan, jamRawat)
+	if err != nil {
+		return nil, err
+	}
+	return s.cpptRepo.GetRevisions(noRawat, entry.TglPerawatan, entry.JamRawat)
+}
+
+// ✅ VerifyCppt: DPJP menandatangani catatan residen/perawat. Sekali verifikasi, tidak bisa diulang.
+func (s *cpptService) VerifyCppt(noRawat, tglPerawatan, jamRawat, kdDokter, nmDokter string, req CpptVerifyRequest) (*CpptVerification, error) {
+	fmt.Printf("🖊️ Verifying CPPT %s %s %s by doctor: %s\n", noRawat, tglPerawatan, jamRawat, kdDokter)
+
+	if err := s.access.CheckDPJP(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ CPPT verification denied for %s: %v\n", kdDokter, err)
+		return nil, accessError(err, ErrVerifyDenied)
+	}
+
+	entry, err := s.cpptRepo.GetCppt(noRawat, tglPerawatan, jamRawat)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Nip == kdDokter {
+		return nil, ErrVerifyOwnEntry
+	}
+
+	catatan := strings.TrimSpace(req.Catatan)
+	if len([]rune(catatan)) > 500 {
+		return nil, fmt.Errorf("%w: catatan must be at most 500 characters", ErrValidation)
+	}
+
+	verification := &CpptVerification{
+		NoRawat:      entry.NoRawat,
+		TglPerawatan: entry.TglPerawatan,
+		JamRawat:     entry.JamRawat,
+		KodeDokter:   kdDokter,
+		NamaDokter:   nmDokter,
+		Catatan:      catatan,
+		VerifiedAt:   time.Now(),
+	}
+	if err := s.cpptRepo.CreateVerification(verification); err != nil {
+		fmt.Printf("❌ Failed to verify CPPT: %v\n", err)
+		return nil, err
+	}
+
+	fmt.Printf("✅ CPPT verified for %s at %s %s\n", noRawat, entry.TglPerawatan, entry.JamRawat)
+	return verification, nil
+}
+
+// attachVerifications mengisi status verifikasi DPJP pada hasil list (gagal = tampil tanpa status)
+func (s *cpptService) attachVerifications(noRawat string, entries []CpptEntry) {
+	verifications, err := s.cpptRepo.GetVerifications(noRawat)
+	if err != nil {
+		fmt.Printf("⚠️ Failed to load CPPT verifications: %v\n", err)
+		return
+	}
+
+	byKey := make(map[string]*CpptVerification, len(verifications))
+	for i := range verifications {
+		v := &verifications[i]
+		byKey[v.TglPerawatan+" "+v.JamRawat] = v
+	}
+	for i := range entries {
+		entries[i].Verifikasi = byKey[entries[i].TglPerawatan+" "+entries[i].JamRawat]
+	}
+}
+
+func contentOf(entry *CpptEntry) CpptCreateRequest {
+	return CpptCreateRequest{
+		Subjektif: entry.Subjektif,
+		Objektif:  entry.Objektif,
+		Asesmen:   entry.Asesmen,
+		Plan:      entry.Plan,
+		Instruksi: entry.Instruksi,
+		Evaluasi:  entry.Evaluasi,
+		Alergi:    entry.Alergi,
+		Vitals:    entry.Vitals,
+	}
+}
+
+// contentVersion: hash isi SOAP + vital. Berubah juga kalau entri diedit dari aplikasi desktop Khanza.
+func contentVersion(entry *CpptEntry) string {
+	content, _ := json.Marshal(contentOf(entry))
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+func validDate(value string) bool {
+	if value == "" {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
@@ -0,0 +1,323 @@
+package cppt
+
+import (
+	"errors"
+	"pwa-rsbw/internal/listranap"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakeCpptRepo: CpptRepository di memori; method yang tidak di-override akan panic
+type fakeCpptRepo struct {
+	CpptRepository
+
+	entries         map[string]*CpptEntry // no_rawat|tgl|jam
+	verifications   map[string]*CpptVerification
+	verificationErr error
+	updateErr       error // mis. verifikasi yang masuk bersamaan, terdeteksi di transaksi
+	revisions       []CpptRevision
+	syncRecords     map[string]*CpptSyncRecord // kd_dokter|idempotency_key
+	createErr       error
+	racingRecord    *CpptSyncRecord // Di-commit request lain tepat sebelum transaksi dimulai
+}
+
+func newFakeCpptRepo() *fakeCpptRepo {
+	return &fakeCpptRepo{
+		entries:       make(map[string]*CpptEntry),
+		verifications: make(map[string]*CpptVerification),
+		syncRecords:   make(map[string]*CpptSyncRecord),
+	}
+}
+
+func cpptKey(noRawat, tglPerawatan, jamRawat string) string {
+	return noRawat + "|" + tglPerawatan + "|" + jamRawat
+}
+
+func (r *fakeCpptRepo) GetCppt(noRawat, tglPerawatan, jamRawat string) (*CpptEntry, error) {
+	entry, ok := r.entries[cpptKey(noRawat, tglPerawatan, jamRawat)]
+	if !ok {
+		return nil, ErrEntryNotFound
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+func (r *fakeCpptRepo) CreateCppt(entry *CpptEntry) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	key := cpptKey(entry.NoRawat, entry.TglPerawatan, entry.JamRawat)
+	if _, ok := r.entries[key]; ok {
+		return ErrDuplicateEntry
+	}
+	copied := *entry
+	r.entries[key] = &copied
+	return nil
+}
+
+func (r *fakeCpptRepo) GetSyncRecord(kdDokter, idempotencyKey string) (*CpptSyncRecord, error) {
+	record, ok := r.syncRecords[kdDokter+"|"+idempotencyKey]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return record, nil
+}
+
+func (r *fakeCpptRepo) ClaimSyncRecord(record *CpptSyncRecord) error {
+	key := record.KodeDokter + "|" + record.IdempotencyKey
+	if _, ok := r.syncRecords[key]; ok {
+		return ErrSyncKeyUsed
+	}
+	copied := *record
+	r.syncRecords[key] = &copied
+	return nil
+}
+
+func (r *fakeCpptRepo) FinishSyncRecord(record *CpptSyncRecord) error {
+	copied := *record
+	r.syncRecords[record.KodeDokter+"|"+record.IdempotencyKey] = &copied
+	return nil
+}
+
+// Transaction: rollback dengan mengembalikan isi map jika fn gagal
+func (r *fakeCpptRepo) Transaction(fn func(repo CpptRepository) error) error {
+	if r.racingRecord != nil {
+		r.syncRecords[r.racingRecord.KodeDokter+"|"+r.racingRecord.IdempotencyKey] = r.racingRecord
+		r.racingRecord = nil
+	}
+	entries := make(map[string]*CpptEntry, len(r.entries))
+	for key, value := range r.entries {
+		entries[key] = value
+	}
+	records := make(map[string]*CpptSyncRecord, len(r.syncRecords))
+	for key, value := range r.syncRecords {
+		records[key] = value
+	}
+	if err := fn(r); err != nil {
+		r.entries, r.syncRecords = entries, records
+		return err
+	}
+	return nil
+}
+
+func (r *fakeCpptRepo) GetVerification(noRawat, tglPerawatan, jamRawat string) (*CpptVerification, error) {
+	if r.verificationErr != nil {
+		return nil, r.verificationErr
+	}
+	verification, ok := r.verifications[cpptKey(noRawat, tglPerawatan, jamRawat)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return verification, nil
+}
+
+func (r *fakeCpptRepo) UpdateCppt(entry *CpptEntry, revision *CpptRevision) error {
+	if r.updateErr != nil {
+		return r.updateErr
+	}
+	copied := *entry
+	r.entries[cpptKey(entry.NoRawat, entry.TglPerawatan, entry.JamRawat)] = &copied
+	r.revisions = append(r.revisions, *revision)
+	return nil
+}
+
+func TestUpdateCppt(t *testing.T) {
+	const noRawat = "2025/01/31/000001"
+	writtenAt := time.Now().Add(-time.Hour)
+	tgl, jam := writtenAt.Format("2006-01-02"), writtenAt.Format("15:04:05")
+	original := CpptCreateRequest{Subjektif: "nyeri dada", Asesmen: "ACS", Plan: "EKG serial"}
+	changed := CpptCreateRequest{Subjektif: "nyeri dada", Asesmen: "ACS", Plan: "EKG serial, troponin"}
+	dbErr := errors.New("connection refused")
+
+	tests := []struct {
+		name            string
+		req             CpptCreateRequest
+		verified        bool
+		verificationErr error
+		updateErr       error
+		notDPJP         bool
+		accessErr       error
+		wantErr         error
+	}{
+		{name: "author edit", req: changed},
+		{name: "author lost write access", req: changed, notDPJP: true, wantErr: ErrWriteDenied},
+		{name: "access check fails", req: changed, accessErr: dbErr, wantErr: dbErr},
+		{name: "already verified", req: changed, verified: true, wantErr: ErrAlreadyVerified},
+		// Error database saat cek verifikasi tidak boleh dianggap "belum diverifikasi"
+		{name: "verification lookup fails", req: changed, verificationErr: dbErr, wantErr: dbErr},
+		{name: "verified during the edit", req: changed, updateErr: ErrAlreadyVerified, wantErr: ErrAlreadyVerified},
+		{name: "no changes", req: original, wantErr: ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeCpptRepo()
+			repo.entries[cpptKey(noRawat, tgl, jam)] = &CpptEntry{
+				NoRawat: noRawat, TglPerawatan: tgl, JamRawat: jam, Nip: "D001",
+				Subjektif: original.Subjektif, Asesmen: original.Asesmen, Plan: original.Plan,
+				Vitals: CpptVitals{Kesadaran: defaultKesadaran},
+			}
+			if tt.verified {
+				repo.verifications[cpptKey(noRawat, tgl, jam)] = &CpptVerification{KodeDokter: "D002"}
+			}
+			repo.verificationErr = tt.verificationErr
+			repo.updateErr = tt.updateErr
+			access := &fakeAccess{dpjp: map[string]bool{"D001": !tt.notDPJP}, err: tt.accessErr}
+			service := NewCpptService(repo, access, 24*time.Hour)
+
+			entry, err := service.UpdateCppt(noRawat, tgl, jam, "D001", CpptUpdateRequest{CpptCreateRequest: tt.req})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if len(repo.revisions) != 0 {
+					t.Fatal("revision written for a rejected edit")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateCppt: %v", err)
+			}
+			if entry.Plan != tt.req.Plan || len(repo.revisions) != 1 || repo.revisions[0].Sebelum.Plan != original.Plan {
+				t.Fatalf("entry = %+v, revisions = %+v", entry, repo.revisions)
+			}
+		})
+	}
+}
+
+// fakeAccess: semua dokter di dpjp adalah DPJP pasien yang masih dirawat; err = gagal cek (database)
+type fakeAccess struct {
+	dpjp map[string]bool
+	err  error
+}
+
+func (a *fakeAccess) CheckAccess(noRawat, kdDokter string) error {
+	return a.CheckDPJP(noRawat, kdDokter)
+}
+
+func (a *fakeAccess) CheckWriteAccess(noRawat, kdDokter string) error {
+	return a.CheckDPJP(noRawat, kdDokter)
+}
+
+func (a *fakeAccess) CheckDPJP(noRawat, kdDokter string) error {
+	if a.err != nil {
+		return a.err
+	}
+	if !a.dpjp[kdDokter] {
+		return listranap.ErrAksesDitolak
+	}
+	return nil
+}
+
+func (a *fakeAccess) IsPasienAktif(noRawat string) (bool, error) {
+	return true, nil
+}
+
+func TestSyncCreateUsesServerTime(t *testing.T) {
+	repo := newFakeCpptRepo()
+	service := NewCpptService(repo, &fakeAccess{dpjp: map[string]bool{"D001": true}}, 24*time.Hour)
+
+	// Draft ditulis offline kemarin sore; jam di rekam medis tetap jam server saat sync
+	clientTime := time.Now().Add(-20 * time.Hour).Truncate(time.Second)
+	before := time.Now().Truncate(time.Second)
+	resp := service.SyncDrafts("D001", "dr. Andi", CpptSyncRequest{Items: []CpptSyncItem{{
+		DraftID:           "draft-1",
+		IdempotencyKey:    "key-1",
+		ClientTimestamp:   clientTime,
+		Operation:         SyncOperationCreate,
+		NoRawat:           "2025/01/31/000001",
+		CpptCreateRequest: CpptCreateRequest{Subjektif: "sesak", Asesmen: "CHF", Plan: "furosemid"},
+	}}})
+
+	result := resp.Results[0]
+	if result.Status != SyncStatusApplied {
+		t.Fatalf("status = %s (%s), want applied", result.Status, result.Message)
+	}
+	written, err := time.ParseInLocation("2006-01-02 15:04:05", result.Entry.TglPerawatan+" "+result.Entry.JamRawat, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.Before(before) {
+		t.Errorf("entry written at %s, want server time (>= %s)", written, before)
+	}
+
+	record := repo.syncRecords["D001|key-1"]
+	if record == nil || !record.ClientTimestamp.Equal(clientTime) {
+		t.Fatalf("sync record = %+v, want client_timestamp %s", record, clientTime)
+	}
+}
+
+func TestSyncReplaySameKey(t *testing.T) {
+	const noRawat = "2025/01/31/000001"
+	draft := CpptSyncItem{
+		DraftID:           "draft-1",
+		IdempotencyKey:    "key-1",
+		ClientTimestamp:   time.Now().Add(-time.Hour),
+		Operation:         SyncOperationCreate,
+		NoRawat:           noRawat,
+		CpptCreateRequest: CpptCreateRequest{Subjektif: "sesak", Asesmen: "CHF", Plan: "furosemid"},
+	}
+	applied := &CpptSyncRecord{
+		IdempotencyKey: "key-1", KodeDokter: "D001", DraftID: "draft-1", Operation: SyncOperationCreate,
+		NoRawat: noRawat, TglPerawatan: "2025-01-31", JamRawat: "08:00:00", Status: SyncStatusApplied,
+	}
+
+	tests := []struct {
+		name         string
+		items        int             // Jumlah pengiriman key yang sama dalam satu batch
+		pending      bool            // Pengiriman lain masih berjalan
+		racing       *CpptSyncRecord // Pengiriman lain selesai di antara lookup dan klaim
+		createErr    error
+		wantStatus   []string
+		wantReplayed []bool
+		wantEntries  int
+		wantRecord   string // Status record tersimpan, kosong = tidak ada record
+	}{
+		{name: "sent twice", items: 2, wantStatus: []string{SyncStatusApplied, SyncStatusApplied},
+			wantReplayed: []bool{false, true}, wantEntries: 1, wantRecord: SyncStatusApplied},
+		{name: "still in flight", items: 1, pending: true, wantStatus: []string{SyncStatusError},
+			wantReplayed: []bool{false}, wantEntries: 0, wantRecord: SyncStatusPending},
+		{name: "claimed by a concurrent request", items: 1, racing: applied, wantStatus: []string{SyncStatusApplied},
+			wantReplayed: []bool{true}, wantEntries: 0, wantRecord: SyncStatusApplied},
+		// Gagal sementara: klaim di-rollback, pengiriman berikutnya menulis seperti biasa
+		{name: "temporary failure releases the key", items: 1, createErr: ErrDuplicateEntry, wantStatus: []string{SyncStatusError},
+			wantReplayed: []bool{false}, wantEntries: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeCpptRepo()
+			repo.createErr = tt.createErr
+			repo.racingRecord = tt.racing
+			if tt.pending {
+				repo.syncRecords["D001|key-1"] = &CpptSyncRecord{IdempotencyKey: "key-1", KodeDokter: "D001", Status: SyncStatusPending}
+			}
+			service := NewCpptService(repo, &fakeAccess{dpjp: map[string]bool{"D001": true}}, 24*time.Hour)
+
+			items := make([]CpptSyncItem, tt.items)
+			for i := range items {
+				items[i] = draft
+			}
+			resp := service.SyncDrafts("D001", "dr. Andi", CpptSyncRequest{Items: items})
+
+			for i, result := range resp.Results {
+				if result.Status != tt.wantStatus[i] || result.Replayed != tt.wantReplayed[i] {
+					t.Errorf("result %d = %s (replayed %v, %s), want %s (replayed %v)",
+						i, result.Status, result.Replayed, result.Message, tt.wantStatus[i], tt.wantReplayed[i])
+				}
+			}
+			if len(repo.entries) != tt.wantEntries {
+				t.Errorf("%d CPPT entries written, want %d", len(repo.entries), tt.wantEntries)
+			}
+			record := repo.syncRecords["D001|key-1"]
+			switch {
+			case tt.wantRecord == "" && record != nil:
+				t.Errorf("sync record = %+v, want none", record)
+			case tt.wantRecord != "" && (record == nil || record.Status != tt.wantRecord):
+				t.Errorf("sync record = %+v, want status %s", record, tt.wantRecord)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package cppt
+
+import (
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/listranap"
+	"pwa-rsbw/internal/textutil"
+	"strings"
+	"time"
+)
+
+// Batas waktu client_timestamp draft offline yang masih diterima
+const (
+	maxDraftAge    = 72 * time.Hour
+	maxClockSkew   = 5 * time.Minute
+	maxSyncMessage = 500
+)
+
+// errSyncRetry: penanda internal untuk membatalkan transaksi sync tanpa dianggap gagal database
+var errSyncRetry = errors.New("sync item must be retried")
+
+// ✅ SyncDrafts menerapkan draft CPPT offline satu per satu. Kegagalan satu item tidak
+// membatalkan item lain; hasil final disimpan per idempotency key sehingga retry aman.
+func (s *cpptService) SyncDrafts(kdDokter, nmDokter string, req CpptSyncRequest) *CpptSyncResponse {
+	fmt.Printf("🔄 Syncing %d CPPT drafts for doctor: %s\n", len(req.Items), kdDokter)
+
+	results := make([]CpptSyncResult, 0, len(req.Items))
+	applied := 0
+	for _, item := range req.Items {
+		result := s.syncItem(kdDokter, nmDokter, item)
+		if result.Status == SyncStatusApplied {
+			applied++
+		}
+		results = append(results, result)
+	}
+
+	fmt.Printf("✅ CPPT sync for %s: %d/%d applied\n", kdDokter, applied, len(req.Items))
+	return &CpptSyncResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("%d of %d drafts applied", applied, len(req.Items)),
+		Results: results,
+	}
+}
+
+func (s *cpptService) syncItem(kdDokter, nmDokter string, item CpptSyncItem) CpptSyncResult {
+	if problem := checkSyncItem(item); problem != "" {
+		return CpptSyncResult{
+			DraftID:        item.DraftID,
+			IdempotencyKey: item.IdempotencyKey,
+			Status:         SyncStatusRejected,
+			Message:        problem,
+		}
+	}
+
+	// Pengiriman ulang: kembalikan hasil sebelumnya tanpa menulis lagi
+	if record, err := s.cpptRepo.GetSyncRecord(kdDokter, item.IdempotencyKey); err == nil {
+		return s.replaySync(item, record)
+	}
+
+	// Key diklaim (record pending) lalu draft diterapkan dan record difinalkan dalam satu transaksi.
+	// Pengiriman ulang yang datang bersamaan tertahan di unique index sampai transaksi ini selesai.
+	var result CpptSyncResult
+	err := s.cpptRepo.Transaction(func(repo CpptRepository) error {
+		record := &CpptSyncRecord{
+			IdempotencyKey:  item.IdempotencyKey,
+			KodeDokter:      kdDokter,
+			DraftID:         item.DraftID,
+			Operation:       item.Operation,
+			NoRawat:         item.NoRawat,
+			Status:          SyncStatusPending,
+			ClientTimestamp: item.ClientTimestamp,
+			SyncedAt:        time.Now(),
+		}
+		if err := repo.ClaimSyncRecord(record); err != nil {
+			return err
+		}
+
+		inTx := *s
+		inTx.cpptRepo = repo
+		var entry *CpptEntry
+		switch item.Operation {
+		case SyncOperationCreate:
+			result, entry = inTx.syncCreate(kdDokter, nmDokter, item)
+		case SyncOperationUpdate:
+			result, entry = inTx.syncUpdate(kdDokter, item)
+		default:
+			result = CpptSyncResult{Status: SyncStatusRejected, Message: "operation must be create or update"}
+		}
+
+		// Error sementara: rollback klaim supaya client bisa retry dengan key yang sama
+		if result.Status == SyncStatusError {
+			return errSyncRetry
+		}
+
+		record.Status = result.Status
+		record.Reason = result.Reason
+		record.Message = textutil.Truncate(strings.TrimSpace(result.Message), maxSyncMessage)
+		record.SyncedAt = time.Now()
+		if entry != nil {
+			record.TglPerawatan = entry.TglPerawatan
+			record.JamRawat = entry.JamRawat
+		}
+		return repo.FinishSyncRecord(record)
+	})
+
+	switch {
+	case err == nil, errors.Is(err, errSyncRetry):
+	case errors.Is(err, ErrSyncKeyUsed):
+		// Request lain dengan key yang sama baru saja selesai; jika ternyata di-rollback, client retry
+		if record, err := s.cpptRepo.GetSyncRecord(kdDokter, item.IdempotencyKey); err == nil {
+			return s.replaySync(item, record)
+		}
+		result = CpptSyncResult{Status: SyncStatusError, Message: "temporary failure, retry later"}
+	default:
+		fmt.Printf("❌ CPPT sync %s failed: %v\n", item.IdempotencyKey, err)
+		result = CpptSyncResult{Status: SyncStatusError, Message: "temporary failure, retry later"}
+	}
+	result.DraftID = item.DraftID
+	result.IdempotencyKey = item.IdempotencyKey
+	return result
+}
+
+// replaySync: hasil dari pengiriman sebelumnya, beserta isi entri terbaru jika berhasil.
+// Record yang masih pending berarti pengiriman lain belum selesai, jadi client diminta retry.
+func (s *cpptService) replaySync(item CpptSyncItem, record *CpptSyncRecord) CpptSyncResult {
+	if record.Status == SyncStatusPending {
+		return CpptSyncResult{
+			DraftID:        item.DraftID,
+			IdempotencyKey: item.IdempotencyKey,
+			Status:         SyncStatusError,
+			Message:        "draft is still being synced, retry later",
+		}
+	}
+
+	result := resultFromRecord(record)
+	if record.Status == SyncStatusApplied {
+		if entry, err := s.cpptRepo.GetCppt(record.NoRawat, record.TglPerawatan, record.JamRawat); err == nil {
+			entry.Versi = contentVersion(entry)
+			result.Entry = entry
+		}
+	}
+	return result
+}
+
+// syncCreate: catatan ditulis dengan waktu server seperti CreateCppt. client_timestamp hanya
+// dipakai untuk menolak draft yang terlalu lama/di masa depan dan disimpan di pwa_cppt_sync.
+func (s *cpptService) syncCreate(kdDokter, nmDokter string, item CpptSyncItem) (CpptSyncResult, *CpptEntry) {
+	now := time.Now()
+	writtenAt := item.ClientTimestamp.In(time.Local)
+	if writtenAt.After(now.Add(maxClockSkew)) {
+		return CpptSyncResult{Status: SyncStatusRejected, Message: "client_timestamp is in the future"}, nil
+	}
+	if now.Sub(writtenAt) > maxDraftAge {
+		return CpptSyncResult{Status: SyncStatusRejected, Message: "draft is too old to be synced"}, nil
+	}
+
+	err := s.access.CheckDPJP(item.NoRawat, kdDokter)
+	if errors.Is(err, listranap.ErrAksesDitolak) {
+		return CpptSyncResult{Status: SyncStatusRejected, Message: ErrWriteDenied.Error()}, nil
+	}
+	if err != nil {
+		return CpptSyncResult{Status: SyncStatusError, Message: "failed to check access"}, nil
+	}
+	if result, ok := s.checkDischarged(item.NoRawat); !ok {
+		return result, nil
+	}
+
+	entry, err := s.CreateCppt(item.NoRawat, kdDokter, nmDokter, item.CpptCreateRequest)
+	if errors.Is(err, ErrDuplicateEntry) {
+		// Catatan lain di detik yang sama; tidak disimpan sebagai hasil final agar bisa di-retry
+		return CpptSyncResult{Status: SyncStatusError, Message: "temporary failure, retry later"}, nil
+	}
+	if err != nil {
+		return resultFromError(err), nil
+	}
+	return CpptSyncResult{Status: SyncStatusApplied, Entry: entry}, entry
+}
+
+func (s *cpptService) syncUpdate(kdDokter string, item CpptSyncItem) (CpptSyncResult, *CpptEntry) {
+	if item.TglPerawatan == "" || item.JamRawat == "" || item.BaseVersi == "" {
+		return CpptSyncResult{Status: SyncStatusRejected, Message: "tgl_perawatan, jam_rawat and base_versi are required for update"}, nil
+	}
+
+	current, err := s.cpptRepo.GetCppt(item.NoRawat, item.TglPerawatan, item.JamRawat)
+	if err != nil {
+		return resultFromError(err), nil
+	}
+	if current.Nip != kdDokter {
+		return CpptSyncResult{Status: SyncStatusRejected, Message: ErrNotAuthor.Error()}, current
+	}
+	if result, ok := s.checkDischarged(item.NoRawat); !ok {
+		return result, current
+	}
+
+	current.Versi = contentVersion(current)
+	if current.Versi != item.BaseVersi {
+		return CpptSyncResult{
+			Status:      SyncStatusConflict,
+			Reason:      ConflictChangedOnServer,
+			Message:     "the entry was changed after this draft was made",
+			ServerEntry: current,
+		}, current
+	}
+
+	entry, err := s.UpdateCppt(item.NoRawat, item.TglPerawatan, item.JamRawat, kdDokter, CpptUpdateRequest{
+		CpptCreateRequest: item.CpptCreateRequest,
+		Alasan:            item.Alasan,
+	})
+	if errors.Is(err, ErrAlreadyVerified) {
+		return CpptSyncResult{Status: SyncStatusConflict, Reason: ConflictVerified, Message: err.Error(), ServerEntry: current}, current
+	}
+	if err != nil {
+		return resultFromError(err), current
+	}
+	return CpptSyncResult{Status: SyncStatusApplied, Entry: entry}, entry
+}
+
+// checkDischarged: draft untuk pasien yang sudah pulang menjadi konflik, bukan ditulis diam-diam
+func (s *cpptService) checkDischarged(noRawat string) (CpptSyncResult, bool) {
+	aktif, err := s.access.IsPasienAktif(noRawat)
+	if err != nil {
+		return CpptSyncResult{Status: SyncStatusError, Message: "failed to check patient status"}, false
+	}
+	if !aktif {
+		return CpptSyncResult{
+			Status:  SyncStatusConflict,
+			Reason:  ConflictPatientDischarged,
+			Message: "patient was discharged before this draft was synced",
+		}, false
+	}
+	return CpptSyncResult{}, true
+}
+
+func checkSyncItem(item CpptSyncItem) string {
+	switch {
+	case item.DraftID == "" || len(item.DraftID) > 64:
+		return "draft_id is required (max 64 characters)"
+	case item.IdempotencyKey == "" || len(item.IdempotencyKey) > 64:
+		return "idempotency_key is required (max 64 characters)"
+	case item.ClientTimestamp.IsZero():
+		return "client_timestamp is required"
+	case item.NoRawat == "":
+		return "no_rawat is required"
+	}
+	return ""
+}
+
+func resultFromError(err error) CpptSyncResult {
+	switch {
+	case errors.Is(err, ErrValidation), errors.Is(err, ErrEntryNotFound),
+		errors.Is(err, ErrWriteDenied), errors.Is(err, ErrNotAuthor), errors.Is(err, ErrEditWindowExpired):
+		return CpptSyncResult{Status: SyncStatusRejected, Message: err.Error()}
+	}
+	fmt.Printf("❌ CPPT sync item failed: %v\n", err)
+	return CpptSyncResult{Status: SyncStatusError, Message: "temporary failure, retry later"}
+}
+
+func resultFromRecord(record *CpptSyncRecord) CpptSyncResult {
+	return CpptSyncResult{
+		DraftID:        record.DraftID,
+		IdempotencyKey: record.IdempotencyKey,
+		Status:         record.Status,
+		Reason:         record.Reason,
+		Message:        record.Message,
+		Replayed:       true,
+	}
+}
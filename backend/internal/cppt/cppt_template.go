@@ -0,0 +1,278 @@
+package cppt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTemplateNotFound  = errors.New("template not found")
+	ErrTemplateForbidden = errors.New("only the creator can change this template")
+)
+
+// templatePlaceholders: placeholder yang dikenali saat expand. Placeholder lain dibiarkan apa adanya.
+var templatePlaceholders = []string{
+	"{{nama_pasien}}", "{{no_rkm_medis}}", "{{jk}}", "{{umur}}", "{{bangsal}}", "{{kamar}}",
+	"{{diagnosa_awal}}", "{{hari_rawat}}", "{{tanggal}}", "{{dokter}}",
+	"{{suhu}}", "{{tensi}}", "{{nadi}}", "{{respirasi}}", "{{spo2}}", "{{gcs}}",
+	"{{kesadaran}}", "{{berat}}", "{{tinggi}}", "{{vital_terakhir}}",
+}
+
+// TemplatePlaceholders: daftar placeholder untuk ditampilkan di editor template
+func TemplatePlaceholders() []string {
+	return templatePlaceholders
+}
+
+// ✅ Template yang bisa dipakai dokter: milik sendiri + bersama satu spesialis
+func (s *cpptService) ListTemplates(kdDokter string, filter CpptTemplateFilter) ([]CpptTemplate, error) {
+	kdSps, err := s.cpptRepo.GetKodeSps(kdDokter)
+	if err != nil {
+		return nil, err
+	}
+	filter.Q = strings.TrimSpace(filter.Q)
+	return s.cpptRepo.FindTemplates(kdDokter, kdSps, filter)
+}
+
+func (s *cpptService) CreateTemplate(kdDokter string, req CpptTemplateRequest) (*CpptTemplate, error) {
+	template := &CpptTemplate{KodeDokter: kdDokter, CreatedAt: time.Now()}
+	if err := s.applyTemplateRequest(template, req); err != nil {
+		return nil, err
+	}
+	template.UpdatedAt = template.CreatedAt
+
+	if err := s.cpptRepo.CreateTemplate(template); err != nil {
+		fmt.Printf("❌ Failed to create CPPT template: %v\n", err)
+		return nil, err
+	}
+	fmt.Printf("✅ CPPT template %d created by %s (%s)\n", template.ID, kdDokter, template.Scope)
+	return template, nil
+}
+
+func (s *cpptService) UpdateTemplate(id uint64, kdDokter string, req CpptTemplateRequest) (*CpptTemplate, error) {
+	template, err := s.ownTemplate(id, kdDokter)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyTemplateRequest(template, req); err != nil {
+		return nil, err
+	}
+	template.UpdatedAt = time.Now()
+
+	if err := s.cpptRepo.SaveTemplate(template); err != nil {
+		fmt.Printf("❌ Failed to update CPPT template: %v\n", err)
+		return nil, err
+	}
+	return template, nil
+}
+
+func (s *cpptService) DeleteTemplate(id uint64, kdDokter string) error {
+	if _, err := s.ownTemplate(id, kdDokter); err != nil {
+		return err
+	}
+	return s.cpptRepo.DeleteTemplate(id)
+}
+
+// ✅ ExpandTemplate mengisi placeholder dengan data pasien + vital terakhir, lalu menaikkan usage count
+func (s *cpptService) ExpandTemplate(id uint64, noRawat, kdDokter, nmDokter string) (*CpptTemplateExpansion, error) {
+	if err := s.access.CheckAccess(noRawat, kdDokter); err != nil {
+		return nil, err
+	}
+
+	template, err := s.getTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+	if template.KodeDokter != kdDokter {
+		kdSps, err := s.cpptRepo.GetKodeSps(kdDokter)
+		if err != nil {
+			return nil, err
+		}
+		if template.Scope != TemplateScopeDepartment || kdSps == "" || template.KodeSps != kdSps {
+			return nil, ErrTemplateNotFound
+		}
+	}
+
+	pasien, err := s.cpptRepo.GetPasienTemplateContext(noRawat)
+	if err != nil {
+		return nil, err
+	}
+	vitals, err := s.cpptRepo.GetLastVitals(noRawat)
+	if err != nil {
+		return nil, err
+	}
+
+	replacer := placeholderReplacer(pasien, vitals, nmDokter, time.Now())
+	expansion := &CpptTemplateExpansion{
+		TemplateID: template.ID,
+		Content: CpptCreateRequest{
+			Subjektif: replacer.Replace(template.Subjektif),
+			Objektif:  replacer.Replace(template.Objektif),
+			Asesmen:   replacer.Replace(template.Asesmen),
+			Plan:      replacer.Replace(template.Plan),
+			Instruksi: replacer.Replace(template.Instruksi),
+			Evaluasi:  replacer.Replace(template.Evaluasi),
+		},
+	}
+
+	if err := s.cpptRepo.IncrementTemplateUsage(template.ID, time.Now()); err != nil {
+		fmt.Printf("⚠️ Failed to count usage of CPPT template %d: %v\n", template.ID, err)
+	}
+	return expansion, nil
+}
+
+func (s *cpptService) getTemplate(id uint64) (*CpptTemplate, error) {
+	template, err := s.cpptRepo.GetTemplate(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	return template, err
+}
+
+// ownTemplate: hanya pembuat yang boleh mengubah/menghapus, juga untuk template departemen
+func (s *cpptService) ownTemplate(id uint64, kdDokter string) (*CpptTemplate, error) {
+	template, err := s.getTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+	if template.KodeDokter != kdDokter {
+		return nil, ErrTemplateForbidden
+	}
+	return template, nil
+}
+
+func (s *cpptService) applyTemplateRequest(template *CpptTemplate, req CpptTemplateRequest) error {
+	var problems []string
+
+	req.Nama = strings.TrimSpace(req.Nama)
+	if req.Nama == "" || len([]rune(req.Nama)) > 100 {
+		problems = append(problems, "nama is required (max 100 characters)")
+	}
+	req.Shortcut = strings.TrimSpace(req.Shortcut)
+	if len(req.Shortcut) > 30 || strings.ContainsAny(req.Shortcut, " \t\n") {
+		problems = append(problems, "shortcut must be a single word of at most 30 characters")
+	}
+
+	switch req.Scope {
+	case "", TemplateScopePersonal:
+		req.Scope = TemplateScopePersonal
+		template.KodeSps = ""
+	case TemplateScopeDepartment:
+		kdSps, err := s.cpptRepo.GetKodeSps(template.KodeDokter)
+		if err != nil {
+			return err
+		}
+		if kdSps == "" {
+			problems = append(problems, "department templates need a doctor with a specialty (kd_sps)")
+		}
+		template.KodeSps = kdSps
+	default:
+		problems = append(problems, "scope must be personal or department")
+	}
+
+	fields := []struct {
+		label string
+		value *string
+	}{
+		{"subjektif", &req.Subjektif},
+		{"objektif", &req.Objektif},
+		{"asesmen", &req.Asesmen},
+		{"plan", &req.Plan},
+		{"instruksi", &req.Instruksi},
+		{"evaluasi", &req.Evaluasi},
+	}
+	empty := true
+	for _, field := range fields {
+		*field.value = strings.TrimSpace(*field.value)
+		if *field.value != "" {
+			empty = false
+		}
+		if len([]rune(*field.value)) > maxSoapLength {
+			problems = append(problems, fmt.Sprintf("%s must be at most %d characters", field.label, maxSoapLength))
+		}
+	}
+	if empty {
+		problems = append(problems, "template must fill at least one SOAP field")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrValidation, strings.Join(problems, "; "))
+	}
+
+	template.Nama = req.Nama
+	template.Shortcut = req.Shortcut
+	template.Scope = req.Scope
+	template.Subjektif = req.Subjektif
+	template.Objektif = req.Objektif
+	template.Asesmen = req.Asesmen
+	template.Plan = req.Plan
+	template.Instruksi = req.Instruksi
+	template.Evaluasi = req.Evaluasi
+	return nil
+}
+
+func placeholderReplacer(pasien *PasienTemplateContext, vitals *CpptVitals, nmDokter string, now time.Time) *strings.Replacer {
+	hariRawat := ""
+	if pasien.TglMasuk != nil {
+		masuk := time.Date(pasien.TglMasuk.Year(), pasien.TglMasuk.Month(), pasien.TglMasuk.Day(), 0, 0, 0, 0, time.Local)
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		hariRawat = fmt.Sprintf("%d", int(today.Sub(masuk).Hours()/24)+1)
+	}
+
+	values := map[string]string{
+		"{{nama_pasien}}":    pasien.NmPasien,
+		"{{no_rkm_medis}}":   pasien.NoRkmMedis,
+		"{{jk}}":             pasien.Jk,
+		"{{umur}}":           pasien.Umur,
+		"{{bangsal}}":        pasien.NmBangsal,
+		"{{kamar}}":          pasien.KdKamar,
+		"{{diagnosa_awal}}":  pasien.Diagnosa,
+		"{{hari_rawat}}":     hariRawat,
+		"{{tanggal}}":        now.Format("02-01-2006"),
+		"{{dokter}}":         nmDokter,
+		"{{suhu}}":           vitals.SuhuTubuh,
+		"{{tensi}}":          vitals.Tensi,
+		"{{nadi}}":           vitals.Nadi,
+		"{{respirasi}}":      vitals.Respirasi,
+		"{{spo2}}":           vitals.SpO2,
+		"{{gcs}}":            vitals.GCS,
+		"{{kesadaran}}":      vitals.Kesadaran,
+		"{{berat}}":          vitals.Berat,
+		"{{tinggi}}":         vitals.Tinggi,
+		"{{vital_terakhir}}": vitalSummary(vitals),
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(values)*2)
+	for _, key := range keys {
+		pairs = append(pairs, key, values[key])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// vitalSummary: ringkasan satu baris, mis. "TD 120/80 mmHg, N 80 x/m, RR 20 x/m, S 36.5 °C, SpO2 98%"
+func vitalSummary(v *CpptVitals) string {
+	var parts []string
+	add := func(label, value, unit string) {
+		if value != "" {
+			parts = append(parts, label+" "+value+unit)
+		}
+	}
+	add("TD", v.Tensi, " mmHg")
+	add("N", v.Nadi, " x/m")
+	add("RR", v.Respirasi, " x/m")
+	add("S", v.SuhuTubuh, " °C")
+	add("SpO2", v.SpO2, "%")
+	add("GCS", v.GCS, "")
+	add("Kesadaran", v.Kesadaran, "")
+	return strings.Join(parts, ", ")
+}
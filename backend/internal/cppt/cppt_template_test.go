@@ -0,0 +1,38 @@
+package cppt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlaceholderReplacer(t *testing.T) {
+	masuk := time.Date(2026, 3, 8, 22, 30, 0, 0, time.Local)
+	pasien := &PasienTemplateContext{
+		NmPasien:   "Budi",
+		NoRkmMedis: "000123",
+		NmBangsal:  "Melati",
+		Diagnosa:   "DHF",
+		TglMasuk:   &masuk,
+	}
+	vitals := &CpptVitals{Tensi: "110/70", Nadi: "90", SuhuTubuh: "38.2", Kesadaran: defaultKesadaran}
+	now := time.Date(2026, 3, 10, 7, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		name   string
+		pasien *PasienTemplateContext
+		text   string
+		want   string
+	}{
+		{"patient fields", pasien, "{{nama_pasien}} ({{no_rkm_medis}}) di {{bangsal}}", "Budi (000123) di Melati"},
+		{"hari rawat counts calendar days", pasien, "Hari rawat ke-{{hari_rawat}}, {{tanggal}}", "Hari rawat ke-3, 10-03-2026"},
+		{"missing admission date", &PasienTemplateContext{}, "H{{hari_rawat}}", "H"},
+		{"vitals", pasien, "{{vital_terakhir}}", "TD 110/70 mmHg, N 90 x/m, S 38.2 °C, Kesadaran Compos Mentis"},
+		{"doctor and unknown placeholder", pasien, "{{dokter}} {{tidak_ada}}", "dr. Ani {{tidak_ada}}"},
+	}
+	for _, c := range cases {
+		got := placeholderReplacer(c.pasien, vitals, "dr. Ani", now).Replace(c.text)
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package cppt
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrValidation = errors.New("invalid cppt")
+
+// Nilai enum kolom kesadaran di pemeriksaan_ranap Khanza
+var kesadaranValues = []string{
+	"Compos Mentis", "Somnolence", "Sopor", "Coma", "Alert", "Confusion",
+	"Voice", "Pain", "Unresponsive", "Apatis", "Delirium",
+}
+
+const defaultKesadaran = "Compos Mentis"
+
+var tensiPattern = regexp.MustCompile(`^\d{2,3}/\d{2,3}$`)
+
+// Panjang maksimal kolom pemeriksaan_ranap (varchar)
+const (
+	maxSoapLength   = 2000
+	maxAlergiLength = 50
+)
+
+type numericRange struct {
+	label    string
+	maxLen   int
+	min, max float64
+}
+
+// normalizeCreateRequest merapikan spasi dan memvalidasi isi SOAP + tanda vital.
+// Semua pesan error dikumpulkan agar client bisa menandai semua field sekaligus.
+func normalizeCreateRequest(req *CpptCreateRequest) error {
+	var problems []string
+
+	fields := []struct {
+		label  string
+		value  *string
+		maxLen int
+	}{
+		{"subjektif", &req.Subjektif, maxSoapLength},
+		{"objektif", &req.Objektif, maxSoapLength},
+		{"asesmen", &req.Asesmen, maxSoapLength},
+		{"plan", &req.Plan, maxSoapLength},
+		{"instruksi", &req.Instruksi, maxSoapLength},
+		{"evaluasi", &req.Evaluasi, maxSoapLength},
+		{"alergi", &req.Alergi, maxAlergiLength},
+	}
+	for _, field := range fields {
+		*field.value = strings.TrimSpace(*field.value)
+		if len([]rune(*field.value)) > field.maxLen {
+			problems = append(problems, fmt.Sprintf("%s must be at most %d characters", field.label, field.maxLen))
+		}
+	}
+
+	if req.Subjektif == "" && req.Objektif == "" {
+		problems = append(problems, "subjektif or objektif is required")
+	}
+	if req.Asesmen == "" {
+		problems = append(problems, "asesmen is required")
+	}
+	if req.Plan == "" {
+		problems = append(problems, "plan is required")
+	}
+
+	problems = append(problems, normalizeVitals(&req.Vitals)...)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrValidation, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func normalizeVitals(v *CpptVitals) []string {
+	var problems []string
+
+	numeric := []struct {
+		value *string
+		rule  numericRange
+	}{
+		{&v.SuhuTubuh, numericRange{"suhu_tubuh", 5, 30, 45}},
+		{&v.Nadi, numericRange{"nadi", 3, 20, 300}},
+		{&v.Respirasi, numericRange{"respirasi", 3, 5, 80}},
+		{&v.Tinggi, numericRange{"tinggi", 5, 20, 250}},
+		{&v.Berat, numericRange{"berat", 5, 0.5, 400}},
+		{&v.SpO2, numericRange{"spo2", 3, 0, 100}},
+	}
+	for _, item := range numeric {
+		*item.value = strings.ReplaceAll(strings.TrimSpace(*item.value), ",", ".")
+		if *item.value == "" {
+			continue
+		}
+		if len(*item.value) > item.rule.maxLen {
+			problems = append(problems, fmt.Sprintf("%s is too long", item.rule.label))
+			continue
+		}
+		n, err := strconv.ParseFloat(*item.value, 64)
+		if err != nil || n < item.rule.min || n > item.rule.max {
+			problems = append(problems, fmt.Sprintf("%s must be between %g and %g", item.rule.label, item.rule.min, item.rule.max))
+		}
+	}
+
+	v.Tensi = strings.ReplaceAll(strings.TrimSpace(v.Tensi), " ", "")
+	if v.Tensi != "" && !tensiPattern.MatchString(v.Tensi) {
+		problems = append(problems, "tensi must be formatted as systolic/diastolic, e.g. 120/80")
+	}
+
+	v.GCS = strings.TrimSpace(v.GCS)
+	if len(v.GCS) > 10 {
+		problems = append(problems, "gcs must be at most 10 characters")
+	}
+
+	v.Kesadaran = strings.TrimSpace(v.Kesadaran)
+	if v.Kesadaran == "" {
+		v.Kesadaran = defaultKesadaran
+	} else {
+		matched := false
+		for _, value := range kesadaranValues {
+			if strings.EqualFold(value, v.Kesadaran) {
+				v.Kesadaran = value
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			problems = append(problems, "kesadaran must be one of: "+strings.Join(kesadaranValues, ", "))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,78 @@
+package cppt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeCreateRequest(t *testing.T) {
+	valid := func() CpptCreateRequest {
+		return CpptCreateRequest{Subjektif: "Demam", Asesmen: "Febris", Plan: "Paracetamol 3x500 mg"}
+	}
+
+	cases := []struct {
+		name    string
+		edit    func(req *CpptCreateRequest)
+		wantErr []string // Potongan pesan yang harus muncul; kosong = valid
+	}{
+		{"valid", func(req *CpptCreateRequest) {}, nil},
+		{"objektif only", func(req *CpptCreateRequest) { req.Subjektif, req.Objektif = "", "Tampak lemah" }, nil},
+		{"blank after trim", func(req *CpptCreateRequest) { req.Subjektif, req.Asesmen, req.Plan = "  ", " ", "\n" },
+			[]string{"subjektif or objektif is required", "asesmen is required", "plan is required"}},
+		{"too long", func(req *CpptCreateRequest) { req.Plan = strings.Repeat("x", maxSoapLength+1) },
+			[]string{"plan must be at most"}},
+		{"alergi length counts runes", func(req *CpptCreateRequest) { req.Alergi = strings.Repeat("é", maxAlergiLength) }, nil},
+		{"invalid vitals reported together", func(req *CpptCreateRequest) { req.Vitals.Nadi, req.Vitals.Tensi = "500", "120-80" },
+			[]string{"nadi must be between", "tensi must be formatted"}},
+	}
+	for _, c := range cases {
+		req := valid()
+		c.edit(&req)
+		err := normalizeCreateRequest(&req)
+		if len(c.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: err = %v, want ErrValidation", c.name, err)
+			continue
+		}
+		for _, want := range c.wantErr {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not mention %q", c.name, err, want)
+			}
+		}
+	}
+}
+
+func TestNormalizeVitals(t *testing.T) {
+	cases := []struct {
+		name     string
+		vitals   CpptVitals
+		want     CpptVitals
+		problems int
+	}{
+		{"defaults kesadaran", CpptVitals{}, CpptVitals{Kesadaran: defaultKesadaran}, 0},
+		{"normalizes input",
+			CpptVitals{SuhuTubuh: " 36,5 ", Tensi: "120 / 80", Nadi: "88", SpO2: "98", GCS: " E4V5M6 ", Kesadaran: "somnolence"},
+			CpptVitals{SuhuTubuh: "36.5", Tensi: "120/80", Nadi: "88", SpO2: "98", GCS: "E4V5M6", Kesadaran: "Somnolence"}, 0},
+		{"out of range", CpptVitals{SuhuTubuh: "50", Respirasi: "2", SpO2: "101"},
+			CpptVitals{SuhuTubuh: "50", Respirasi: "2", SpO2: "101", Kesadaran: defaultKesadaran}, 3},
+		{"not a number", CpptVitals{Berat: "abc"}, CpptVitals{Berat: "abc", Kesadaran: defaultKesadaran}, 1},
+		{"too long", CpptVitals{Tinggi: "170.55"}, CpptVitals{Tinggi: "170.55", Kesadaran: defaultKesadaran}, 1},
+		{"unknown kesadaran", CpptVitals{Kesadaran: "Pingsan"}, CpptVitals{Kesadaran: "Pingsan"}, 1},
+	}
+	for _, c := range cases {
+		vitals := c.vitals
+		problems := normalizeVitals(&vitals)
+		if len(problems) != c.problems {
+			t.Errorf("%s: got problems %q, want %d", c.name, problems, c.problems)
+		}
+		if vitals != c.want {
+			t.Errorf("%s: normalized to %+v, want %+v", c.name, vitals, c.want)
+		}
+	}
+}
@@ -52,3 +52,12 @@ func Connect(cfg *config.Config) (*gorm.DB, *sql.DB) {
 	log.Println("✅ GORM & SQL Database connected successfully (Single Pool)")
 	return db, sqlDB // Kembalikan keduanya
 }
+
+// Migrate membuat/menyesuaikan tabel milik aplikasi (bukan tabel SIMRS Khanza).
+// Tabel Khanza TIDAK boleh didaftarkan di sini.
+func Migrate(db *gorm.DB, models ...interface{}) {
+	if err := db.AutoMigrate(models...); err != nil {
+		log.Fatalf("❌ Failed to migrate app tables: %v", err)
+	}
+	log.Printf("✅ App tables migrated (%d models)", len(models))
+}
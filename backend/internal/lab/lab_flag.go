@@ -0,0 +1,136 @@
+package lab
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	numberPattern = regexp.MustCompile(`-?\d+(?:[.,]\d+)?`)
+	rangePattern  = regexp.MustCompile(`^\s*(-?\d+(?:[.,]\d+)?)\s*(?:-|–|s/d|sd)\s*(-?\d+(?:[.,]\d+)?)`)
+	limitPattern  = regexp.MustCompile(`^\s*(<=|>=|<|>|≤|≥)\s*(-?\d+(?:[.,]\d+)?)`)
+)
+
+// criticalLimit: batas nilai kritis untuk parameter umum. Dicocokkan dengan nama pemeriksaan
+// (huruf kecil, substring) dan satuan; parameter lain hanya kritis jika ditandai petugas lab.
+type criticalLimit struct {
+	names   []string
+	unit    string // Kosong = semua satuan
+	low     float64
+	high    float64
+	hasLow  bool
+	hasHigh bool
+}
+
+var criticalLimits = []criticalLimit{
+	{names: []string{"hemoglobin", "hgb"}, unit: "g/dl", low: 7, high: 20, hasLow: true, hasHigh: true},
+	{names: []string{"kalium", "potassium"}, low: 2.5, high: 6.5, hasLow: true, hasHigh: true},
+	{names: []string{"natrium", "sodium"}, low: 120, high: 160, hasLow: true, hasHigh: true},
+	{names: []string{"glukosa", "gula darah"}, unit: "mg/dl", low: 40, high: 500, hasLow: true, hasHigh: true},
+}
+
+// parseNumber: angka pertama dalam teks, koma desimal diterima ("3,5")
+func parseNumber(value string) (float64, bool) {
+	match := numberPattern.FindString(value)
+	if match == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", "."), 64)
+	return n, err == nil
+}
+
+func parseFloat(value string) float64 {
+	n, _ := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	return n
+}
+
+// flagFromRange: H/L dari nilai_rujukan bentuk "a - b", "< x", atau "> x"
+func flagFromRange(nilai float64, rujukan string) string {
+	if m := rangePattern.FindStringSubmatch(rujukan); m != nil {
+		low, high := parseFloat(m[1]), parseFloat(m[2])
+		switch {
+		case nilai < low:
+			return FlagLow
+		case nilai > high:
+			return FlagHigh
+		}
+		return FlagNormal
+	}
+
+	if m := limitPattern.FindStringSubmatch(rujukan); m != nil {
+		limit := parseFloat(m[2])
+		switch m[1] {
+		case "<":
+			if nilai >= limit {
+				return FlagHigh
+			}
+		case "<=", "≤":
+			if nilai > limit {
+				return FlagHigh
+			}
+		case ">":
+			if nilai <= limit {
+				return FlagLow
+			}
+		case ">=", "≥":
+			if nilai < limit {
+				return FlagLow
+			}
+		}
+	}
+	return FlagNormal
+}
+
+// flagFromKeterangan: tanda dari petugas lab di Khanza. ok=false jika keterangan bukan flag.
+func flagFromKeterangan(keterangan string) (flag string, kritis bool, ok bool) {
+	switch strings.ToUpper(strings.TrimSpace(keterangan)) {
+	case "L", "LOW", "RENDAH":
+		return FlagLow, false, true
+	case "H", "HIGH", "TINGGI":
+		return FlagHigh, false, true
+	case "LL":
+		return FlagLow, true, true
+	case "HH":
+		return FlagHigh, true, true
+	case "*", "K", "KRITIS", "CRITICAL":
+		return FlagNormal, true, true
+	}
+	return FlagNormal, false, false
+}
+
+func isCritical(pemeriksaan, satuan string, nilai float64) bool {
+	name := strings.ToLower(pemeriksaan)
+	unit := strings.ToLower(strings.ReplaceAll(satuan, " ", ""))
+	for _, limit := range criticalLimits {
+		if limit.unit != "" && limit.unit != unit {
+			continue
+		}
+		for _, candidate := range limit.names {
+			if strings.Contains(name, candidate) {
+				return (limit.hasLow && nilai < limit.low) || (limit.hasHigh && nilai > limit.high)
+			}
+		}
+	}
+	return false
+}
+
+// ✅ evaluate menggabungkan flag petugas lab dengan perhitungan dari nilai rujukan.
+// Flag dari petugas diutamakan; flag hitungan dipakai jika petugas tidak mengisi.
+func evaluate(row LabRow) (flag string, kritis bool) {
+	flag, kritis, marked := flagFromKeterangan(row.Keterangan)
+
+	nilai, numeric := parseNumber(row.Nilai)
+	if !numeric {
+		return flag, kritis
+	}
+	if !marked || flag == FlagNormal {
+		if computed := flagFromRange(nilai, row.NilaiRujukan); computed != FlagNormal {
+			flag = computed
+		}
+	}
+	if isCritical(row.Pemeriksaan, row.Satuan, nilai) {
+		kritis = true
+	}
+	return flag, kritis
+}
@@ -0,0 +1,121 @@
+package lab
+
+import "testing"
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   float64
+		wantOK bool
+	}{
+		{"4.2", 4.2, true},
+		{"3,5", 3.5, true},
+		{"-2", -2, true},
+		{"12.3 mg/dL", 12.3, true},
+		{"< 0.5", 0.5, true},
+		{"Positif", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseNumber(tt.value)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseNumber(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFlagFromRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		nilai   float64
+		rujukan string
+		want    string
+	}{
+		{"below range", 3.0, "3.5 - 5.0", FlagLow},
+		{"above range", 5.5, "3.5 - 5.0", FlagHigh},
+		{"inside range", 4.0, "3.5 - 5.0", FlagNormal},
+		{"on the lower bound", 3.5, "3.5 - 5.0", FlagNormal},
+		{"comma decimals", 3.0, "3,5 - 5,0", FlagLow},
+		{"s/d separator", 12, "4 s/d 11", FlagHigh},
+		{"en dash separator", 3, "4–11", FlagLow},
+		{"negative lower bound", -3, "-2 - 2", FlagLow},
+		{"less than, at limit", 1, "< 1", FlagHigh},
+		{"less than, below limit", 0.9, "<1", FlagNormal},
+		{"at most, at limit", 1, "<= 1", FlagNormal},
+		{"greater than, at limit", 40, "> 40", FlagLow},
+		{"at least, below limit", 59, "≥ 60", FlagLow},
+		{"text reference", 5, "Negatif", FlagNormal},
+		{"empty reference", 5, "", FlagNormal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flagFromRange(tt.nilai, tt.rujukan); got != tt.want {
+				t.Errorf("flagFromRange(%v, %q) = %q, want %q", tt.nilai, tt.rujukan, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCritical(t *testing.T) {
+	tests := []struct {
+		name        string
+		pemeriksaan string
+		satuan      string
+		nilai       float64
+		want        bool
+	}{
+		{"low hemoglobin", "Hemoglobin", "g/dL", 6.5, true},
+		{"normal hemoglobin", "Hemoglobin", "g/dL", 12, false},
+		{"hemoglobin in other unit", "Hemoglobin", "g/L", 65, false},
+		{"abbreviated name", "HGB", "g/dl", 21, true},
+		{"high kalium any unit", "Kalium", "mmol/L", 7, true},
+		{"normal kalium", "Kalium (K)", "mmol/L", 4, false},
+		{"low natrium", "Natrium", "mmol/L", 119, true},
+		{"low glucose", "Glukosa Darah Sewaktu", "mg/dL", 35, true},
+		{"parameter without limits", "Trombosit", "10^3/uL", 5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCritical(tt.pemeriksaan, tt.satuan, tt.nilai); got != tt.want {
+				t.Errorf("isCritical(%q, %q, %v) = %v, want %v", tt.pemeriksaan, tt.satuan, tt.nilai, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	kalium := func(nilai, keterangan string) LabRow {
+		return LabRow{Pemeriksaan: "Kalium", Satuan: "mmol/L", Nilai: nilai, NilaiRujukan: "3.5 - 5.0", Keterangan: keterangan}
+	}
+
+	tests := []struct {
+		name       string
+		row        LabRow
+		wantFlag   string
+		wantKritis bool
+	}{
+		{"normal", kalium("4.0", ""), FlagNormal, false},
+		{"computed low", kalium("3,0", ""), FlagLow, false},
+		{"computed low and critical", kalium("2.0", ""), FlagLow, true},
+		// Flag petugas lab diutamakan atas hitungan
+		{"lab flag wins", kalium("4.0", "H"), FlagHigh, false},
+		{"critical mark keeps computed flag", kalium("6.0", "*"), FlagHigh, true},
+		{"critical by lab only", kalium("4.0", "HH"), FlagHigh, true},
+		{"non numeric result", LabRow{Pemeriksaan: "HBsAg", Nilai: "Reaktif", NilaiRujukan: "Non reaktif"}, FlagNormal, false},
+		{"non numeric with lab flag", LabRow{Pemeriksaan: "HBsAg", Nilai: "Reaktif", Keterangan: "H"}, FlagHigh, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag, kritis := evaluate(tt.row)
+			if flag != tt.wantFlag || kritis != tt.wantKritis {
+				t.Errorf("evaluate() = %q, %v, want %q, %v", flag, kritis, tt.wantFlag, tt.wantKritis)
+			}
+		})
+	}
+}
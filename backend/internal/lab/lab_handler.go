@@ -0,0 +1,67 @@
+package lab
+
+import (
+	"errors"
+	"net/http"
+	"pwa-rsbw/internal/listranap"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LabHandler struct {
+	labService LabService
+}
+
+func NewLabHandler(labService LabService) *LabHandler {
+	return &LabHandler{
+		labService: labService,
+	}
+}
+
+// ✅ Hasil lab per order + tren per parameter untuk satu pasien
+func (h *LabHandler) GetLabResults(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var filter LabFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid query parameters",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response, err := h.labService.GetLabResults(noRawat, kdDokter, filter)
+	if errors.Is(err, listranap.ErrAksesDitolak) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": "Lab results are only available to the patient's DPJP",
+		})
+		return
+	}
+	if errors.Is(err, ErrInvalidFilter) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get lab results",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
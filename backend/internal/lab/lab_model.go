@@ -0,0 +1,85 @@
+package lab
+
+// LabRow: satu baris hasil dari detail_periksa_lab + template_laboratorium (hasil query mentah)
+type LabRow struct {
+	TglPeriksa    string `gorm:"column:tgl_periksa"` // YYYY-MM-DD
+	Jam           string `gorm:"column:jam"`         // HH:MM:SS
+	KdJenisPrw    string `gorm:"column:kd_jenis_prw"`
+	NmPerawatan   string `gorm:"column:nm_perawatan"`
+	Kategori      string `gorm:"column:kategori"` // PK, PA, MB
+	DokterPerujuk string `gorm:"column:dokter_perujuk"`
+	IDTemplate    string `gorm:"column:id_template"`
+	Pemeriksaan   string `gorm:"column:pemeriksaan"`
+	Satuan        string `gorm:"column:satuan"`
+	Nilai         string `gorm:"column:nilai"`
+	NilaiRujukan  string `gorm:"column:nilai_rujukan"`
+	Keterangan    string `gorm:"column:keterangan"` // Flag yang diisi petugas lab di Khanza (L, H, *, dll.)
+}
+
+// Flag hasil
+const (
+	FlagNormal = ""
+	FlagHigh   = "H"
+	FlagLow    = "L"
+)
+
+// LabResult: satu parameter hasil
+type LabResult struct {
+	IDTemplate   string `json:"id_template"`
+	Pemeriksaan  string `json:"pemeriksaan"`
+	Nilai        string `json:"nilai"`
+	Satuan       string `json:"satuan"`
+	NilaiRujukan string `json:"nilai_rujukan"`
+	Flag         string `json:"flag"`   // "", H, L
+	Kritis       bool   `json:"kritis"` // Nilai kritis, perlu tindakan segera
+	Keterangan   string `json:"keterangan,omitempty"`
+}
+
+// LabPanel: satu jenis pemeriksaan (mis. Darah Lengkap) dalam satu order
+type LabPanel struct {
+	KdJenisPrw  string      `json:"kd_jenis_prw"`
+	NmPerawatan string      `json:"nm_perawatan"`
+	Kategori    string      `json:"kategori"`
+	Hasil       []LabResult `json:"hasil"`
+}
+
+// LabOrder: semua pemeriksaan dengan tanggal + jam yang sama
+type LabOrder struct {
+	TglPeriksa    string     `json:"tgl_periksa"`
+	Jam           string     `json:"jam"`
+	DokterPerujuk string     `json:"dokter_perujuk"`
+	Abnormal      int        `json:"abnormal"` // Jumlah hasil H/L
+	Kritis        int        `json:"kritis"`
+	Panel         []LabPanel `json:"panel"`
+}
+
+// LabTrendPoint: satu nilai numerik parameter pada satu waktu
+type LabTrendPoint struct {
+	TglPeriksa string  `json:"tgl_periksa"`
+	Jam        string  `json:"jam"`
+	Nilai      float64 `json:"nilai"`
+	Flag       string  `json:"flag"`
+}
+
+// LabTrend: deret waktu satu parameter (hanya hasil numerik), terlama di depan
+type LabTrend struct {
+	KdJenisPrw   string          `json:"kd_jenis_prw"`
+	IDTemplate   string          `json:"id_template"`
+	Pemeriksaan  string          `json:"pemeriksaan"`
+	Satuan       string          `json:"satuan"`
+	NilaiRujukan string          `json:"nilai_rujukan"` // Dari hasil terakhir
+	Data         []LabTrendPoint `json:"data"`
+}
+
+type LabFilter struct {
+	DateFrom string `form:"date_from"` // YYYY-MM-DD
+	DateTo   string `form:"date_to"`   // YYYY-MM-DD (inklusif)
+}
+
+type LabResponse struct {
+	Status  string     `json:"status"`
+	Message string     `json:"message"`
+	NoRawat string     `json:"no_rawat"`
+	Orders  []LabOrder `json:"orders"` // Terbaru di atas
+	Trends  []LabTrend `json:"trends"`
+}
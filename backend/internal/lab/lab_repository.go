@@ -0,0 +1,62 @@
+package lab
+
+import (
+	"gorm.io/gorm"
+)
+
+type LabRepository interface {
+	FindLabResults(noRawat string, filter LabFilter) ([]LabRow, error)
+}
+
+type labRepository struct {
+	db *gorm.DB
+}
+
+func NewLabRepository(db *gorm.DB) LabRepository {
+	return &labRepository{
+		db: db,
+	}
+}
+
+// ✅ Hasil lab satu rawat inap, terbaru di atas, urut sesuai template_laboratorium
+func (r *labRepository) FindLabResults(noRawat string, filter LabFilter) ([]LabRow, error) {
+	query := `
+	SELECT
+		DATE_FORMAT(dpl.tgl_periksa, '%Y-%m-%d') as tgl_periksa,
+		CAST(dpl.jam AS CHAR) as jam,
+		dpl.kd_jenis_prw,
+		COALESCE(jpl.nm_perawatan, dpl.kd_jenis_prw) as nm_perawatan,
+		COALESCE(pl.kategori, '') as kategori,
+		COALESCE(d.nm_dokter, pl.dokter_perujuk, '') as dokter_perujuk,
+		CAST(dpl.id_template AS CHAR) as id_template,
+		COALESCE(tl.Pemeriksaan, '') as pemeriksaan,
+		COALESCE(tl.satuan, '') as satuan,
+		COALESCE(dpl.nilai, '') as nilai,
+		COALESCE(dpl.nilai_rujukan, '') as nilai_rujukan,
+		COALESCE(dpl.keterangan, '') as keterangan
+	FROM detail_periksa_lab dpl
+	JOIN periksa_lab pl ON pl.no_rawat = dpl.no_rawat AND pl.kd_jenis_prw = dpl.kd_jenis_prw
+		AND pl.tgl_periksa = dpl.tgl_periksa AND pl.jam = dpl.jam
+	LEFT JOIN jns_perawatan_lab jpl ON dpl.kd_jenis_prw = jpl.kd_jenis_prw
+	LEFT JOIN template_laboratorium tl ON dpl.id_template = tl.id_template
+	LEFT JOIN dokter d ON pl.dokter_perujuk = d.kd_dokter
+	WHERE dpl.no_rawat = ?`
+	args := []interface{}{noRawat}
+
+	if filter.DateFrom != "" {
+		query += " AND dpl.tgl_periksa >= ?"
+		args = append(args, filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		query += " AND dpl.tgl_periksa <= ?"
+		args = append(args, filter.DateTo)
+	}
+	query += " ORDER BY dpl.tgl_periksa DESC, dpl.jam DESC, dpl.kd_jenis_prw, tl.urut, dpl.id_template"
+
+	var rows []LabRow
+	err := r.db.Raw(query, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,143 @@
+package lab
+
+import (
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/listranap"
+	"time"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+type LabService interface {
+	GetLabResults(noRawat, kdDokter string, filter LabFilter) (*LabResponse, error)
+}
+
+type labService struct {
+	labRepo LabRepository
+	access  listranap.AccessChecker
+}
+
+func NewLabService(labRepo LabRepository, access listranap.AccessChecker) LabService {
+	return &labService{
+		labRepo: labRepo,
+		access:  access,
+	}
+}
+
+// ✅ GetLabResults: hanya untuk DPJP pasien; akses darurat (break-the-glass) tidak membuka hasil lab
+func (s *labService) GetLabResults(noRawat, kdDokter string, filter LabFilter) (*LabResponse, error) {
+	fmt.Printf("🔍 Getting lab results for: %s by doctor: %s\n", noRawat, kdDokter)
+
+	if err := s.access.CheckDPJP(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ Lab access denied for %s: %v\n", kdDokter, err)
+		return nil, err
+	}
+
+	for _, value := range []string{filter.DateFrom, filter.DateTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, fmt.Errorf("%w: date_from/date_to must be YYYY-MM-DD", ErrInvalidFilter)
+		}
+	}
+
+	rows, err := s.labRepo.FindLabResults(noRawat, filter)
+	if err != nil {
+		fmt.Printf("❌ Error getting lab results: %v\n", err)
+		return nil, err
+	}
+
+	orders, trends := groupResults(rows)
+
+	fmt.Printf("✅ Found %d lab orders for %s\n", len(orders), noRawat)
+	return &LabResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Found %d lab orders", len(orders)),
+		NoRawat: noRawat,
+		Orders:  orders,
+		Trends:  trends,
+	}, nil
+}
+
+// groupResults: baris (terbaru di atas) → order per tanggal+jam → panel per jenis pemeriksaan,
+// sekaligus deret tren per parameter (terlama di depan)
+func groupResults(rows []LabRow) ([]LabOrder, []LabTrend) {
+	orders := []LabOrder{}
+	trends := []LabTrend{}
+	trendIndex := map[string]int{}
+
+	for _, row := range rows {
+		if len(orders) == 0 || orders[len(orders)-1].TglPeriksa != row.TglPeriksa || orders[len(orders)-1].Jam != row.Jam {
+			orders = append(orders, LabOrder{
+				TglPeriksa:    row.TglPeriksa,
+				Jam:           row.Jam,
+				DokterPerujuk: row.DokterPerujuk,
+				Panel:         []LabPanel{},
+			})
+		}
+		order := &orders[len(orders)-1]
+
+		if len(order.Panel) == 0 || order.Panel[len(order.Panel)-1].KdJenisPrw != row.KdJenisPrw {
+			order.Panel = append(order.Panel, LabPanel{
+				KdJenisPrw:  row.KdJenisPrw,
+				NmPerawatan: row.NmPerawatan,
+				Kategori:    row.Kategori,
+				Hasil:       []LabResult{},
+			})
+		}
+		panel := &order.Panel[len(order.Panel)-1]
+
+		flag, kritis := evaluate(row)
+		if flag != FlagNormal {
+			order.Abnormal++
+		}
+		if kritis {
+			order.Kritis++
+		}
+		panel.Hasil = append(panel.Hasil, LabResult{
+			IDTemplate:   row.IDTemplate,
+			Pemeriksaan:  row.Pemeriksaan,
+			Nilai:        row.Nilai,
+			Satuan:       row.Satuan,
+			NilaiRujukan: row.NilaiRujukan,
+			Flag:         flag,
+			Kritis:       kritis,
+			Keterangan:   row.Keterangan,
+		})
+
+		nilai, numeric := parseNumber(row.Nilai)
+		if !numeric {
+			continue
+		}
+		key := row.KdJenisPrw + "|" + row.IDTemplate
+		i, ok := trendIndex[key]
+		if !ok {
+			i = len(trends)
+			trendIndex[key] = i
+			trends = append(trends, LabTrend{
+				KdJenisPrw:   row.KdJenisPrw,
+				IDTemplate:   row.IDTemplate,
+				Pemeriksaan:  row.Pemeriksaan,
+				Satuan:       row.Satuan,
+				NilaiRujukan: row.NilaiRujukan,
+			})
+		}
+		trends[i].Data = append(trends[i].Data, LabTrendPoint{
+			TglPeriksa: row.TglPeriksa,
+			Jam:        row.Jam,
+			Nilai:      nilai,
+			Flag:       flag,
+		})
+	}
+
+	// Baris datang terbaru dulu, tren ditampilkan kronologis
+	for i := range trends {
+		data := trends[i].Data
+		for l, r := 0, len(data)-1; l < r; l, r = l+1, r-1 {
+			data[l], data[r] = data[r], data[l]
+		}
+	}
+	return orders, trends
+}
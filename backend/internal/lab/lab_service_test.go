@@ -0,0 +1,53 @@
+package lab
+
+import (
+	"errors"
+	"pwa-rsbw/internal/listranap"
+	"testing"
+)
+
+// fakeAccess: D001 DPJP pasien, D002 hanya punya akses darurat
+type fakeAccess struct {
+	listranap.AccessChecker
+}
+
+func (fakeAccess) CheckAccess(noRawat, kdDokter string) error {
+	if kdDokter == "D001" || kdDokter == "D002" {
+		return nil
+	}
+	return listranap.ErrAksesDitolak
+}
+
+func (fakeAccess) CheckDPJP(noRawat, kdDokter string) error {
+	if kdDokter == "D001" {
+		return nil
+	}
+	return listranap.ErrAksesDitolak
+}
+
+type fakeLabRepo struct {
+	LabRepository
+}
+
+func (fakeLabRepo) FindLabResults(noRawat string, filter LabFilter) ([]LabRow, error) {
+	return nil, nil
+}
+
+func TestGetLabResultsRequiresDPJP(t *testing.T) {
+	service := NewLabService(fakeLabRepo{}, fakeAccess{})
+
+	tests := []struct {
+		kdDokter string
+		wantErr  error
+	}{
+		{"D001", nil},
+		{"D002", listranap.ErrAksesDitolak}, // Akses darurat tidak cukup untuk hasil lab
+		{"D003", listranap.ErrAksesDitolak},
+	}
+	for _, tt := range tests {
+		_, err := service.GetLabResults("2025/01/31/000001", tt.kdDokter, LabFilter{})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("GetLabResults by %s: err = %v, want %v", tt.kdDokter, err, tt.wantErr)
+		}
+	}
+}
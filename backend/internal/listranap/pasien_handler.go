@@ -1,9 +1,11 @@
 package listranap
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type PasienHandler struct {
@@ -18,6 +20,25 @@ func NewPasienHandler(pasienService PasienService) *PasienHandler {
 
 // ✅ ENHANCED: Support filter parameter untuk CPPT
 func (h *PasienHandler) GetPasienRawatInapAktif(c *gin.Context) {
+	// ✅ Service account (API key): list per bangsal. Bangsal dari key selalu menang atas query param
+	if c.GetString("api_key_id") != "" {
+		kdBangsal := c.GetString("kd_bangsal")
+		if kdBangsal == "" {
+			kdBangsal = c.Query("kd_bangsal")
+		}
+
+		response, err := h.pasienService.GetPasienAktifByBangsal(kdBangsal)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to get patient list",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
 	kdDokter := c.GetString("kd_dokter")
 	if kdDokter == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -29,8 +50,16 @@ func (h *PasienHandler) GetPasienRawatInapAktif(c *gin.Context) {
 
 	// ✅ TAMBAH: Get filter parameter
 	filter := c.DefaultQuery("filter", "all") // "all", "sudah_cppt", "belum_cppt"
+	tanggal := c.Query("date")                // Opsional, YYYY-MM-DD untuk list riwayat
 
-	response, err := h.pasienService.GetPasienAktifByDokterWithFilter(kdDokter, filter)
+	response, err := h.pasienService.GetPasienAktifByDokterWithFilter(kdDokter, filter, tanggal)
+	if errors.Is(err, ErrTanggalTidakValid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -64,12 +93,74 @@ func (h *PasienHandler) GetPasienDetail(c *gin.Context) {
 		return
 	}
 
+	// ✅ Tandai di audit log jika dibuka lewat akses darurat
+	if pasien.AksesDarurat != nil {
+		c.Set("audit_flagged", true)
+		c.Set("audit_detail", "break_the_glass: "+pasien.AksesDarurat.Alasan)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data":   pasien,
 	})
 }
 
+// ✅ Break-the-glass: minta akses darurat ke pasien yang bukan DPJP
+func (h *PasienHandler) RequestEmergencyAccess(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var req EmergencyAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Selalu di-flag di audit log, termasuk permintaan yang gagal
+	c.Set("audit_flagged", true)
+	c.Set("audit_detail", req.Alasan)
+
+	access, err := h.pasienService.RequestEmergencyAccess(noRawat, c.GetString("id_user"), kdDokter, req.Alasan)
+	if errors.Is(err, ErrAlasanTerlaluPendek) || errors.Is(err, ErrAlasanTerlaluPanjang) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Patient not found or not currently admitted",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to grant emergency access",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "Emergency access granted. The DPJP has been notified",
+		"data":    access,
+	})
+}
+
 // Profile dokter yang login
 func (h *PasienHandler) GetDokterProfile(c *gin.Context) {
 	kdDokter := c.GetString("kd_dokter")
@@ -92,3 +183,77 @@ func (h *PasienHandler) GetDokterProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// ✅ Riwayat pasien yang sudah pulang (paging + filter tanggal keluar)
+func (h *PasienHandler) GetRiwayatPasien(c *gin.Context) {
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var filter RiwayatFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid query parameters",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response, err := h.pasienService.GetRiwayatPasien(kdDokter, filter)
+	if errors.Is(err, ErrFilterTidakValid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get patient history",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Detail rawat inap yang sudah pulang
+func (h *PasienHandler) GetRiwayatDetail(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	riwayat, err := h.pasienService.GetRiwayatDetail(noRawat, kdDokter)
+	if errors.Is(err, ErrAksesDitolak) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": "Not the DPJP of this patient",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Discharged stay not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   riwayat,
+	})
+}
@@ -22,6 +22,9 @@ type PasienRawatInap struct {
 	CpptStatus        string     `json:"cppt_status" gorm:"column:cppt_status"`               // "done", "pending", "new"
 	JumlahCpptHariIni int        `json:"jumlah_cppt_hari_ini" gorm:"column:jumlah_cppt"`      // Jumlah CPPT hari ini
 	CpptTerakhir      *time.Time `json:"cppt_terakhir,omitempty" gorm:"column:cppt_terakhir"` // Tanggal CPPT terakhir
+
+	// ✅ Diisi jika detail dibuka lewat akses darurat (bukan DPJP)
+	AksesDarurat *EmergencyAccess `json:"akses_darurat,omitempty" gorm:"-"`
 }
 
 // Response structure
@@ -69,3 +72,59 @@ type DokterProfileResponse struct {
 type PasienFilterRequest struct {
 	Filter string `json:"filter" form:"filter"` // "all", "sudah_cppt", "belum_cppt", "pasien_baru"
 }
+
+// ✅ Akses darurat (break-the-glass) ke pasien yang bukan DPJP-nya
+type EmergencyAccess struct {
+	ID         uint64    `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	IDUser     string    `json:"id_user" gorm:"column:id_user;size:64"`
+	KodeDokter string    `json:"kd_dokter" gorm:"column:kd_dokter;size:20;index:idx_emergency_dokter_rawat"`
+	NoRawat    string    `json:"no_rawat" gorm:"column:no_rawat;size:17;index:idx_emergency_dokter_rawat"`
+	Alasan     string    `json:"alasan" gorm:"column:alasan;size:500"`
+	GrantedAt  time.Time `json:"granted_at" gorm:"column:granted_at"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"column:expires_at"`
+}
+
+func (EmergencyAccess) TableName() string {
+	return "pwa_emergency_access"
+}
+
+type EmergencyAccessRequest struct {
+	Alasan string `json:"alasan" binding:"required,max=500"` // Alasan klinis, wajib diisi
+}
+
+// ✅ Riwayat rawat inap yang sudah pulang (per DPJP)
+type PasienRiwayat struct {
+	NoRawat         string     `json:"no_rawat" gorm:"column:no_rawat"`
+	NoRKMMedis      string     `json:"no_rkm_medis" gorm:"column:no_rkm_medis"`
+	NamaPasien      string     `json:"nm_pasien" gorm:"column:nm_pasien"`
+	PenanggungJawab string     `json:"penanggung_jawab" gorm:"column:penanggung_jawab"`
+	KodeKamar       string     `json:"kd_kamar" gorm:"column:kd_kamar"` // Kamar terakhir
+	NamaBangsal     string     `json:"nm_bangsal" gorm:"column:nm_bangsal"`
+	DiagnosaAwal    string     `json:"diagnosa_awal" gorm:"column:diagnosa_awal"`
+	DiagnosaAkhir   string     `json:"diagnosa_akhir" gorm:"column:diagnosa_akhir"` // kamar_inap.diagnosa_akhir, fallback diagnosa utama ICD-10
+	KodePenyakit    string     `json:"kd_penyakit,omitempty" gorm:"column:kd_penyakit"`
+	TanggalMasuk    time.Time  `json:"tgl_masuk" gorm:"column:tgl_masuk"` // Masuk pertama (sebelum pindah kamar)
+	TanggalKeluar   *time.Time `json:"tgl_keluar" gorm:"column:tgl_keluar"`
+	JamKeluar       string     `json:"jam_keluar" gorm:"column:jam_keluar"`
+	StatusPulang    string     `json:"stts_pulang" gorm:"column:stts_pulang"`
+	LamaRawat       int        `json:"lama_rawat" gorm:"column:lama_rawat"` // Hari
+	JumlahCppt      int        `json:"jumlah_cppt" gorm:"column:jumlah_cppt"`
+}
+
+// Filter riwayat: rentang tanggal keluar, pencarian nama / no RM
+type RiwayatFilter struct {
+	DateFrom string `form:"date_from"` // YYYY-MM-DD, tgl_keluar
+	DateTo   string `form:"date_to"`   // YYYY-MM-DD (inklusif)
+	Q        string `form:"q"`
+	Page     int    `form:"page"`
+	Limit    int    `form:"limit"`
+}
+
+type RiwayatListResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Total   int64           `json:"total"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+	Data    []PasienRiwayat `json:"data"`
+}
@@ -1,14 +1,32 @@
 package listranap
 
 import (
+	"fmt"
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type PasienRepository interface {
 	GetPasienRawatInapByDokter(kdDokter string) ([]PasienRawatInap, error)
-	GetPasienRawatInapByDokterWithCppt(kdDokter string, filter string) ([]PasienRawatInap, error)
+	GetPasienRawatInapByDokterWithCppt(kdDokter string, filter string, tanggal string) ([]PasienRawatInap, error)
 	GetPasienDetail(noRawat string, kdDokter string) (*PasienRawatInap, error)
 	GetDokterProfile(kdDokter string) (*DokterProfile, error)
+
+	// Akses darurat (break-the-glass)
+	GetPasienAktifByNoRawat(noRawat string, kdDokter string) (*PasienRawatInap, error)
+	GetDPJPByNoRawat(noRawat string) ([]string, error)
+	CreateEmergencyAccess(access *EmergencyAccess) error
+	GetActiveEmergencyAccess(noRawat string, kdDokter string, now time.Time) (*EmergencyAccess, error)
+	IsDPJP(noRawat string, kdDokter string) (bool, error)
+	IsPasienAktif(noRawat string) (bool, error)
+
+	// Riwayat pasien yang sudah pulang
+	FindRiwayatByDokter(kdDokter string, filter RiwayatFilter) ([]PasienRiwayat, int64, error)
+	GetRiwayatDetail(noRawat string) (*PasienRiwayat, error)
+
+	// Service account (API key): list per bangsal, bukan per dokter
+	GetPasienRawatInapByBangsal(kdBangsal string) ([]PasienRawatInap, error)
 }
 
 type pasienRepository struct {
@@ -22,12 +40,24 @@ func NewPasienRepository(db *gorm.DB) PasienRepository {
 }
 
 func (r *pasienRepository) GetPasienRawatInapByDokter(kdDokter string) ([]PasienRawatInap, error) {
-	return r.GetPasienRawatInapByDokterWithCppt(kdDokter, "all")
+	return r.GetPasienRawatInapByDokterWithCppt(kdDokter, "all", "")
 }
 
-// ✅ DIPERBARUI: Query dengan 3 status CPPT
-func (r *pasienRepository) GetPasienRawatInapByDokterWithCppt(kdDokter string, filter string) ([]PasienRawatInap, error) {
+// ✅ DIPERBARUI: Query dengan 3 status CPPT.
+// tanggal kosong = hari ini (pasien yang masih dirawat); tanggal YYYY-MM-DD = rekonstruksi
+// pasien yang dirawat pada hari itu dari tgl_masuk/tgl_keluar, status CPPT relatif ke tanggal itu.
+func (r *pasienRepository) GetPasienRawatInapByDokterWithCppt(kdDokter string, filter string, tanggal string) ([]PasienRawatInap, error) {
 	var pasienList []PasienRawatInap
+	var args []interface{}
+
+	// Tanggal acuan: CURDATE() untuk list live, parameter untuk riwayat
+	onDate := func() string {
+		if tanggal == "" {
+			return "CURDATE()"
+		}
+		args = append(args, tanggal)
+		return "?"
+	}
 
 	query := `
 	SELECT 
@@ -46,7 +76,7 @@ func (r *pasienRepository) GetPasienRawatInapByDokterWithCppt(kdDokter string, f
 		-- ✅ DIPERBARUI: Logika CASE untuk 3 status (new, done, pending)
 		CASE 
 			-- Kriteria 1: Pasien baru masuk hari ini
-			WHEN DATE(ki.tgl_masuk) = CURDATE() THEN 'new'
+			WHEN DATE(ki.tgl_masuk) = ` + onDate() + ` THEN 'new'
 			-- Kriteria 2: Sudah ada CPPT hari ini (pasien lama)
 			WHEN cppt_today.jumlah_cppt > 0 THEN 'done'
 			-- Lainnya (Pasien lama, belum CPPT)
@@ -67,37 +97,59 @@ func (r *pasienRepository) GetPasienRawatInapByDokterWithCppt(kdDokter string, f
 	LEFT JOIN (
 		SELECT no_rawat, COUNT(*) as jumlah_cppt
 		FROM pemeriksaan_ranap 
-		WHERE DATE(tgl_perawatan) = CURDATE() AND nip = ?
+		WHERE DATE(tgl_perawatan) = ` + onDate() + ` AND nip = ?
 		GROUP BY no_rawat
-	) cppt_today ON ki.no_rawat = cppt_today.no_rawat
-	-- CPPT terakhir
+	) cppt_today ON ki.no_rawat = cppt_today.no_rawat`
+	args = append(args, kdDokter)
+
+	query += `
+	-- CPPT terakhir (sampai tanggal acuan)
 	LEFT JOIN (
 		SELECT no_rawat, MAX(tgl_perawatan) as cppt_terakhir
 		FROM pemeriksaan_ranap 
-		WHERE nip = ?
+		WHERE tgl_perawatan <= ` + onDate() + ` AND nip = ?
 		GROUP BY no_rawat
-	) cppt_last ON ki.no_rawat = cppt_last.no_rawat
-	WHERE ki.stts_pulang = '-' 
+	) cppt_last ON ki.no_rawat = cppt_last.no_rawat`
+	args = append(args, kdDokter)
+
+	if tanggal == "" {
+		query += `
+	WHERE ki.stts_pulang = '-'`
+	} else {
+		// Baris kamar_inap yang mencakup tanggal itu. Pindah kamar di hari yang sama
+		// menghasilkan beberapa baris, ambil kamar terakhir saja.
+		query += `
+	WHERE ki.tgl_masuk <= ? AND (ki.stts_pulang = '-' OR ki.tgl_keluar >= ?)
+	AND NOT EXISTS (
+		SELECT 1 FROM kamar_inap ki2
+		WHERE ki2.no_rawat = ki.no_rawat
+		AND ki2.tgl_masuk <= ? AND (ki2.stts_pulang = '-' OR ki2.tgl_keluar >= ?)
+		AND (ki2.tgl_masuk > ki.tgl_masuk OR (ki2.tgl_masuk = ki.tgl_masuk AND ki2.jam_masuk > ki.jam_masuk))
+	)`
+		args = append(args, tanggal, tanggal, tanggal, tanggal)
+	}
+	query += `
 	AND d.kd_dokter = ?`
+	args = append(args, kdDokter)
 
 	// ✅ DIPERBARUI: Filter berdasarkan 3 status
 	switch filter {
 	case "sudah_cppt":
 		// Hanya pasien lama yang sudah CPPT
-		query += " AND cppt_today.jumlah_cppt > 0 AND DATE(ki.tgl_masuk) != CURDATE()"
+		query += " AND cppt_today.jumlah_cppt > 0 AND DATE(ki.tgl_masuk) != " + onDate()
 	case "belum_cppt":
 		// Hanya pasien lama yang belum CPPT
 		query += " AND (cppt_today.jumlah_cppt IS NULL OR cppt_today.jumlah_cppt = 0)"
-		query += " AND DATE(ki.tgl_masuk) != CURDATE()"
+		query += " AND DATE(ki.tgl_masuk) != " + onDate()
 	case "pasien_baru":
 		// ✅ TAMBAH: Hanya pasien baru
-		query += " AND DATE(ki.tgl_masuk) = CURDATE()"
+		query += " AND DATE(ki.tgl_masuk) = " + onDate()
 	}
 
 	// ✅ DIPERBARUI: Order by status dulu, agar pending/kuning di atas
 	query += " ORDER BY CASE cppt_status WHEN 'pending' THEN 1 WHEN 'new' THEN 2 WHEN 'done' THEN 3 ELSE 4 END, b.nm_bangsal, k.kd_kamar, ki.tgl_masuk"
 
-	err := r.db.Raw(query, kdDokter, kdDokter, kdDokter).Scan(&pasienList).Error
+	err := r.db.Raw(query, args...).Scan(&pasienList).Error
 	if err != nil {
 		return nil, err
 	}
@@ -187,3 +239,278 @@ func (r *pasienRepository) GetDokterProfile(kdDokter string) (*DokterProfile, er
 
 	return &dokter, nil
 }
+
+// ✅ Detail pasien aktif TANPA syarat DPJP (hanya untuk akses darurat yang sudah di-grant).
+// Data dokter yang tampil adalah DPJP pasien, CPPT dihitung untuk dokter yang mengakses.
+func (r *pasienRepository) GetPasienAktifByNoRawat(noRawat string, kdDokter string) (*PasienRawatInap, error) {
+	var pasien PasienRawatInap
+
+	query := `
+	SELECT 
+		ki.no_rawat,
+		p.no_rkm_medis,
+		p.nm_pasien,
+		COALESCE(pj.png_jawab, 'N/A') as penanggung_jawab,
+		k.kd_kamar,
+		b.nm_bangsal,
+		ki.diagnosa_awal,
+		ki.tgl_masuk,
+		COALESCE(d.nm_dokter, '') as nm_dokter,
+		COALESCE(d.kd_dokter, '') as kd_dokter,
+		COALESCE(d.no_telp, '') as no_telp,
+		CASE 
+			WHEN DATE(ki.tgl_masuk) = CURDATE() THEN 'new'
+			WHEN cppt_today.jumlah_cppt > 0 THEN 'done'
+			ELSE 'pending' 
+		END as cppt_status,
+		COALESCE(cppt_today.jumlah_cppt, 0) as jumlah_cppt,
+		cppt_last.cppt_terakhir
+	FROM kamar_inap ki
+	JOIN reg_periksa rp ON ki.no_rawat = rp.no_rawat
+	JOIN pasien p ON rp.no_rkm_medis = p.no_rkm_medis
+	LEFT JOIN dpjp_ranap dr ON ki.no_rawat = dr.no_rawat
+	LEFT JOIN dokter d ON dr.kd_dokter = d.kd_dokter
+	JOIN kamar k ON ki.kd_kamar = k.kd_kamar
+	JOIN bangsal b ON k.kd_bangsal = b.kd_bangsal
+	LEFT JOIN penjab pj ON rp.kd_pj = pj.kd_pj
+	LEFT JOIN (
+		SELECT no_rawat, COUNT(*) as jumlah_cppt
+		FROM pemeriksaan_ranap 
+		WHERE DATE(tgl_perawatan) = CURDATE() AND nip = ?
+		GROUP BY no_rawat
+	) cppt_today ON ki.no_rawat = cppt_today.no_rawat
+	LEFT JOIN (
+		SELECT no_rawat, MAX(tgl_perawatan) as cppt_terakhir
+		FROM pemeriksaan_ranap 
+		WHERE nip = ?
+		GROUP BY no_rawat
+	) cppt_last ON ki.no_rawat = cppt_last.no_rawat
+	WHERE ki.stts_pulang = '-'
+	AND ki.no_rawat = ?
+	LIMIT 1`
+
+	err := r.db.Raw(query, kdDokter, kdDokter, noRawat).Scan(&pasien).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if pasien.NoRawat == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &pasien, nil
+}
+
+// Semua DPJP untuk satu no_rawat (untuk notifikasi akses darurat)
+func (r *pasienRepository) GetDPJPByNoRawat(noRawat string) ([]string, error) {
+	var kdDokterList []string
+	err := r.db.Raw(`SELECT kd_dokter FROM dpjp_ranap WHERE no_rawat = ?`, noRawat).Scan(&kdDokterList).Error
+	if err != nil {
+		return nil, err
+	}
+	return kdDokterList, nil
+}
+
+// IsDPJP: dokter tercatat sebagai DPJP (termasuk pasien yang sudah pulang)
+func (r *pasienRepository) IsDPJP(noRawat string, kdDokter string) (bool, error) {
+	var count int64
+	err := r.db.Raw(`SELECT COUNT(*) FROM dpjp_ranap WHERE no_rawat = ? AND kd_dokter = ?`, noRawat, kdDokter).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// IsPasienAktif: masih dirawat (belum pulang) di kamar_inap
+func (r *pasienRepository) IsPasienAktif(noRawat string) (bool, error) {
+	var count int64
+	err := r.db.Raw(`SELECT COUNT(*) FROM kamar_inap WHERE no_rawat = ? AND stts_pulang = '-'`, noRawat).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *pasienRepository) CreateEmergencyAccess(access *EmergencyAccess) error {
+	return r.db.Create(access).Error
+}
+
+func (r *pasienRepository) GetActiveEmergencyAccess(noRawat string, kdDokter string, now time.Time) (*EmergencyAccess, error) {
+	var access EmergencyAccess
+	err := r.db.Where("no_rawat = ? AND kd_dokter = ? AND expires_at > ?", noRawat, kdDokter, now).
+		Order("expires_at DESC").
+		First(&access).Error
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// ✅ Pasien aktif per bangsal (kdBangsal kosong = semua bangsal) untuk papan informasi.
+// DPJP digabung dalam satu baris, status CPPT dihitung dari CPPT siapa pun hari ini.
+func (r *pasienRepository) GetPasienRawatInapByBangsal(kdBangsal string) ([]PasienRawatInap, error) {
+	var pasienList []PasienRawatInap
+
+	query := `
+	SELECT 
+		ki.no_rawat,
+		p.no_rkm_medis,
+		p.nm_pasien,
+		COALESCE(pj.png_jawab, 'N/A') as penanggung_jawab,
+		k.kd_kamar,
+		b.nm_bangsal,
+		ki.diagnosa_awal,
+		ki.tgl_masuk,
+		COALESCE(dpjp.kd_dokter, '') as kd_dokter,
+		COALESCE(dpjp.nm_dokter, '') as nm_dokter,
+		'' as no_telp,
+		CASE 
+			WHEN DATE(ki.tgl_masuk) = CURDATE() THEN 'new'
+			WHEN cppt_today.jumlah_cppt > 0 THEN 'done'
+			ELSE 'pending' 
+		END as cppt_status,
+		COALESCE(cppt_today.jumlah_cppt, 0) as jumlah_cppt,
+		cppt_last.cppt_terakhir
+	FROM kamar_inap ki
+	JOIN reg_periksa rp ON ki.no_rawat = rp.no_rawat
+	JOIN pasien p ON rp.no_rkm_medis = p.no_rkm_medis
+	JOIN kamar k ON ki.kd_kamar = k.kd_kamar
+	JOIN bangsal b ON k.kd_bangsal = b.kd_bangsal
+	LEFT JOIN penjab pj ON rp.kd_pj = pj.kd_pj
+	LEFT JOIN (
+		SELECT dr.no_rawat,
+			GROUP_CONCAT(d.kd_dokter SEPARATOR ', ') as kd_dokter,
+			GROUP_CONCAT(d.nm_dokter SEPARATOR ', ') as nm_dokter
+		FROM dpjp_ranap dr
+		JOIN dokter d ON dr.kd_dokter = d.kd_dokter
+		GROUP BY dr.no_rawat
+	) dpjp ON ki.no_rawat = dpjp.no_rawat
+	LEFT JOIN (
+		SELECT no_rawat, COUNT(*) as jumlah_cppt
+		FROM pemeriksaan_ranap 
+		WHERE DATE(tgl_perawatan) = CURDATE()
+		GROUP BY no_rawat
+	) cppt_today ON ki.no_rawat = cppt_today.no_rawat
+	LEFT JOIN (
+		SELECT no_rawat, MAX(tgl_perawatan) as cppt_terakhir
+		FROM pemeriksaan_ranap 
+		GROUP BY no_rawat
+	) cppt_last ON ki.no_rawat = cppt_last.no_rawat
+	WHERE ki.stts_pulang = '-'
+	AND (? = '' OR k.kd_bangsal = ?)
+	ORDER BY b.nm_bangsal, k.kd_kamar, ki.tgl_masuk`
+
+	err := r.db.Raw(query, kdBangsal, kdBangsal).Scan(&pasienList).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pasienList, nil
+}
+
+// Kolom riwayat: baris kamar_inap terakhir (status pulang selain '-' dan 'Pindah Kamar'),
+// tanggal masuk pertama, diagnosa akhir dan jumlah CPPT selama dirawat
+const riwayatSelect = `
+	SELECT
+		ki.no_rawat,
+		p.no_rkm_medis,
+		p.nm_pasien,
+		COALESCE(pj.png_jawab, 'N/A') as penanggung_jawab,
+		ki.kd_kamar,
+		b.nm_bangsal,
+		COALESCE(masuk.diagnosa_awal, '') as diagnosa_awal,
+		COALESCE(NULLIF(ki.diagnosa_akhir, ''), py.nm_penyakit, '') as diagnosa_akhir,
+		COALESCE(dp.kd_penyakit, '') as kd_penyakit,
+		masuk.tgl_masuk,
+		ki.tgl_keluar,
+		CAST(ki.jam_keluar AS CHAR) as jam_keluar,
+		ki.stts_pulang,
+		GREATEST(DATEDIFF(ki.tgl_keluar, masuk.tgl_masuk), 1) as lama_rawat,
+		COALESCE(cppt.jumlah_cppt, 0) as jumlah_cppt`
+
+const riwayatJoins = `
+	FROM kamar_inap ki
+	JOIN reg_periksa rp ON ki.no_rawat = rp.no_rawat
+	JOIN pasien p ON rp.no_rkm_medis = p.no_rkm_medis
+	JOIN kamar k ON ki.kd_kamar = k.kd_kamar
+	JOIN bangsal b ON k.kd_bangsal = b.kd_bangsal
+	LEFT JOIN penjab pj ON rp.kd_pj = pj.kd_pj
+	JOIN (
+		SELECT m.no_rawat, MIN(m.tgl_masuk) as tgl_masuk,
+			(
+				SELECT k1.diagnosa_awal FROM kamar_inap k1
+				WHERE k1.no_rawat = m.no_rawat
+				ORDER BY k1.tgl_masuk, k1.jam_masuk
+				LIMIT 1
+			) as diagnosa_awal
+		FROM kamar_inap m
+		WHERE m.no_rawat IN (%s)
+		GROUP BY m.no_rawat
+	) masuk ON ki.no_rawat = masuk.no_rawat
+	LEFT JOIN diagnosa_pasien dp ON dp.no_rawat = ki.no_rawat AND dp.status = 'Ranap' AND dp.prioritas = 1
+	LEFT JOIN penyakit py ON dp.kd_penyakit = py.kd_penyakit
+	LEFT JOIN (
+		SELECT no_rawat, COUNT(*) as jumlah_cppt
+		FROM pemeriksaan_ranap
+		WHERE no_rawat IN (%s)
+		GROUP BY no_rawat
+	) cppt ON ki.no_rawat = cppt.no_rawat
+	WHERE ki.stts_pulang NOT IN ('-', 'Pindah Kamar')`
+
+// ✅ Riwayat pasien pulang untuk satu DPJP, terbaru di atas
+func (r *pasienRepository) FindRiwayatByDokter(kdDokter string, filter RiwayatFilter) ([]PasienRiwayat, int64, error) {
+	dpjpRawat := `SELECT no_rawat FROM dpjp_ranap WHERE kd_dokter = ?`
+	from := fmt.Sprintf(riwayatJoins, dpjpRawat, dpjpRawat) + `
+	AND ki.no_rawat IN (` + dpjpRawat + `)`
+	args := []interface{}{kdDokter, kdDokter, kdDokter}
+
+	if filter.DateFrom != "" {
+		from += " AND ki.tgl_keluar >= ?"
+		args = append(args, filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		from += " AND ki.tgl_keluar <= ?"
+		args = append(args, filter.DateTo)
+	}
+	if filter.Q != "" {
+		from += " AND (p.nm_pasien LIKE ? OR p.no_rkm_medis = ? OR ki.no_rawat = ?)"
+		args = append(args, "%"+filter.Q+"%", filter.Q, filter.Q)
+	}
+
+	var total int64
+	err := r.db.Raw(`SELECT COUNT(*)`+from, args...).Scan(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var riwayat []PasienRiwayat
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	err = r.db.Raw(riwayatSelect+from+`
+	ORDER BY ki.tgl_keluar DESC, ki.jam_keluar DESC
+	LIMIT ? OFFSET ?`, args...).Scan(&riwayat).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return riwayat, total, nil
+}
+
+// Detail satu rawat inap yang sudah pulang (cek DPJP dilakukan di service)
+func (r *pasienRepository) GetRiwayatDetail(noRawat string) (*PasienRiwayat, error) {
+	var riwayat PasienRiwayat
+	query := riwayatSelect + fmt.Sprintf(riwayatJoins, "?", "?") + `
+	AND ki.no_rawat = ?
+	ORDER BY ki.tgl_keluar DESC, ki.jam_keluar DESC
+	LIMIT 1`
+
+	err := r.db.Raw(query, noRawat, noRawat, noRawat).Scan(&riwayat).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if riwayat.NoRawat == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &riwayat, nil
+}
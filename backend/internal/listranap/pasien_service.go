@@ -1,41 +1,92 @@
 package listranap
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// Notifier: antrian notifikasi push (diimplementasikan notifications.Repository)
+type Notifier interface {
+	EnqueueNotification(kdDokter, judul, isi, noRawat string) error
+}
+
+const (
+	minAlasanDarurat = 10  // karakter
+	maxAlasanDarurat = 500 // pwa_emergency_access.alasan varchar(500)
+)
+
+// AccessChecker: cek hak akses dokter ke pasien untuk modul klinis lain (CPPT, lab, radiologi, obat).
+// Penolakan selalu ErrAksesDitolak (403); error lain berarti gagal cek di database (500)
+type AccessChecker interface {
+	CheckAccess(noRawat string, kdDokter string) error
+	CheckWriteAccess(noRawat string, kdDokter string) error
+	CheckDPJP(noRawat string, kdDokter string) error
+	IsPasienAktif(noRawat string) (bool, error)
+}
+
 type PasienService interface {
 	GetPasienAktifByDokter(kdDokter string) (*PasienListResponse, error)
-	GetPasienAktifByDokterWithFilter(kdDokter string, filter string) (*PasienListResponse, error)
+	GetPasienAktifByDokterWithFilter(kdDokter string, filter string, tanggal string) (*PasienListResponse, error)
 	GetDetailPasien(noRawat string, kdDokter string) (*PasienRawatInap, error)
 	GetDokterProfile(kdDokter string) (*DokterProfileResponse, error)
+	RequestEmergencyAccess(noRawat, idUser, kdDokter, alasan string) (*EmergencyAccess, error)
+	GetPasienAktifByBangsal(kdBangsal string) (*PasienListResponse, error)
+	AccessChecker
+
+	// Riwayat pasien pulang
+	GetRiwayatPasien(kdDokter string, filter RiwayatFilter) (*RiwayatListResponse, error)
+	GetRiwayatDetail(noRawat string, kdDokter string) (*PasienRiwayat, error)
 }
 
 type pasienService struct {
-	pasienRepo PasienRepository
+	pasienRepo         PasienRepository
+	notifier           Notifier
+	emergencyAccessTTL time.Duration
 }
 
-func NewPasienService(pasienRepo PasienRepository) PasienService {
+func NewPasienService(pasienRepo PasienRepository, notifier Notifier, emergencyAccessTTL time.Duration) PasienService {
 	return &pasienService{
-		pasienRepo: pasienRepo,
+		pasienRepo:         pasienRepo,
+		notifier:           notifier,
+		emergencyAccessTTL: emergencyAccessTTL,
 	}
 }
 
 func (s *pasienService) GetPasienAktifByDokter(kdDokter string) (*PasienListResponse, error) {
-	return s.GetPasienAktifByDokterWithFilter(kdDokter, "all")
+	return s.GetPasienAktifByDokterWithFilter(kdDokter, "all", "")
 }
 
-func (s *pasienService) GetPasienAktifByDokterWithFilter(kdDokter string, filter string) (*PasienListResponse, error) {
-	fmt.Printf("🔍 Getting active patients for doctor: %s with filter: %s\n", kdDokter, filter)
+var ErrTanggalTidakValid = errors.New("date must be YYYY-MM-DD and not in the future")
+
+// tanggal kosong = list live hari ini, YYYY-MM-DD = list riwayat hari itu (kepatuhan CPPT)
+func (s *pasienService) GetPasienAktifByDokterWithFilter(kdDokter string, filter string, tanggal string) (*PasienListResponse, error) {
+	fmt.Printf("🔍 Getting active patients for doctor: %s with filter: %s date: %q\n", kdDokter, filter, tanggal)
 
 	if kdDokter == "" {
 		return nil, fmt.Errorf("kode dokter not found")
 	}
 
+	now := time.Now()
+	tanggalList := now.Format("02-01-2006 15:04:05") + " WIB"
+	if tanggal != "" {
+		date, err := time.ParseInLocation("2006-01-02", tanggal, time.Local)
+		if err != nil || date.After(now) {
+			return nil, ErrTanggalTidakValid
+		}
+		if tanggal == now.Format("2006-01-02") {
+			tanggal = "" // Hari ini = list live
+		} else {
+			tanggalList = date.Format("02-01-2006")
+		}
+	}
+
 	// ✅ DIPERBARUI: Saat panggil service, summary HARUS dihitung dari filter "all"
 	// 1. Ambil data pasien sesuai filter
-	pasienList, err := s.pasienRepo.GetPasienRawatInapByDokterWithCppt(kdDokter, filter)
+	pasienList, err := s.pasienRepo.GetPasienRawatInapByDokterWithCppt(kdDokter, filter, tanggal)
 	if err != nil {
 		fmt.Printf("❌ Error getting patients (filtered): %v\n", err)
 		return nil, err
@@ -43,7 +94,7 @@ func (s *pasienService) GetPasienAktifByDokterWithFilter(kdDokter string, filter
 
 	// 2. Ambil data "all" HANYA untuk menghitung summary
 	// Ini penting agar summary di dashboard selalu konsisten, apapun filternya
-	allPasienList, err := s.pasienRepo.GetPasienRawatInapByDokterWithCppt(kdDokter, "all")
+	allPasienList, err := s.pasienRepo.GetPasienRawatInapByDokterWithCppt(kdDokter, "all", tanggal)
 	if err != nil {
 		fmt.Printf("❌ Error getting patients (all for summary): %v\n", err)
 		return nil, err
@@ -53,7 +104,7 @@ func (s *pasienService) GetPasienAktifByDokterWithFilter(kdDokter string, filter
 	// 3. Bangun sisa response
 	dokterInfo := DokterInfo{
 		KodeDokter:  kdDokter,
-		TanggalList: time.Now().Format("02-01-2006 15:04:05") + " WIB",
+		TanggalList: tanggalList,
 	}
 
 	if len(allPasienList) > 0 { // Ambil info dokter dari list "all"
@@ -89,6 +140,32 @@ func (s *pasienService) GetPasienAktifByDokterWithFilter(kdDokter string, filter
 	}, nil
 }
 
+// ✅ List pasien aktif per bangsal untuk service account (papan informasi nurse station)
+func (s *pasienService) GetPasienAktifByBangsal(kdBangsal string) (*PasienListResponse, error) {
+	fmt.Printf("🔍 Getting active patients for bangsal: %q\n", kdBangsal)
+
+	pasienList, err := s.pasienRepo.GetPasienRawatInapByBangsal(kdBangsal)
+	if err != nil {
+		fmt.Printf("❌ Error getting patients by bangsal: %v\n", err)
+		return nil, err
+	}
+
+	cpptSummary := s.calculateCpptSummary(pasienList)
+	message := fmt.Sprintf("Found %d active patients", len(pasienList))
+	fmt.Printf("✅ %s\n", message)
+
+	return &PasienListResponse{
+		Status:  "success",
+		Message: message,
+		Total:   len(pasienList),
+		Data:    pasienList,
+		DokterInfo: DokterInfo{
+			TanggalList: time.Now().Format("02-01-2006 15:04:05") + " WIB",
+		},
+		CpptSummary: cpptSummary,
+	}, nil
+}
+
 // ✅ DIPERBARUI: Menghitung 3 status (done, pending, new)
 func (s *pasienService) calculateCpptSummary(pasienList []PasienRawatInap) CpptSummary {
 	total := len(pasienList)
@@ -126,6 +203,17 @@ func (s *pasienService) calculateCpptSummary(pasienList []PasienRawatInap) CpptS
 func (s *pasienService) GetDetailPasien(noRawat string, kdDokter string) (*PasienRawatInap, error) {
 	fmt.Printf("🔍 Getting patient detail for: %s by doctor: %s\n", noRawat, kdDokter)
 	pasien, err := s.pasienRepo.GetPasienDetail(noRawat, kdDokter)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// ✅ Bukan DPJP: cek apakah dokter punya akses darurat yang masih berlaku
+		access, accessErr := s.pasienRepo.GetActiveEmergencyAccess(noRawat, kdDokter, time.Now())
+		if accessErr == nil {
+			pasien, err = s.pasienRepo.GetPasienAktifByNoRawat(noRawat, kdDokter)
+			if err == nil {
+				pasien.AksesDarurat = access
+				fmt.Printf("🚨 Patient detail opened via emergency access: %s by %s\n", noRawat, kdDokter)
+			}
+		}
+	}
 	if err != nil {
 		fmt.Printf("❌ Patient not found or not your DPJP: %v\n", err)
 		return nil, err
@@ -150,3 +238,191 @@ func (s *pasienService) GetDokterProfile(kdDokter string) (*DokterProfileRespons
 		Data:    *dokter,
 	}, nil
 }
+
+var ErrAksesDitolak = errors.New("not the DPJP of this patient and no active emergency access")
+
+// ✅ CheckAccess dipakai modul lain (CPPT, radiologi, obat): DPJP atau akses darurat yang masih berlaku
+func (s *pasienService) CheckAccess(noRawat string, kdDokter string) error {
+	isDPJP, err := s.pasienRepo.IsDPJP(noRawat, kdDokter)
+	if err != nil {
+		fmt.Printf("❌ Failed to check DPJP: %v\n", err)
+		return err
+	}
+	if isDPJP {
+		return nil
+	}
+
+	_, err = s.pasienRepo.GetActiveEmergencyAccess(noRawat, kdDokter, time.Now())
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		fmt.Printf("❌ Failed to check emergency access: %v\n", err)
+		return err
+	}
+	return ErrAksesDitolak
+}
+
+var ErrPasienTidakAktif = errors.New("patient is no longer admitted")
+
+// ✅ CheckWriteAccess: menulis ke rekam medis hanya untuk DPJP/konsulen (dokter yang tercatat di
+// dpjp_ranap) selama pasien masih dirawat. Akses darurat TIDAK memberi hak tulis.
+func (s *pasienService) CheckWriteAccess(noRawat string, kdDokter string) error {
+	isDPJP, err := s.pasienRepo.IsDPJP(noRawat, kdDokter)
+	if err != nil {
+		fmt.Printf("❌ Failed to check DPJP: %v\n", err)
+		return err
+	}
+	if !isDPJP {
+		return ErrAksesDitolak
+	}
+
+	aktif, err := s.IsPasienAktif(noRawat)
+	if err != nil {
+		return err
+	}
+	if !aktif {
+		return ErrPasienTidakAktif
+	}
+	return nil
+}
+
+// IsPasienAktif: pasien masih dirawat (belum pulang)
+func (s *pasienService) IsPasienAktif(noRawat string) (bool, error) {
+	aktif, err := s.pasienRepo.IsPasienAktif(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Failed to check patient status: %v\n", err)
+		return false, err
+	}
+	return aktif, nil
+}
+
+// ✅ CheckDPJP: hanya DPJP/konsulen, juga setelah pasien pulang (mis. verifikasi CPPT)
+func (s *pasienService) CheckDPJP(noRawat string, kdDokter string) error {
+	isDPJP, err := s.pasienRepo.IsDPJP(noRawat, kdDokter)
+	if err != nil {
+		fmt.Printf("❌ Failed to check DPJP: %v\n", err)
+		return err
+	}
+	if !isDPJP {
+		return ErrAksesDitolak
+	}
+	return nil
+}
+
+var (
+	ErrAlasanTerlaluPendek  = fmt.Errorf("alasan must be at least %d characters", minAlasanDarurat)
+	ErrAlasanTerlaluPanjang = fmt.Errorf("alasan must be at most %d characters", maxAlasanDarurat)
+)
+
+// ✅ Break-the-glass: beri akses sementara ke pasien yang bukan DPJP, lalu beri tahu DPJP
+func (s *pasienService) RequestEmergencyAccess(noRawat, idUser, kdDokter, alasan string) (*EmergencyAccess, error) {
+	alasan = strings.TrimSpace(alasan)
+	if len([]rune(alasan)) < minAlasanDarurat {
+		return nil, ErrAlasanTerlaluPendek
+	}
+	if len([]rune(alasan)) > maxAlasanDarurat {
+		return nil, ErrAlasanTerlaluPanjang
+	}
+
+	// Pasien harus masih dirawat
+	if _, err := s.pasienRepo.GetPasienAktifByNoRawat(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ Emergency access denied for %s: %v\n", noRawat, err)
+		return nil, err
+	}
+
+	now := time.Now()
+	access := &EmergencyAccess{
+		IDUser:     idUser,
+		KodeDokter: kdDokter,
+		NoRawat:    noRawat,
+		Alasan:     alasan,
+		GrantedAt:  now,
+		ExpiresAt:  now.Add(s.emergencyAccessTTL),
+	}
+	if err := s.pasienRepo.CreateEmergencyAccess(access); err != nil {
+		fmt.Printf("❌ Failed to create emergency access: %v\n", err)
+		return nil, err
+	}
+	fmt.Printf("🚨 Emergency access granted: %s to %s until %s\n", noRawat, kdDokter, access.ExpiresAt.Format("15:04"))
+
+	// ✅ Beri tahu semua DPJP pasien lewat notification_queue (gagal kirim tidak membatalkan akses).
+	// Isi push dikirim lewat OneSignal (pihak ketiga), jadi hanya pesan umum + no_rawat;
+	// alasan klinis tetap tersimpan di pwa_emergency_access dan dibaca di aplikasi.
+	dpjpList, err := s.pasienRepo.GetDPJPByNoRawat(noRawat)
+	if err != nil {
+		fmt.Printf("⚠️ Failed to get DPJP for notification: %v\n", err)
+	}
+	for _, dpjp := range dpjpList {
+		if dpjp == kdDokter {
+			continue
+		}
+		judul := "Akses Darurat Pasien"
+		isi := emergencyNotificationBody(noRawat)
+		if err := s.notifier.EnqueueNotification(dpjp, judul, isi, noRawat); err != nil {
+			fmt.Printf("⚠️ Failed to notify DPJP %s: %v\n", dpjp, err)
+		}
+	}
+
+	return access, nil
+}
+
+// emergencyNotificationBody: tanpa nama pasien, nama dokter, atau alasan (PHI)
+func emergencyNotificationBody(noRawat string) string {
+	return fmt.Sprintf("Akses darurat dibuka untuk pasien rawat inap %s. Buka aplikasi untuk detail.", noRawat)
+}
+
+var ErrFilterTidakValid = errors.New("invalid filter")
+
+// ✅ Riwayat pasien yang sudah pulang, per DPJP, dengan paging
+func (s *pasienService) GetRiwayatPasien(kdDokter string, filter RiwayatFilter) (*RiwayatListResponse, error) {
+	fmt.Printf("🔍 Getting discharged patients for doctor: %s\n", kdDokter)
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+	for _, value := range []string{filter.DateFrom, filter.DateTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return nil, fmt.Errorf("%w: date_from/date_to must be YYYY-MM-DD", ErrFilterTidakValid)
+		}
+	}
+	filter.Q = strings.TrimSpace(filter.Q)
+
+	riwayat, total, err := s.pasienRepo.FindRiwayatByDokter(kdDokter, filter)
+	if err != nil {
+		fmt.Printf("❌ Error getting discharged patients: %v\n", err)
+		return nil, err
+	}
+
+	fmt.Printf("✅ Found %d discharged patients for %s\n", total, kdDokter)
+	return &RiwayatListResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Found %d discharged patients", total),
+		Total:   total,
+		Page:    filter.Page,
+		Limit:   filter.Limit,
+		Data:    riwayat,
+	}, nil
+}
+
+// ✅ Detail rawat inap yang sudah pulang, hanya untuk DPJP/konsulen pasien tersebut
+func (s *pasienService) GetRiwayatDetail(noRawat string, kdDokter string) (*PasienRiwayat, error) {
+	fmt.Printf("🔍 Getting discharged patient detail for: %s by doctor: %s\n", noRawat, kdDokter)
+
+	if err := s.CheckDPJP(noRawat, kdDokter); err != nil {
+		return nil, err
+	}
+
+	riwayat, err := s.pasienRepo.GetRiwayatDetail(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Error getting discharged patient detail: %v\n", err)
+		return nil, err
+	}
+	return riwayat, nil
+}
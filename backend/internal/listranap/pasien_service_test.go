@@ -0,0 +1,183 @@
+package listranap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fakePasienRepo: PasienRepository di memori; method yang tidak di-override akan panic
+type fakePasienRepo struct {
+	PasienRepository
+
+	dpjp      map[string][]string // no_rawat -> kd_dokter DPJP
+	emergency map[string]*EmergencyAccess
+	isDPJPErr error
+	createErr error
+}
+
+func newFakePasienRepo() *fakePasienRepo {
+	return &fakePasienRepo{
+		dpjp:      make(map[string][]string),
+		emergency: make(map[string]*EmergencyAccess),
+	}
+}
+
+func (r *fakePasienRepo) GetPasienAktifByNoRawat(noRawat string, kdDokter string) (*PasienRawatInap, error) {
+	if _, ok := r.dpjp[noRawat]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &PasienRawatInap{NoRawat: noRawat}, nil
+}
+
+func (r *fakePasienRepo) GetDPJPByNoRawat(noRawat string) ([]string, error) {
+	return r.dpjp[noRawat], nil
+}
+
+func (r *fakePasienRepo) CreateEmergencyAccess(access *EmergencyAccess) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	r.emergency[access.NoRawat+"|"+access.KodeDokter] = access
+	return nil
+}
+
+func (r *fakePasienRepo) GetActiveEmergencyAccess(noRawat string, kdDokter string, now time.Time) (*EmergencyAccess, error) {
+	access, ok := r.emergency[noRawat+"|"+kdDokter]
+	if !ok || !now.Before(access.ExpiresAt) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return access, nil
+}
+
+func (r *fakePasienRepo) IsDPJP(noRawat string, kdDokter string) (bool, error) {
+	if r.isDPJPErr != nil {
+		return false, r.isDPJPErr
+	}
+	for _, dpjp := range r.dpjp[noRawat] {
+		if dpjp == kdDokter {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type sentNotification struct {
+	kdDokter, judul, isi, noRawat string
+}
+
+type fakeNotifier struct {
+	sent []sentNotification
+}
+
+func (n *fakeNotifier) EnqueueNotification(kdDokter, judul, isi, noRawat string) error {
+	n.sent = append(n.sent, sentNotification{kdDokter, judul, isi, noRawat})
+	return nil
+}
+
+func TestRequestEmergencyAccessNotifiesWithoutPHI(t *testing.T) {
+	const noRawat = "2025/01/31/000001"
+	const alasan = "Pasien henti napas di IGD, DPJP tidak bisa dihubungi"
+
+	repo := newFakePasienRepo()
+	repo.dpjp[noRawat] = []string{"D001", "D002"}
+	notifier := &fakeNotifier{}
+	service := NewPasienService(repo, notifier, 4*time.Hour)
+
+	access, err := service.RequestEmergencyAccess(noRawat, "D009", "D009", alasan)
+	if err != nil {
+		t.Fatalf("RequestEmergencyAccess: %v", err)
+	}
+	if access.Alasan != alasan {
+		t.Errorf("stored alasan = %q, want the full reason kept in the app", access.Alasan)
+	}
+
+	if len(notifier.sent) != 2 {
+		t.Fatalf("notifications = %d, want one per DPJP", len(notifier.sent))
+	}
+	for _, n := range notifier.sent {
+		if strings.Contains(n.isi, "henti napas") || strings.Contains(n.judul, "henti napas") {
+			t.Errorf("push to %s contains the clinical reason: %q", n.kdDokter, n.isi)
+		}
+		if !strings.Contains(n.isi, noRawat) || n.noRawat != noRawat {
+			t.Errorf("push to %s = %+v, want the no_rawat reference", n.kdDokter, n)
+		}
+	}
+}
+
+func TestRequestEmergencyAccessErrors(t *testing.T) {
+	const noRawat = "2025/01/31/000001"
+	const alasan = "Pasien henti napas di IGD, DPJP tidak bisa dihubungi"
+	dbErr := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		noRawat   string
+		alasan    string
+		createErr error
+		wantErr   error
+	}{
+		{name: "too short", noRawat: noRawat, alasan: "darurat", wantErr: ErrAlasanTerlaluPendek},
+		{name: "too long", noRawat: noRawat, alasan: strings.Repeat("a", maxAlasanDarurat+1), wantErr: ErrAlasanTerlaluPanjang},
+		{name: "max length counts runes", noRawat: noRawat, alasan: strings.Repeat("é", maxAlasanDarurat)},
+		{name: "not admitted", noRawat: "2025/01/31/000009", alasan: alasan, wantErr: gorm.ErrRecordNotFound},
+		// Error database tidak boleh dilaporkan sebagai pasien tidak ditemukan
+		{name: "insert fails", noRawat: noRawat, alasan: alasan, createErr: dbErr, wantErr: dbErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakePasienRepo()
+			repo.dpjp[noRawat] = []string{"D001"}
+			repo.createErr = tt.createErr
+			service := NewPasienService(repo, &fakeNotifier{}, 4*time.Hour)
+
+			_, err := service.RequestEmergencyAccess(tt.noRawat, "D009", "D009", tt.alasan)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	const noRawat = "2025/01/31/000001"
+	dbErr := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		kdDokter  string
+		emergency bool
+		isDPJPErr error
+		wantErr   error
+	}{
+		{"dpjp", "D001", false, nil, nil},
+		{"emergency access", "D009", true, nil, nil},
+		{"not dpjp", "D009", false, nil, ErrAksesDitolak},
+		// Error database bukan penolakan akses: modul lain harus menjawab 500, bukan 403
+		{"database error", "D001", false, dbErr, dbErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakePasienRepo()
+			repo.dpjp[noRawat] = []string{"D001"}
+			repo.isDPJPErr = tt.isDPJPErr
+			if tt.emergency {
+				repo.emergency[noRawat+"|"+tt.kdDokter] = &EmergencyAccess{NoRawat: noRawat, KodeDokter: tt.kdDokter, ExpiresAt: time.Now().Add(time.Hour)}
+			}
+			service := NewPasienService(repo, &fakeNotifier{}, time.Hour)
+
+			err := service.CheckAccess(noRawat, tt.kdDokter)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("CheckAccess() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.isDPJPErr != nil && errors.Is(err, ErrAksesDitolak) {
+				t.Fatal("database error reported as access denied")
+			}
+		})
+	}
+}
@@ -74,3 +74,13 @@ func (r *Repository) UpdateNotificationStatus(id int64, status string, responseM
 
 	return err
 }
+
+// EnqueueNotification menambahkan notifikasi baru ke antrian (dikirim oleh worker)
+func (r *Repository) EnqueueNotification(kdDokter, judul, isi, noRawat string) error {
+	query := `
+		INSERT INTO notification_queue (kd_dokter, title, body, no_rawat, status, created_at)
+		VALUES (?, ?, ?, ?, 'pending', ?)
+	`
+	_, err := r.DB.Exec(query, kdDokter, judul, isi, noRawat, time.Now())
+	return err
+}
@@ -0,0 +1,55 @@
+package obat
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// "3x1", "3 x 1/2", "2dd1", "3 X 500 mg"; satuan panjang didahulukan ("tablet" sebelum "tab")
+	frekuensiPattern = regexp.MustCompile(`(?i)(\d+)\s*(?:x|dd)\s*(\d+(?:[.,/]\d+)?\s*(?:mg|mcg|g|ml|cc|iu|unit|tablet|tab|kapsul|kaps|caps|sachet|sach|ampul|amp|vial|puff|tetes|gtt|sdm|sdt|cth)?)`)
+	// "tiap 8 jam", "/8 jam", "per 12 jam", "q8h"
+	intervalPattern = regexp.MustCompile(`(?i)(?:tiap|setiap|per|/|q)\s*(\d+)\s*(?:jam|h\b)`)
+)
+
+// Kata kunci rute pemberian, dicek berurutan (yang lebih spesifik dulu)
+var ruteKeywords = []struct {
+	rute     string
+	keywords []string
+}{
+	{"IV", []string{"i.v", "iv ", "iv.", "intravena", "drip", "bolus", "infus"}},
+	{"IM", []string{"i.m", "im ", "im.", "intramuskular"}},
+	{"SC", []string{"s.c", "sc ", "sc.", "subkutan", "subcutan"}},
+	{"Inhalasi", []string{"inhal", "nebul", "puff"}},
+	{"Rektal", []string{"supp", "rektal", "rectal"}},
+	{"Sublingual", []string{"sublingual", "s.l"}},
+	{"Topikal", []string{"topikal", "oles", "salep", "krim", "cream"}},
+	{"Tetes", []string{"tetes mata", "tetes telinga", "gtt"}},
+	{"Oral", []string{"p.o", "po ", "po.", "oral", "per oral", "tab", "kaps", "sirup", "syr", "diminum", "sesudah makan", "sebelum makan"}},
+}
+
+// parseAturanPakai mengurai teks bebas aturan pakai menjadi dosis, frekuensi dan rute.
+// Bagian yang tidak dikenali dibiarkan kosong; teks asli tetap dikirim ke client.
+func parseAturanPakai(aturan string) (dosis, frekuensi, rute string) {
+	text := strings.TrimSpace(aturan)
+	if text == "" {
+		return "", "", ""
+	}
+
+	if m := frekuensiPattern.FindStringSubmatch(text); m != nil {
+		frekuensi = m[1] + "x sehari"
+		dosis = strings.TrimSpace(m[2])
+	} else if m := intervalPattern.FindStringSubmatch(text); m != nil {
+		frekuensi = "tiap " + m[1] + " jam"
+	}
+
+	lower := " " + strings.ToLower(text) + " "
+	for _, candidate := range ruteKeywords {
+		for _, keyword := range candidate.keywords {
+			if strings.Contains(lower, keyword) {
+				return dosis, frekuensi, candidate.rute
+			}
+		}
+	}
+	return dosis, frekuensi, ""
+}
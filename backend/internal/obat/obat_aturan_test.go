@@ -0,0 +1,33 @@
+package obat
+
+import "testing"
+
+func TestParseAturanPakai(t *testing.T) {
+	cases := []struct {
+		aturan    string
+		dosis     string
+		frekuensi string
+		rute      string
+	}{
+		{"", "", "", ""},
+		{"3x1 tab p.o. sesudah makan", "1 tab", "3x sehari", "Oral"},
+		{"3x1 tablet sesudah makan", "1 tablet", "3x sehari", "Oral"},
+		{"3 X 500 mg", "500 mg", "3x sehari", ""},
+		{"2dd1", "1", "2x sehari", ""},
+		{"3 x 1/2 tab", "1/2 tab", "3x sehari", "Oral"},
+		{"2x1 kapsul", "1 kapsul", "2x sehari", "Oral"},
+		{"1 amp iv tiap 8 jam", "", "tiap 8 jam", "IV"},
+		{"ceftriaxone drip q12h", "", "tiap 12 jam", "IV"},
+		{"2 puff /12 jam", "", "tiap 12 jam", "Inhalasi"},
+		{"supp 1x1", "1", "1x sehari", "Rektal"},
+		{"salep oles tipis", "", "", "Topikal"},
+		{"sesuai advis", "", "", ""},
+	}
+	for _, c := range cases {
+		dosis, frekuensi, rute := parseAturanPakai(c.aturan)
+		if dosis != c.dosis || frekuensi != c.frekuensi || rute != c.rute {
+			t.Errorf("parseAturanPakai(%q) = (%q, %q, %q), want (%q, %q, %q)",
+				c.aturan, dosis, frekuensi, rute, c.dosis, c.frekuensi, c.rute)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package obat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pwa-rsbw/internal/listranap"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ObatHandler struct {
+	obatService ObatService
+}
+
+func NewObatHandler(obatService ObatService) *ObatHandler {
+	return &ObatHandler{
+		obatService: obatService,
+	}
+}
+
+// ✅ Daftar obat aktif pasien (tambahkan ?all=true untuk semua obat selama dirawat)
+func (h *ObatHandler) GetObatPasien(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var filter ObatFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid query parameters",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response, err := h.obatService.GetObatPasien(noRawat, kdDokter, filter)
+	if errors.Is(err, listranap.ErrAksesDitolak) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get medication list",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ✅ E-resep rawat inap. 409 + daftar peringatan jika perlu konfirmasi dokter.
+func (h *ObatHandler) CreateResep(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	var req ResepCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	resep, err := h.obatService.CreateResep(noRawat, kdDokter, req)
+	var warningsErr *ResepWarningsError
+	if errors.As(err, &warningsErr) {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":     "error",
+			"message":    "Prescription has warnings. Resend with konfirmasi=true to proceed",
+			"peringatan": warningsErr.Warnings,
+		})
+		return
+	}
+	if errors.Is(err, ErrWriteDenied) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrResepInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to create prescription",
+		})
+		return
+	}
+
+	if len(resep.Peringatan) > 0 {
+		c.Set("audit_detail", fmt.Sprintf("no_resep: %s, confirmed %d warnings", resep.NoResep, len(resep.Peringatan)))
+	} else {
+		c.Set("audit_detail", "no_resep: "+resep.NoResep)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "Prescription created",
+		"data":    resep,
+	})
+}
+
+// ✅ Pencarian obat untuk resep / plan CPPT: ?q=amoxi&kd_pj=BPJ&limit=20
+func (h *ObatHandler) SearchObat(c *gin.Context) {
+	var filter ObatSearchFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid query parameters",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response, err := h.obatService.SearchObat(filter)
+	if errors.Is(err, ErrQueryTooShort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrIndexNotReady) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "error",
+			"message": "Drug index is still loading, please retry shortly",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to search drugs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ✅ Admin: daftar nama generik per barang
+func (h *ObatHandler) GetGenerik(c *gin.Context) {
+	rows, err := h.obatService.GetGenerik()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get generic names",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"total":  len(rows),
+		"data":   rows,
+	})
+}
+
+// ✅ Admin: impor/perbarui nama generik ({"items": [{"kode_brng", "nama_generik"}]})
+func (h *ObatHandler) ImportGenerik(c *gin.Context) {
+	var req ObatGenerikImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	saved, err := h.obatService.ImportGenerik(req)
+	if errors.Is(err, ErrMasterInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to import generic names",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("%d generic names saved", saved),
+		"total":   saved,
+	})
+}
+
+// ✅ Admin: hapus nama generik satu barang
+func (h *ObatHandler) DeleteGenerik(c *gin.Context) {
+	h.respondMasterDelete(c, h.obatService.DeleteGenerik(c.Param("kode_brng")), "Generic name deleted")
+}
+
+// ✅ Admin: daftar formularium satu penjamin
+func (h *ObatHandler) GetFormularium(c *gin.Context) {
+	rows, err := h.obatService.GetFormularium(c.Param("kd_pj"))
+	if errors.Is(err, ErrMasterInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get formulary",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"total":  len(rows),
+		"data":   rows,
+	})
+}
+
+// ✅ Admin: ganti formularium penjamin dengan daftar kode_brng baru
+func (h *ObatHandler) ImportFormularium(c *gin.Context) {
+	var req FormulariumImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	saved, err := h.obatService.ImportFormularium(c.Param("kd_pj"), req)
+	if errors.Is(err, ErrMasterInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to import formulary",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": fmt.Sprintf("Formulary replaced with %d items", saved),
+		"total":   saved,
+	})
+}
+
+// ✅ Admin: keluarkan satu barang dari formularium penjamin
+func (h *ObatHandler) DeleteFormularium(c *gin.Context) {
+	h.respondMasterDelete(c, h.obatService.DeleteFormularium(c.Param("kd_pj"), c.Param("kode_brng")), "Formulary item deleted")
+}
+
+func (h *ObatHandler) respondMasterDelete(c *gin.Context, err error, message string) {
+	if errors.Is(err, ErrMasterNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to delete drug master entry",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": message,
+	})
+}
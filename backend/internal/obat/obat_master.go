@@ -0,0 +1,203 @@
+package obat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMasterInvalid  = errors.New("invalid drug master data")
+	ErrMasterNotFound = errors.New("drug master entry not found")
+)
+
+const (
+	maxNamaGenerik = 100 // pwa_obat_generik.nama_generik varchar(100)
+	maxKdPj        = 3   // penjab.kd_pj char(3)
+	maxListedKode  = 20  // Batas kode bermasalah yang disebut di pesan error
+)
+
+// ✅ Admin: semua nama generik yang sudah diisi instalasi farmasi
+func (s *obatService) GetGenerik() ([]ObatGenerik, error) {
+	rows, err := s.obatRepo.FindGenerik()
+	if err != nil {
+		fmt.Printf("❌ Error getting generic names: %v\n", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ✅ Admin: impor/perbarui nama generik. Semua kode harus ada di databarang; satu baris salah
+// menolak seluruh impor supaya file dari farmasi bisa diperbaiki lalu dikirim ulang utuh.
+func (s *obatService) ImportGenerik(req ObatGenerikImportRequest) (int, error) {
+	var problems []string
+	rows := make([]ObatGenerik, 0, len(req.Items))
+	kode := make([]string, 0, len(req.Items))
+	seen := map[string]bool{}
+	for _, item := range req.Items {
+		row := ObatGenerik{
+			KodeBrng:    strings.TrimSpace(item.KodeBrng),
+			NamaGenerik: strings.TrimSpace(item.NamaGenerik),
+		}
+		switch {
+		case row.KodeBrng == "":
+			problems = append(problems, "kode_brng is required")
+			continue
+		case seen[row.KodeBrng]:
+			problems = append(problems, fmt.Sprintf("%s is listed more than once", row.KodeBrng))
+			continue
+		case row.NamaGenerik == "" || len([]rune(row.NamaGenerik)) > maxNamaGenerik:
+			problems = append(problems, fmt.Sprintf("%s: nama_generik is required (max %d characters)", row.KodeBrng, maxNamaGenerik))
+		}
+		seen[row.KodeBrng] = true
+		rows = append(rows, row)
+		kode = append(kode, row.KodeBrng)
+	}
+
+	missing, err := s.missingBarang(kode)
+	if err != nil {
+		return 0, err
+	}
+	problems = append(problems, missing...)
+	if len(problems) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrMasterInvalid, joinProblems(problems))
+	}
+
+	if err := s.obatRepo.SaveGenerik(rows); err != nil {
+		fmt.Printf("❌ Failed to save generic names: %v\n", err)
+		return 0, err
+	}
+
+	fmt.Printf("✅ Imported %d generic names\n", len(rows))
+	s.refreshIndex()
+	return len(rows), nil
+}
+
+// ✅ Admin: hapus nama generik satu barang
+func (s *obatService) DeleteGenerik(kodeBrng string) error {
+	err := s.obatRepo.DeleteGenerik(strings.TrimSpace(kodeBrng))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMasterNotFound
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to delete generic name: %v\n", err)
+		return err
+	}
+
+	s.refreshIndex()
+	return nil
+}
+
+// ✅ Admin: daftar formularium satu penjamin
+func (s *obatService) GetFormularium(kdPj string) ([]ObatFormularium, error) {
+	kdPj = strings.TrimSpace(kdPj)
+	if kdPj == "" || len(kdPj) > maxKdPj {
+		return nil, fmt.Errorf("%w: kd_pj is required (max %d characters)", ErrMasterInvalid, maxKdPj)
+	}
+	rows, err := s.obatRepo.FindFormulariumByPj(kdPj)
+	if err != nil {
+		fmt.Printf("❌ Error getting formulary: %v\n", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ✅ Admin: ganti seluruh formularium penjamin dengan daftar baru (daftar kosong = kosongkan)
+func (s *obatService) ImportFormularium(kdPj string, req FormulariumImportRequest) (int, error) {
+	kdPj = strings.TrimSpace(kdPj)
+	if kdPj == "" || len(kdPj) > maxKdPj {
+		return 0, fmt.Errorf("%w: kd_pj is required (max %d characters)", ErrMasterInvalid, maxKdPj)
+	}
+	exists, err := s.obatRepo.PenjabExists(kdPj)
+	if err != nil {
+		fmt.Printf("❌ Error looking up penjab: %v\n", err)
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("%w: penjab %s not found", ErrMasterInvalid, kdPj)
+	}
+
+	// Duplikat di file impor cukup diabaikan, hasilnya tetap satu baris per barang
+	kode := make([]string, 0, len(req.KodeBrng))
+	seen := map[string]bool{}
+	for _, value := range req.KodeBrng {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		kode = append(kode, value)
+	}
+
+	missing, err := s.missingBarang(kode)
+	if err != nil {
+		return 0, err
+	}
+	if len(missing) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrMasterInvalid, joinProblems(missing))
+	}
+
+	if err := s.obatRepo.ReplaceFormularium(kdPj, kode); err != nil {
+		fmt.Printf("❌ Failed to save formulary: %v\n", err)
+		return 0, err
+	}
+
+	fmt.Printf("✅ Formulary %s replaced with %d items\n", kdPj, len(kode))
+	s.refreshIndex()
+	return len(kode), nil
+}
+
+// ✅ Admin: keluarkan satu barang dari formularium penjamin
+func (s *obatService) DeleteFormularium(kdPj, kodeBrng string) error {
+	err := s.obatRepo.DeleteFormularium(strings.TrimSpace(kdPj), strings.TrimSpace(kodeBrng))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMasterNotFound
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to delete formulary item: %v\n", err)
+		return err
+	}
+
+	s.refreshIndex()
+	return nil
+}
+
+// missingBarang: pesan untuk setiap kode yang tidak ada di databarang
+func (s *obatService) missingBarang(kode []string) ([]string, error) {
+	existing, err := s.obatRepo.FindExistingBarang(kode)
+	if err != nil {
+		fmt.Printf("❌ Error looking up items: %v\n", err)
+		return nil, err
+	}
+	found := make(map[string]bool, len(existing))
+	for _, value := range existing {
+		found[value] = true
+	}
+
+	var problems []string
+	for _, value := range kode {
+		if !found[value] {
+			problems = append(problems, fmt.Sprintf("%s: item not found", value))
+		}
+	}
+	return problems, nil
+}
+
+// refreshIndex: perubahan master langsung terlihat di pencarian, tanpa menunggu worker
+func (s *obatService) refreshIndex() {
+	if s.index == nil {
+		return
+	}
+	if err := s.index.Refresh(); err != nil {
+		fmt.Printf("⚠️ Drug index refresh after master update failed: %v\n", err)
+	}
+}
+
+func joinProblems(problems []string) string {
+	if len(problems) > maxListedKode {
+		return fmt.Sprintf("%s; and %d more", strings.Join(problems[:maxListedKode], "; "), len(problems)-maxListedKode)
+	}
+	return strings.Join(problems, "; ")
+}
@@ -0,0 +1,129 @@
+package obat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeObatRepo: method yang tidak dipakai tes akan panic lewat interface yang di-embed
+type fakeObatRepo struct {
+	ObatRepository
+	katalog     []KatalogRow
+	generik     []ObatGenerik
+	formularium []ObatFormularium
+	penjab      map[string]bool
+
+	savedGenerik []ObatGenerik
+	replaced     map[string][]string
+}
+
+func (f *fakeObatRepo) FindKatalog() ([]KatalogRow, error)          { return f.katalog, nil }
+func (f *fakeObatRepo) FindGenerik() ([]ObatGenerik, error)         { return f.generik, nil }
+func (f *fakeObatRepo) FindFormularium() ([]ObatFormularium, error) { return f.formularium, nil }
+func (f *fakeObatRepo) PenjabExists(kdPj string) (bool, error)      { return f.penjab[kdPj], nil }
+func (f *fakeObatRepo) SaveGenerik(rows []ObatGenerik) error        { f.savedGenerik = rows; return nil }
+func (f *fakeObatRepo) FindExistingBarang(kode []string) ([]string, error) {
+	var existing []string
+	for _, value := range kode {
+		for _, row := range f.katalog {
+			if row.KodeBrng == value {
+				existing = append(existing, value)
+			}
+		}
+	}
+	return existing, nil
+}
+func (f *fakeObatRepo) ReplaceFormularium(kdPj string, kode []string) error {
+	if f.replaced == nil {
+		f.replaced = map[string][]string{}
+	}
+	f.replaced[kdPj] = kode
+	return nil
+}
+
+func newFakeObatRepo() *fakeObatRepo {
+	return &fakeObatRepo{
+		katalog: []KatalogRow{
+			{KodeBrng: "B001", NamaBrng: "Amoxicillin 500 mg kaps"},
+			{KodeBrng: "B002", NamaBrng: "Amoxsan 500 mg kaps"},
+			{KodeBrng: "B003", NamaBrng: "Paracetamol 500 mg tab"},
+		},
+		penjab: map[string]bool{"BPJ": true},
+	}
+}
+
+func TestSearchTotalBeforeLimit(t *testing.T) {
+	repo := newFakeObatRepo()
+	repo.generik = []ObatGenerik{{KodeBrng: "B002", NamaGenerik: "amoxicillin"}}
+	index := NewObatIndex(repo)
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	results, total, _, err := index.Search("amoxicillin", "", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || total != 2 {
+		t.Errorf("got %d results, total %d; want 1 result, total 2", len(results), total)
+	}
+}
+
+func TestImportGenerik(t *testing.T) {
+	cases := []struct {
+		name    string
+		items   []ObatGenerik
+		wantErr bool
+		saved   []ObatGenerik
+	}{
+		{"trimmed upsert", []ObatGenerik{{KodeBrng: " B002 ", NamaGenerik: " amoxicillin "}}, false, []ObatGenerik{{KodeBrng: "B002", NamaGenerik: "amoxicillin"}}},
+		{"unknown item", []ObatGenerik{{KodeBrng: "B002", NamaGenerik: "amoxicillin"}, {KodeBrng: "X999", NamaGenerik: "x"}}, true, nil},
+		{"empty generic", []ObatGenerik{{KodeBrng: "B003", NamaGenerik: " "}}, true, nil},
+		{"listed twice", []ObatGenerik{{KodeBrng: "B003", NamaGenerik: "paracetamol"}, {KodeBrng: "B003", NamaGenerik: "acetaminophen"}}, true, nil},
+	}
+	for _, c := range cases {
+		repo := newFakeObatRepo()
+		service := &obatService{obatRepo: repo}
+		_, err := service.ImportGenerik(ObatGenerikImportRequest{Items: c.items})
+		if c.wantErr {
+			if !errors.Is(err, ErrMasterInvalid) || repo.savedGenerik != nil {
+				t.Errorf("%s: err = %v, saved = %v; want ErrMasterInvalid and nothing saved", c.name, err, repo.savedGenerik)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(repo.savedGenerik, c.saved) {
+			t.Errorf("%s: err = %v, saved = %v; want %v", c.name, err, repo.savedGenerik, c.saved)
+		}
+	}
+}
+
+func TestImportFormularium(t *testing.T) {
+	cases := []struct {
+		name    string
+		kdPj    string
+		kode    []string
+		wantErr bool
+		saved   []string
+	}{
+		{"dedupe", "BPJ", []string{"B001", " B003", "B001", ""}, false, []string{"B001", "B003"}},
+		{"clear", "BPJ", nil, false, []string{}},
+		{"unknown penjab", "XXX", []string{"B001"}, true, nil},
+		{"unknown item", "BPJ", []string{"B001", "X999"}, true, nil},
+	}
+	for _, c := range cases {
+		repo := newFakeObatRepo()
+		service := &obatService{obatRepo: repo}
+		_, err := service.ImportFormularium(c.kdPj, FormulariumImportRequest{KodeBrng: c.kode})
+		saved, ok := repo.replaced[c.kdPj]
+		if c.wantErr {
+			if !errors.Is(err, ErrMasterInvalid) || ok {
+				t.Errorf("%s: err = %v, replaced = %v; want ErrMasterInvalid and nothing replaced", c.name, err, saved)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(saved, c.saved) {
+			t.Errorf("%s: err = %v, replaced = %v; want %v", c.name, err, saved, c.saved)
+		}
+	}
+}
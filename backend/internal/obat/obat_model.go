@@ -0,0 +1,193 @@
+package obat
+
+import "time"
+
+// ResepRow: satu item resep dokter (resep_obat + resep_dokter), atau satu komponen racikan
+type ResepRow struct {
+	NoResep     string  `gorm:"column:no_resep"`
+	Waktu       string  `gorm:"column:waktu"` // YYYY-MM-DD HH:MM:SS peresepan
+	KdDokter    string  `gorm:"column:kd_dokter"`
+	NmDokter    string  `gorm:"column:nm_dokter"`
+	KodeBrng    string  `gorm:"column:kode_brng"`
+	NamaBrng    string  `gorm:"column:nama_brng"`
+	Satuan      string  `gorm:"column:satuan"`
+	Jml         float64 `gorm:"column:jml"`
+	AturanPakai string  `gorm:"column:aturan_pakai"`
+	NamaRacik   string  `gorm:"column:nama_racik"` // Kosong jika bukan racikan
+}
+
+// PemberianRow: ringkasan pemberian obat di bangsal (detail_pemberian_obat) per barang
+type PemberianRow struct {
+	KodeBrng        string  `gorm:"column:kode_brng"`
+	NamaBrng        string  `gorm:"column:nama_brng"`
+	Satuan          string  `gorm:"column:satuan"`
+	Pertama         string  `gorm:"column:pertama"`  // YYYY-MM-DD HH:MM:SS
+	Terakhir        string  `gorm:"column:terakhir"` // YYYY-MM-DD HH:MM:SS
+	TotalJml        float64 `gorm:"column:total_jml"`
+	JumlahPemberian int     `gorm:"column:jumlah_pemberian"`
+}
+
+// AturanRow: aturan pakai yang dicatat saat pemberian (tabel aturan_pakai)
+type AturanRow struct {
+	KodeBrng string `gorm:"column:kode_brng"`
+	Aturan   string `gorm:"column:aturan"`
+}
+
+// ObatItem: satu obat dalam daftar terapi pasien
+type ObatItem struct {
+	KodeBrng    string `json:"kode_brng"`
+	NamaBrng    string `json:"nama_brng"`
+	Satuan      string `json:"satuan"`
+	AturanPakai string `json:"aturan_pakai"`      // Teks asli dari Khanza
+	Dosis       string `json:"dosis"`             // Diurai dari aturan pakai, mis. "1 tab"
+	Frekuensi   string `json:"frekuensi"`         // Mis. "3x sehari"
+	Rute        string `json:"rute"`              // Oral, IV, IM, SC, ...
+	Racikan     string `json:"racikan,omitempty"` // Nama racikan jika diresepkan sebagai komponen racikan
+
+	TglMulai           string  `json:"tgl_mulai"` // Resep/pemberian pertama
+	TerakhirDiresepkan string  `json:"terakhir_diresepkan,omitempty"`
+	TerakhirDiberikan  string  `json:"terakhir_diberikan,omitempty"`
+	JumlahPemberian    int     `json:"jumlah_pemberian"`
+	TotalDiberikan     float64 `json:"total_diberikan"`
+	DokterPeresep      string  `json:"dokter_peresep,omitempty"`
+	Aktif              bool    `json:"aktif"` // Diresepkan/diberikan dalam 48 jam terakhir
+}
+
+type ObatFilter struct {
+	All bool `form:"all"` // true = termasuk obat yang sudah tidak diberikan
+}
+
+type ObatResponse struct {
+	Status  string     `json:"status"`
+	Message string     `json:"message"`
+	NoRawat string     `json:"no_rawat"`
+	Aktif   int        `json:"aktif"`
+	Data    []ObatItem `json:"data"`
+}
+
+// ResepItemRequest: satu item resep baru
+type ResepItemRequest struct {
+	KodeBrng    string  `json:"kode_brng" binding:"required"`
+	Jml         float64 `json:"jml" binding:"required,gt=0"`
+	AturanPakai string  `json:"aturan_pakai" binding:"required"` // Mis. "3x1 tab p.o. sesudah makan"
+}
+
+// ResepCreateRequest: resep rawat inap dari PWA. Peringatan (duplikasi terapi, alergi)
+// menolak resep sampai dikirim ulang dengan konfirmasi = true.
+type ResepCreateRequest struct {
+	Items      []ResepItemRequest `json:"items" binding:"required,min=1,max=30,dive"`
+	Konfirmasi bool               `json:"konfirmasi"`
+}
+
+// BarangRow: data barang + stok untuk validasi resep
+type BarangRow struct {
+	KodeBrng string  `gorm:"column:kode_brng"`
+	NamaBrng string  `gorm:"column:nama_brng"`
+	Satuan   string  `gorm:"column:satuan"`
+	Status   string  `gorm:"column:status"` // '1' aktif
+	Stok     float64 `gorm:"column:stok"`
+}
+
+// Jenis peringatan resep
+const (
+	WarningDuplikasi = "duplikasi_terapi"
+	WarningAlergi    = "alergi"
+)
+
+type ResepWarning struct {
+	Jenis    string `json:"jenis"`
+	KodeBrng string `json:"kode_brng"`
+	NamaBrng string `json:"nama_brng"`
+	Pesan    string `json:"pesan"`
+}
+
+type ResepItem struct {
+	KodeBrng    string  `json:"kode_brng"`
+	NamaBrng    string  `json:"nama_brng"`
+	Satuan      string  `json:"satuan"`
+	Jml         float64 `json:"jml"`
+	AturanPakai string  `json:"aturan_pakai"`
+}
+
+// Resep: resep_obat yang dibuat + itemnya
+type Resep struct {
+	NoResep      string         `json:"no_resep"`
+	NoRawat      string         `json:"no_rawat"`
+	KdDokter     string         `json:"kd_dokter"`
+	TglPeresepan string         `json:"tgl_peresepan"`
+	JamPeresepan string         `json:"jam_peresepan"`
+	Items        []ResepItem    `json:"items"`
+	Peringatan   []ResepWarning `json:"peringatan,omitempty"` // Peringatan yang sudah dikonfirmasi dokter
+}
+
+// KatalogRow: satu barang aktif databarang untuk indeks pencarian obat
+type KatalogRow struct {
+	KodeBrng  string  `gorm:"column:kode_brng"`
+	NamaBrng  string  `gorm:"column:nama_brng"`
+	Sediaan   string  `gorm:"column:sediaan"` // jenis.nama, mis. "Tablet", "Injeksi"
+	Kapasitas float64 `gorm:"column:kapasitas"`
+	Satuan    string  `gorm:"column:satuan"`
+	Kategori  string  `gorm:"column:kategori"`
+	Golongan  string  `gorm:"column:golongan"`
+}
+
+// ✅ Nama generik per barang (databarang Khanza tidak punya kolom generik), diisi instalasi farmasi
+type ObatGenerik struct {
+	KodeBrng    string `json:"kode_brng" gorm:"column:kode_brng;primaryKey;size:15"`
+	NamaGenerik string `json:"nama_generik" gorm:"column:nama_generik;size:100"`
+}
+
+func (ObatGenerik) TableName() string {
+	return "pwa_obat_generik"
+}
+
+// ✅ Barang yang masuk formularium penjamin (penjab.kd_pj), diisi instalasi farmasi
+type ObatFormularium struct {
+	ID       uint64 `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	KdPj     string `json:"kd_pj" gorm:"column:kd_pj;size:3;uniqueIndex:idx_formularium_pj_brng"`
+	KodeBrng string `json:"kode_brng" gorm:"column:kode_brng;size:15;uniqueIndex:idx_formularium_pj_brng"`
+}
+
+func (ObatFormularium) TableName() string {
+	return "pwa_obat_formularium"
+}
+
+// ObatGenerikImportRequest: impor/perbarui nama generik per barang (upsert per kode_brng)
+type ObatGenerikImportRequest struct {
+	Items []ObatGenerik `json:"items" binding:"required,min=1,max=5000"`
+}
+
+// FormulariumImportRequest: daftar lengkap barang formularium satu penjamin, menggantikan daftar lama
+type FormulariumImportRequest struct {
+	KodeBrng []string `json:"kode_brng" binding:"max=5000"`
+}
+
+type ObatSearchFilter struct {
+	Q     string `form:"q"`
+	KdPj  string `form:"kd_pj"` // Opsional: tandai & prioritaskan obat formularium penjamin ini
+	Limit int    `form:"limit"`
+}
+
+// ObatSearchResult: satu hasil pencarian obat
+type ObatSearchResult struct {
+	KodeBrng         string   `json:"kode_brng"`
+	NamaBrng         string   `json:"nama_brng"`
+	NamaGenerik      string   `json:"nama_generik"`
+	Sediaan          string   `json:"sediaan"`
+	Kekuatan         string   `json:"kekuatan"` // Diurai dari nama, mis. "500 mg", fallback databarang.kapasitas
+	Satuan           string   `json:"satuan"`
+	Kategori         string   `json:"kategori"`
+	Golongan         string   `json:"golongan"`
+	Formularium      []string `json:"formularium"`                 // kd_pj yang memasukkan obat ini ke formularium
+	MasukFormularium *bool    `json:"masuk_formularium,omitempty"` // Hanya jika kd_pj diisi
+	Skor             float64  `json:"skor"`
+}
+
+type ObatSearchResponse struct {
+	Status    string             `json:"status"`
+	Message   string             `json:"message"`
+	Query     string             `json:"query"`
+	Total     int                `json:"total"` // Semua yang cocok; data dipotong sesuai limit
+	IndexedAt time.Time          `json:"indexed_at"`
+	Data      []ObatSearchResult `json:"data"`
+}
@@ -0,0 +1,356 @@
+package obat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ObatRepository interface {
+	FindResep(noRawat string) ([]ResepRow, error)
+	FindPemberian(noRawat string) ([]PemberianRow, error)
+	FindAturanPakai(noRawat string) ([]AturanRow, error)
+
+	// E-resep
+	FindBarang(kodeBrng []string, kdDepo string) ([]BarangRow, error)
+	FindAlergi(noRawat string) ([]string, error)
+	CreateResep(resep *Resep) error
+	FindKatalog() ([]KatalogRow, error)
+	FindGenerik() ([]ObatGenerik, error)
+	FindGenerikByKode(kodeBrng []string) ([]ObatGenerik, error)
+	FindFormularium() ([]ObatFormularium, error)
+
+	// Master generik & formularium (admin)
+	FindExistingBarang(kodeBrng []string) ([]string, error)
+	PenjabExists(kdPj string) (bool, error)
+	SaveGenerik(rows []ObatGenerik) error
+	DeleteGenerik(kodeBrng string) error
+	FindFormulariumByPj(kdPj string) ([]ObatFormularium, error)
+	ReplaceFormularium(kdPj string, kodeBrng []string) error
+	DeleteFormularium(kdPj, kodeBrng string) error
+}
+
+type obatRepository struct {
+	db *gorm.DB
+}
+
+func NewObatRepository(db *gorm.DB) ObatRepository {
+	return &obatRepository{
+		db: db,
+	}
+}
+
+// ✅ Resep dokter selama rawat inap, terlama di atas. Racikan diurai per komponen
+// (resep_dokter_racikan_detail) dengan aturan pakai dan nama racikan dari header racikan.
+func (r *obatRepository) FindResep(noRawat string) ([]ResepRow, error) {
+	var rows []ResepRow
+	err := r.db.Raw(`
+	SELECT
+		ro.no_resep,
+		CONCAT(DATE_FORMAT(ro.tgl_peresepan, '%Y-%m-%d'), ' ', CAST(ro.jam_peresepan AS CHAR)) as waktu,
+		ro.kd_dokter,
+		COALESCE(d.nm_dokter, ro.kd_dokter) as nm_dokter,
+		rd.kode_brng,
+		COALESCE(db.nama_brng, rd.kode_brng) as nama_brng,
+		COALESCE(ks.satuan, '') as satuan,
+		rd.jml,
+		COALESCE(rd.aturan_pakai, '') as aturan_pakai,
+		'' as nama_racik
+	FROM resep_obat ro
+	JOIN resep_dokter rd ON ro.no_resep = rd.no_resep
+	LEFT JOIN dokter d ON ro.kd_dokter = d.kd_dokter
+	LEFT JOIN databarang db ON rd.kode_brng = db.kode_brng
+	LEFT JOIN kodesatuan ks ON db.kode_sat = ks.kode_sat
+	WHERE ro.no_rawat = ?
+	UNION ALL
+	SELECT
+		ro.no_resep,
+		CONCAT(DATE_FORMAT(ro.tgl_peresepan, '%Y-%m-%d'), ' ', CAST(ro.jam_peresepan AS CHAR)) as waktu,
+		ro.kd_dokter,
+		COALESCE(d.nm_dokter, ro.kd_dokter) as nm_dokter,
+		rdd.kode_brng,
+		COALESCE(db.nama_brng, rdd.kode_brng) as nama_brng,
+		COALESCE(ks.satuan, '') as satuan,
+		rdd.jml,
+		COALESCE(rdr.aturan_pakai, '') as aturan_pakai,
+		COALESCE(rdr.nama_racik, '') as nama_racik
+	FROM resep_obat ro
+	JOIN resep_dokter_racikan rdr ON ro.no_resep = rdr.no_resep
+	JOIN resep_dokter_racikan_detail rdd ON rdr.no_resep = rdd.no_resep AND rdr.no_racik = rdd.no_racik
+	LEFT JOIN dokter d ON ro.kd_dokter = d.kd_dokter
+	LEFT JOIN databarang db ON rdd.kode_brng = db.kode_brng
+	LEFT JOIN kodesatuan ks ON db.kode_sat = ks.kode_sat
+	WHERE ro.no_rawat = ?
+	ORDER BY waktu`, noRawat, noRawat).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ✅ Pemberian obat ke pasien, diringkas per barang
+func (r *obatRepository) FindPemberian(noRawat string) ([]PemberianRow, error) {
+	var rows []PemberianRow
+	err := r.db.Raw(`
+	SELECT
+		dpo.kode_brng,
+		COALESCE(db.nama_brng, dpo.kode_brng) as nama_brng,
+		COALESCE(ks.satuan, '') as satuan,
+		MIN(CONCAT(DATE_FORMAT(dpo.tgl_perawatan, '%Y-%m-%d'), ' ', CAST(dpo.jam AS CHAR))) as pertama,
+		MAX(CONCAT(DATE_FORMAT(dpo.tgl_perawatan, '%Y-%m-%d'), ' ', CAST(dpo.jam AS CHAR))) as terakhir,
+		SUM(dpo.jml) as total_jml,
+		COUNT(*) as jumlah_pemberian
+	FROM detail_pemberian_obat dpo
+	LEFT JOIN databarang db ON dpo.kode_brng = db.kode_brng
+	LEFT JOIN kodesatuan ks ON db.kode_sat = ks.kode_sat
+	WHERE dpo.no_rawat = ?
+	GROUP BY dpo.kode_brng, db.nama_brng, ks.satuan`, noRawat).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Aturan pakai saat pemberian, terbaru di atas
+func (r *obatRepository) FindAturanPakai(noRawat string) ([]AturanRow, error) {
+	var rows []AturanRow
+	err := r.db.Raw(`
+	SELECT kode_brng, aturan
+	FROM aturan_pakai
+	WHERE no_rawat = ? AND aturan <> ''
+	ORDER BY tgl_perawatan DESC, jam DESC`, noRawat).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ✅ Barang yang diresepkan beserta stok (satu depo, atau semua gudang jika kdDepo kosong)
+func (r *obatRepository) FindBarang(kodeBrng []string, kdDepo string) ([]BarangRow, error) {
+	stokWhere := ""
+	args := []interface{}{}
+	if kdDepo != "" {
+		stokWhere = " AND kd_bangsal = ?"
+		args = append(args, kdDepo)
+	}
+	args = append(args, kodeBrng)
+
+	var rows []BarangRow
+	err := r.db.Raw(`
+	SELECT
+		db.kode_brng,
+		db.nama_brng,
+		COALESCE(ks.satuan, '') as satuan,
+		db.status,
+		COALESCE(stok.stok, 0) as stok
+	FROM databarang db
+	LEFT JOIN kodesatuan ks ON db.kode_sat = ks.kode_sat
+	LEFT JOIN (
+		SELECT kode_brng, SUM(stok) as stok FROM gudangbarang WHERE 1 = 1`+stokWhere+` GROUP BY kode_brng
+	) stok ON db.kode_brng = stok.kode_brng
+	WHERE db.kode_brng IN ?`, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Riwayat alergi pasien dari semua CPPT rawat inap (semua kunjungan pasien yang sama)
+func (r *obatRepository) FindAlergi(noRawat string) ([]string, error) {
+	var alergi []string
+	err := r.db.Raw(`
+	SELECT DISTINCT pr.alergi
+	FROM pemeriksaan_ranap pr
+	JOIN reg_periksa rp ON pr.no_rawat = rp.no_rawat
+	WHERE rp.no_rkm_medis = (SELECT no_rkm_medis FROM reg_periksa WHERE no_rawat = ?)
+	AND pr.alergi IS NOT NULL AND TRIM(pr.alergi) NOT IN ('', '-')`, noRawat).Scan(&alergi).Error
+	if err != nil {
+		return nil, err
+	}
+	return alergi, nil
+}
+
+// ✅ Simpan resep_obat + resep_dokter dalam satu transaksi. no_resep mengikuti format Khanza
+// (yyyyMMdd + 4 digit urut); urutan dihitung dari no_resep berawalan tanggal yang sama, bukan
+// tgl_peresepan, karena resep dari Khanza bisa bernomor tanggal lain. Bentrok nomor dicoba ulang.
+func (r *obatRepository) CreateResep(resep *Resep) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		err = r.db.Transaction(func(tx *gorm.DB) error {
+			var last int
+			prefix := strings.ReplaceAll(resep.TglPeresepan, "-", "")
+			err := tx.Raw(`SELECT COALESCE(MAX(CONVERT(RIGHT(no_resep, 4), SIGNED)), 0) FROM resep_obat WHERE no_resep LIKE ? FOR UPDATE`,
+				prefix+"%").Scan(&last).Error
+			if err != nil {
+				return err
+			}
+			resep.NoResep = fmt.Sprintf("%s%04d", prefix, last+1)
+
+			// Belum diserahkan farmasi: tanggal/jam perawatan dan penyerahan diisi 0000-00-00 seperti e-resep Khanza
+			err = tx.Exec(`
+				INSERT INTO resep_obat (
+					no_resep, tgl_perawatan, jam, no_rawat, kd_dokter,
+					tgl_peresepan, jam_peresepan, status, tgl_penyerahan, jam_penyerahan
+				) VALUES (?, '0000-00-00', '00:00:00', ?, ?, ?, ?, 'ranap', '0000-00-00', '00:00:00')
+			`, resep.NoResep, resep.NoRawat, resep.KdDokter, resep.TglPeresepan, resep.JamPeresepan).Error
+			if err != nil {
+				return err
+			}
+
+			for _, item := range resep.Items {
+				err = tx.Exec(`INSERT INTO resep_dokter (no_resep, kode_brng, jml, aturan_pakai) VALUES (?, ?, ?, ?)`,
+					resep.NoResep, item.KodeBrng, item.Jml, item.AturanPakai).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			time.Sleep(time.Duration(attempt+1) * 50 * time.Millisecond)
+			continue
+		}
+		return err
+	}
+	return err
+}
+
+// Semua barang aktif untuk indeks pencarian obat (dibaca berkala, bukan per ketikan)
+func (r *obatRepository) FindKatalog() ([]KatalogRow, error) {
+	var rows []KatalogRow
+	err := r.db.Raw(`
+	SELECT
+		db.kode_brng,
+		db.nama_brng,
+		COALESCE(j.nama, '') as sediaan,
+		COALESCE(db.kapasitas, 0) as kapasitas,
+		COALESCE(ks.satuan, '') as satuan,
+		COALESCE(kb.nama, '') as kategori,
+		COALESCE(gb.nama, '') as golongan
+	FROM databarang db
+	LEFT JOIN jenis j ON db.kdjns = j.kdjns
+	LEFT JOIN kodesatuan ks ON db.kode_sat = ks.kode_sat
+	LEFT JOIN kategori_barang kb ON db.kode_kategori = kb.kode
+	LEFT JOIN golongan_barang gb ON db.kode_golongan = gb.kode
+	WHERE db.status = '1'
+	ORDER BY db.nama_brng`).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Tabel aplikasi dibaca terpisah (collation berbeda dengan tabel Khanza), digabung di indeks
+func (r *obatRepository) FindGenerik() ([]ObatGenerik, error) {
+	var rows []ObatGenerik
+	if err := r.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Nama generik untuk barang tertentu, dipakai cek duplikasi terapi dan alergi e-resep
+func (r *obatRepository) FindGenerikByKode(kodeBrng []string) ([]ObatGenerik, error) {
+	var rows []ObatGenerik
+	if len(kodeBrng) == 0 {
+		return rows, nil
+	}
+	if err := r.db.Where("kode_brng IN ?", kodeBrng).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *obatRepository) FindFormularium() ([]ObatFormularium, error) {
+	var rows []ObatFormularium
+	if err := r.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Kode barang yang benar-benar ada di databarang (aktif maupun tidak)
+func (r *obatRepository) FindExistingBarang(kodeBrng []string) ([]string, error) {
+	var kode []string
+	if len(kodeBrng) == 0 {
+		return kode, nil
+	}
+	err := r.db.Raw(`SELECT kode_brng FROM databarang WHERE kode_brng IN ?`, kodeBrng).Scan(&kode).Error
+	if err != nil {
+		return nil, err
+	}
+	return kode, nil
+}
+
+func (r *obatRepository) PenjabExists(kdPj string) (bool, error) {
+	var count int64
+	err := r.db.Raw(`SELECT COUNT(*) FROM penjab WHERE kd_pj = ?`, kdPj).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Upsert nama generik per kode_brng dalam satu transaksi
+func (r *obatRepository) SaveGenerik(rows []ObatGenerik) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "kode_brng"}},
+			DoUpdates: clause.AssignmentColumns([]string{"nama_generik"}),
+		}).CreateInBatches(rows, 500).Error
+	})
+}
+
+func (r *obatRepository) DeleteGenerik(kodeBrng string) error {
+	result := r.db.Where("kode_brng = ?", kodeBrng).Delete(&ObatGenerik{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *obatRepository) FindFormulariumByPj(kdPj string) ([]ObatFormularium, error) {
+	var rows []ObatFormularium
+	if err := r.db.Where("kd_pj = ?", kdPj).Order("kode_brng").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Ganti seluruh formularium satu penjamin (impor daftar dari instalasi farmasi)
+func (r *obatRepository) ReplaceFormularium(kdPj string, kodeBrng []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("kd_pj = ?", kdPj).Delete(&ObatFormularium{}).Error; err != nil {
+			return err
+		}
+		if len(kodeBrng) == 0 {
+			return nil
+		}
+		rows := make([]ObatFormularium, 0, len(kodeBrng))
+		for _, kode := range kodeBrng {
+			rows = append(rows, ObatFormularium{KdPj: kdPj, KodeBrng: kode})
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+func (r *obatRepository) DeleteFormularium(kdPj, kodeBrng string) error {
+	result := r.db.Where("kd_pj = ? AND kode_brng = ?", kdPj, kodeBrng).Delete(&ObatFormularium{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
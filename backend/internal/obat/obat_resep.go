@@ -0,0 +1,254 @@
+package obat
+
+import (
+	"errors"
+	"fmt"
+	"pwa-rsbw/internal/listranap"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWriteDenied  = errors.New("only the DPJP or consultant of an admitted patient can prescribe")
+	ErrResepInvalid = errors.New("invalid prescription")
+	ErrNeedsConfirm = errors.New("prescription has warnings that must be confirmed")
+)
+
+const (
+	maxAturanPakai   = 150 // resep_dokter.aturan_pakai varchar(150)
+	minAlergiKeyword = 3   // Cocok per kata utuh, jadi singkatan seperti "ctm" tetap terbaca
+)
+
+// Isian alergi yang berarti "tidak ada alergi"
+var alergiStopwords = map[string]bool{
+	"tidak ada": true, "tidak": true, "tdk ada": true, "tak ada": true,
+	"disangkal": true, "negatif": true, "-": true, "obat": true,
+}
+
+// ResepWarningsError membawa daftar peringatan yang belum dikonfirmasi
+type ResepWarningsError struct {
+	Warnings []ResepWarning
+}
+
+func (e *ResepWarningsError) Error() string {
+	return fmt.Sprintf("%s (%d warnings)", ErrNeedsConfirm.Error(), len(e.Warnings))
+}
+
+func (e *ResepWarningsError) Unwrap() error {
+	return ErrNeedsConfirm
+}
+
+// ✅ CreateResep: validasi barang, stok, duplikasi terapi dan alergi, lalu tulis resep_obat + resep_dokter
+func (s *obatService) CreateResep(noRawat, kdDokter string, req ResepCreateRequest) (*Resep, error) {
+	fmt.Printf("💊 Creating prescription for: %s by doctor: %s (%d items)\n", noRawat, kdDokter, len(req.Items))
+
+	if err := s.access.CheckWriteAccess(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ Prescription denied for %s: %v\n", kdDokter, err)
+		if errors.Is(err, listranap.ErrAksesDitolak) || errors.Is(err, listranap.ErrPasienTidakAktif) {
+			return nil, ErrWriteDenied
+		}
+		return nil, err
+	}
+
+	// 1. Validasi isi request
+	var problems []string
+	kode := make([]string, 0, len(req.Items))
+	seen := map[string]bool{}
+	for i := range req.Items {
+		item := &req.Items[i]
+		item.KodeBrng = strings.TrimSpace(item.KodeBrng)
+		item.AturanPakai = strings.TrimSpace(item.AturanPakai)
+		if seen[item.KodeBrng] {
+			problems = append(problems, fmt.Sprintf("%s is listed more than once", item.KodeBrng))
+		}
+		seen[item.KodeBrng] = true
+		if item.AturanPakai == "" || len([]rune(item.AturanPakai)) > maxAturanPakai {
+			problems = append(problems, fmt.Sprintf("%s: aturan_pakai is required (max %d characters)", item.KodeBrng, maxAturanPakai))
+		}
+		kode = append(kode, item.KodeBrng)
+	}
+
+	// 2. Barang harus ada, aktif, dan stok depo cukup
+	barang, err := s.obatRepo.FindBarang(kode, s.kdDepo)
+	if err != nil {
+		fmt.Printf("❌ Error looking up items: %v\n", err)
+		return nil, err
+	}
+	byKode := make(map[string]BarangRow, len(barang))
+	for _, row := range barang {
+		byKode[row.KodeBrng] = row
+	}
+	for _, item := range req.Items {
+		row, ok := byKode[item.KodeBrng]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: item not found", item.KodeBrng))
+		case row.Status != "1":
+			problems = append(problems, fmt.Sprintf("%s (%s): item is inactive", item.KodeBrng, row.NamaBrng))
+		case row.Stok < item.Jml:
+			problems = append(problems, fmt.Sprintf("%s (%s): insufficient stock (%g %s available)", item.KodeBrng, row.NamaBrng, row.Stok, row.Satuan))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrResepInvalid, strings.Join(problems, "; "))
+	}
+
+	// 3. Peringatan klinis: duplikasi dengan terapi aktif dan kecocokan dengan riwayat alergi
+	warnings, err := s.resepWarnings(noRawat, req.Items, byKode)
+	if err != nil {
+		return nil, err
+	}
+	if len(warnings) > 0 && !req.Konfirmasi {
+		fmt.Printf("⚠️ Prescription for %s needs confirmation: %d warnings\n", noRawat, len(warnings))
+		return nil, &ResepWarningsError{Warnings: warnings}
+	}
+
+	now := time.Now()
+	resep := &Resep{
+		NoRawat:      noRawat,
+		KdDokter:     kdDokter,
+		TglPeresepan: now.Format("2006-01-02"),
+		JamPeresepan: now.Format("15:04:05"),
+		Items:        make([]ResepItem, 0, len(req.Items)),
+		Peringatan:   warnings,
+	}
+	for _, item := range req.Items {
+		row := byKode[item.KodeBrng]
+		resep.Items = append(resep.Items, ResepItem{
+			KodeBrng:    item.KodeBrng,
+			NamaBrng:    row.NamaBrng,
+			Satuan:      row.Satuan,
+			Jml:         item.Jml,
+			AturanPakai: item.AturanPakai,
+		})
+	}
+
+	if err := s.obatRepo.CreateResep(resep); err != nil {
+		fmt.Printf("❌ Failed to create prescription: %v\n", err)
+		return nil, err
+	}
+
+	fmt.Printf("✅ Prescription %s created for %s\n", resep.NoResep, noRawat)
+	return resep, nil
+}
+
+func (s *obatService) resepWarnings(noRawat string, items []ResepItemRequest, byKode map[string]BarangRow) ([]ResepWarning, error) {
+	current, err := s.currentObat(noRawat)
+	if err != nil {
+		return nil, err
+	}
+	var aktif []ObatItem
+	kode := make([]string, 0, len(items)+len(current))
+	for _, item := range items {
+		kode = append(kode, item.KodeBrng)
+	}
+	for _, item := range current {
+		if item.Aktif {
+			aktif = append(aktif, item)
+			kode = append(kode, item.KodeBrng)
+		}
+	}
+
+	generik, err := s.obatRepo.FindGenerikByKode(kode)
+	if err != nil {
+		fmt.Printf("❌ Error getting generic names: %v\n", err)
+		return nil, err
+	}
+	generikByKode := make(map[string]string, len(generik))
+	for _, row := range generik {
+		generikByKode[row.KodeBrng] = strings.ToLower(strings.TrimSpace(row.NamaGenerik))
+	}
+
+	alergi, err := s.obatRepo.FindAlergi(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Error getting allergy history: %v\n", err)
+		return nil, err
+	}
+
+	return checkResep(items, byKode, aktif, generikByKode, alergiKeywords(alergi)), nil
+}
+
+// checkResep: duplikasi terapi per nama generik (fallback kode barang jika generik belum diisi),
+// termasuk dua merek generik sama dalam satu resep, dan kecocokan alergi pada nama barang atau generik
+func checkResep(items []ResepItemRequest, byKode map[string]BarangRow, aktif []ObatItem, generikByKode map[string]string, keywords []string) []ResepWarning {
+	var warnings []ResepWarning
+
+	terapiKey := func(kodeBrng string) string {
+		if generik := generikByKode[kodeBrng]; generik != "" {
+			return "generik:" + generik
+		}
+		return "kode:" + kodeBrng
+	}
+	existing := map[string]string{}
+	for _, item := range aktif {
+		existing[terapiKey(item.KodeBrng)] = fmt.Sprintf("already active: %s %s (last %s)", item.NamaBrng, item.AturanPakai, lastEvent(item))
+	}
+
+	for _, item := range items {
+		row := byKode[item.KodeBrng]
+		key := terapiKey(item.KodeBrng)
+		if pesan, ok := existing[key]; ok {
+			warnings = append(warnings, ResepWarning{
+				Jenis:    WarningDuplikasi,
+				KodeBrng: item.KodeBrng,
+				NamaBrng: row.NamaBrng,
+				Pesan:    pesan,
+			})
+		} else {
+			existing[key] = fmt.Sprintf("same drug already in this prescription: %s", row.NamaBrng)
+		}
+
+		for _, keyword := range keywords {
+			if matchesAlergi(keyword, row.NamaBrng) || matchesAlergi(keyword, generikByKode[item.KodeBrng]) {
+				warnings = append(warnings, ResepWarning{
+					Jenis:    WarningAlergi,
+					KodeBrng: item.KodeBrng,
+					NamaBrng: row.NamaBrng,
+					Pesan:    fmt.Sprintf("patient has a recorded allergy to %q", keyword),
+				})
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// matchesAlergi: semua kata kunci alergi muncul sebagai kata utuh di nama ("ctm" cocok dengan
+// "CTM 4 mg" tapi tidak dengan "Octmide"; "asam mefenamat" cocok dengan "Asam Mefenamat 500 mg")
+func matchesAlergi(keyword, nama string) bool {
+	terms := tokenize(keyword)
+	if nama == "" || len(terms) == 0 {
+		return false
+	}
+	words := map[string]bool{}
+	for _, word := range tokenize(nama) {
+		words[word] = true
+	}
+	for _, word := range terms {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// alergiKeywords: teks bebas alergi dari CPPT ("amoxicillin, seafood; ctm") → kata kunci huruf kecil
+func alergiKeywords(alergi []string) []string {
+	var keywords []string
+	seen := map[string]bool{}
+	for _, text := range alergi {
+		parts := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return r == ',' || r == ';' || r == '/' || r == '\n' || r == '+'
+		})
+		for _, part := range parts {
+			part = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(part), "alergi"))
+			part = strings.TrimSpace(strings.TrimPrefix(part, "obat "))
+			if len(part) < minAlergiKeyword || alergiStopwords[part] || seen[part] {
+				continue
+			}
+			seen[part] = true
+			keywords = append(keywords, part)
+		}
+	}
+	return keywords
+}
@@ -0,0 +1,95 @@
+package obat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAlergiKeywords(t *testing.T) {
+	cases := []struct {
+		name   string
+		alergi []string
+		want   []string
+	}{
+		{"empty", nil, nil},
+		{"stopwords", []string{"tidak ada", "-", "Disangkal"}, nil},
+		{"split and dedupe", []string{"Amoxicillin, seafood; CTM", "amoxicillin / ibuprofen"}, []string{"amoxicillin", "seafood", "ctm", "ibuprofen"}},
+		{"strip prefix", []string{"alergi obat ctm", "Alergi asam mefenamat"}, []string{"ctm", "asam mefenamat"}},
+		{"too short", []string{"ab, ctm"}, []string{"ctm"}},
+		{"unspecified drug", []string{"alergi obat"}, nil},
+	}
+	for _, c := range cases {
+		if got := alergiKeywords(c.alergi); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: alergiKeywords(%q) = %q, want %q", c.name, c.alergi, got, c.want)
+		}
+	}
+}
+
+func TestMatchesAlergi(t *testing.T) {
+	cases := []struct {
+		keyword string
+		nama    string
+		want    bool
+	}{
+		{"ctm", "CTM 4 mg tab", true},
+		{"ctm", "Octmide 10 mg", false},
+		{"asam mefenamat", "Asam Mefenamat 500 mg", true},
+		{"asam mefenamat", "Asam Folat 1 mg", false},
+		{"amoxicillin", "", false},
+		{"...", "Paracetamol 500 mg", false},
+	}
+	for _, c := range cases {
+		if got := matchesAlergi(c.keyword, c.nama); got != c.want {
+			t.Errorf("matchesAlergi(%q, %q) = %v, want %v", c.keyword, c.nama, got, c.want)
+		}
+	}
+}
+
+func TestCheckResep(t *testing.T) {
+	byKode := map[string]BarangRow{
+		"B001": {KodeBrng: "B001", NamaBrng: "Sanmol 500 mg"},
+		"B002": {KodeBrng: "B002", NamaBrng: "CTM 4 mg"},
+		"B003": {KodeBrng: "B003", NamaBrng: "Amoxsan 500 mg"},
+		"B004": {KodeBrng: "B004", NamaBrng: "Omeprazole 20 mg"},
+		"B005": {KodeBrng: "B005", NamaBrng: "Lansoprazole 30 mg"},
+	}
+	generik := map[string]string{
+		"B001": "paracetamol",
+		"B002": "chlorpheniramine maleate",
+		"B003": "amoxicillin",
+		"P010": "paracetamol",
+	}
+	aktif := []ObatItem{
+		{KodeBrng: "P010", NamaBrng: "Paracetamol 500 mg", AturanPakai: "3x1", TerakhirDiberikan: "2026-03-10 06:00:00"},
+		{KodeBrng: "B004", NamaBrng: "Omeprazole 20 mg", AturanPakai: "1x1", TerakhirDiresepkan: "2026-03-09 08:00:00"},
+	}
+
+	type warning struct{ jenis, kode string }
+	cases := []struct {
+		name     string
+		items    []string
+		keywords []string
+		want     []warning
+	}{
+		{"no warnings", []string{"B005"}, []string{"seafood"}, nil},
+		{"same generic, other brand", []string{"B001"}, nil, []warning{{WarningDuplikasi, "B001"}}},
+		{"same item without generic", []string{"B004"}, nil, []warning{{WarningDuplikasi, "B004"}}},
+		{"short allergy keyword on name", []string{"B002"}, []string{"ctm"}, []warning{{WarningAlergi, "B002"}}},
+		{"allergy on generic only", []string{"B003"}, []string{"amoxicillin"}, []warning{{WarningAlergi, "B003"}}},
+		{"duplicate within prescription", []string{"B003", "B003"}, nil, []warning{{WarningDuplikasi, "B003"}}},
+		{"both warnings", []string{"B001"}, []string{"paracetamol"}, []warning{{WarningDuplikasi, "B001"}, {WarningAlergi, "B001"}}},
+	}
+	for _, c := range cases {
+		items := make([]ResepItemRequest, 0, len(c.items))
+		for _, kode := range c.items {
+			items = append(items, ResepItemRequest{KodeBrng: kode, Jml: 1, AturanPakai: "3x1"})
+		}
+		var got []warning
+		for _, w := range checkResep(items, byKode, aktif, generik, c.keywords) {
+			got = append(got, warning{w.Jenis, w.KodeBrng})
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package obat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrQueryTooShort = errors.New("search query must be at least 2 characters")
+	ErrIndexNotReady = errors.New("drug index is not loaded yet")
+)
+
+const (
+	minQueryLength     = 2
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// "500 mg", "0,5 mg/ml", "125mg/5ml", "1 g", "10%"
+var kekuatanPattern = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\s*((?:mcg|mg|gr|g|ml|iu|unit)\b|%)(?:\s*/\s*(\d+(?:[.,]\d+)?)?\s*(ml|g|tab|dosis)\b)?`)
+
+type indexEntry struct {
+	result      ObatSearchResult
+	formularium map[string]bool
+	nama        string   // nama_brng huruf kecil, untuk bonus awalan
+	words       []string // Token nama, generik, sediaan, kekuatan, satuan
+}
+
+// ObatIndex: katalog obat di memori agar pencarian per ketikan tidak memakai LIKE ke databarang
+type ObatIndex struct {
+	repo ObatRepository
+
+	mu       sync.RWMutex
+	entries  []indexEntry
+	loadedAt time.Time
+}
+
+func NewObatIndex(repo ObatRepository) *ObatIndex {
+	return &ObatIndex{repo: repo}
+}
+
+// Refresh membangun ulang indeks dari databarang + tabel generik/formularium aplikasi
+func (x *ObatIndex) Refresh() error {
+	katalog, err := x.repo.FindKatalog()
+	if err != nil {
+		return fmt.Errorf("gagal mengambil databarang: %w", err)
+	}
+	generik, err := x.repo.FindGenerik()
+	if err != nil {
+		return fmt.Errorf("gagal mengambil nama generik: %w", err)
+	}
+	formularium, err := x.repo.FindFormularium()
+	if err != nil {
+		return fmt.Errorf("gagal mengambil formularium: %w", err)
+	}
+
+	generikByKode := make(map[string]string, len(generik))
+	for _, row := range generik {
+		generikByKode[row.KodeBrng] = strings.TrimSpace(row.NamaGenerik)
+	}
+	formulariumByKode := map[string]map[string]bool{}
+	for _, row := range formularium {
+		if formulariumByKode[row.KodeBrng] == nil {
+			formulariumByKode[row.KodeBrng] = map[string]bool{}
+		}
+		formulariumByKode[row.KodeBrng][row.KdPj] = true
+	}
+
+	entries := make([]indexEntry, 0, len(katalog))
+	for _, row := range katalog {
+		result := ObatSearchResult{
+			KodeBrng:    row.KodeBrng,
+			NamaBrng:    strings.TrimSpace(row.NamaBrng),
+			NamaGenerik: generikByKode[row.KodeBrng],
+			Sediaan:     strings.TrimSpace(row.Sediaan),
+			Kekuatan:    parseKekuatan(row.NamaBrng, row.Kapasitas),
+			Satuan:      strings.TrimSpace(row.Satuan),
+			Kategori:    strings.TrimSpace(row.Kategori),
+			Golongan:    strings.TrimSpace(row.Golongan),
+		}
+		pj := formulariumByKode[row.KodeBrng]
+		for kdPj := range pj {
+			result.Formularium = append(result.Formularium, kdPj)
+		}
+		sort.Strings(result.Formularium)
+
+		entries = append(entries, indexEntry{
+			result:      result,
+			formularium: pj,
+			nama:        strings.ToLower(result.NamaBrng),
+			words:       tokenize(strings.Join([]string{result.NamaBrng, result.NamaGenerik, result.Sediaan, result.Kekuatan, result.Satuan}, " ")),
+		})
+	}
+
+	x.mu.Lock()
+	x.entries = entries
+	x.loadedAt = time.Now()
+	x.mu.Unlock()
+
+	fmt.Printf("✅ Drug index refreshed: %d items, %d formulary entries\n", len(entries), len(formularium))
+	return nil
+}
+
+// StartRefresh memuat ulang indeks secara berkala (perubahan databarang/formularium ikut terbaca)
+func (x *ObatIndex) StartRefresh(interval time.Duration) {
+	log.Printf("✅ Drug index worker dimulai (refresh setiap %v).", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := x.Refresh(); err != nil {
+			log.Printf("ERROR (DrugIndex): %v", err)
+		}
+	}
+}
+
+// Search: pencocokan awalan + fuzzy (salah ketik) per kata, semua kata query harus cocok.
+// total = jumlah semua barang yang cocok, sebelum dipotong limit.
+func (x *ObatIndex) Search(query, kdPj string, limit int) ([]ObatSearchResult, int, time.Time, error) {
+	x.mu.RLock()
+	entries := x.entries
+	loadedAt := x.loadedAt
+	x.mu.RUnlock()
+
+	if loadedAt.IsZero() {
+		return nil, 0, loadedAt, ErrIndexNotReady
+	}
+
+	normalized := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	terms := tokenize(normalized)
+	if len([]rune(normalized)) < minQueryLength || len(terms) == 0 {
+		return nil, 0, loadedAt, ErrQueryTooShort
+	}
+
+	var results []ObatSearchResult
+	for _, entry := range entries {
+		score := 0.0
+		for _, term := range terms {
+			best := 0.0
+			for _, word := range entry.words {
+				if s := matchScore(term, word); s > best {
+					best = s
+				}
+			}
+			if best == 0 {
+				score = 0
+				break
+			}
+			score += best
+		}
+		if score == 0 {
+			continue
+		}
+
+		result := entry.result
+		if strings.HasPrefix(entry.nama, normalized) {
+			score += 2
+		}
+		if kdPj != "" {
+			masuk := entry.formularium[kdPj]
+			result.MasukFormularium = &masuk
+			if masuk {
+				score += 0.5
+			}
+		}
+		result.Skor = score
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Skor != results[j].Skor {
+			return results[i].Skor > results[j].Skor
+		}
+		return results[i].NamaBrng < results[j].NamaBrng
+	})
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, total, loadedAt, nil
+}
+
+// matchScore: 3 = kata sama, 2 = awalan, 1 = bagian kata, <1 = salah ketik (edit distance)
+func matchScore(term, word string) float64 {
+	switch {
+	case term == word:
+		return 3
+	case strings.HasPrefix(word, term):
+		return 2
+	case len(term) >= 3 && strings.Contains(word, term):
+		return 1
+	}
+
+	termLen := len([]rune(term))
+	if termLen < 4 {
+		return 0
+	}
+	maxDist := 1
+	if termLen > 6 {
+		maxDist = 2
+	}
+
+	// Bandingkan juga dengan awalan kata sepanjang term, agar "paracet" cocok ke "parasetamol"
+	wordRunes := []rune(word)
+	dist := levenshtein(term, word)
+	if len(wordRunes) > termLen {
+		if d := levenshtein(term, string(wordRunes[:termLen])); d < dist {
+			dist = d
+		}
+	}
+	if dist > maxDist {
+		return 0
+	}
+	return 1 - 0.25*float64(dist)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j] + 1
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+			if prev[j-1]+cost < curr[j] {
+				curr[j] = prev[j-1] + cost
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+	words := make([]string, 0, len(fields))
+	seen := map[string]bool{}
+	for _, field := range fields {
+		field = strings.Trim(field, ".,")
+		if field == "" || seen[field] {
+			continue
+		}
+		seen[field] = true
+		words = append(words, field)
+	}
+	return words
+}
+
+// parseKekuatan mengambil kekuatan sediaan dari nama barang, mis. "AMOXICILLIN 500 MG KAPS" → "500 mg"
+func parseKekuatan(nama string, kapasitas float64) string {
+	if m := kekuatanPattern.FindStringSubmatch(nama); m != nil {
+		kekuatan := strings.ReplaceAll(m[1], ",", ".") + " " + strings.ToLower(m[2])
+		if m[4] != "" {
+			per := strings.ReplaceAll(m[3], ",", ".")
+			if per != "" {
+				per += " "
+			}
+			kekuatan += "/" + per + strings.ToLower(m[4])
+		}
+		return kekuatan
+	}
+	if kapasitas > 0 {
+		return strconv.FormatFloat(kapasitas, 'f', -1, 64)
+	}
+	return ""
+}
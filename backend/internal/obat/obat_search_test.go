@@ -0,0 +1,85 @@
+package obat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchScore(t *testing.T) {
+	cases := []struct {
+		term string
+		word string
+		want float64
+	}{
+		{"amoxicillin", "amoxicillin", 3},
+		{"amox", "amoxicillin", 2},
+		{"cillin", "amoxicillin", 1},
+		{"am", "ambroxol", 2},
+		{"ox", "amoxicillin", 0},            // Terlalu pendek untuk bagian kata
+		{"amoxicilin", "amoxicillin", 0.75}, // Salah ketik satu huruf
+		{"paracet", "parasetamol", 0.75},    // Dibandingkan dengan awalan kata
+		{"ctm", "cetirizine", 0},            // Terlalu pendek untuk fuzzy
+		{"ibuprofen", "omeprazole", 0},
+	}
+	for _, c := range cases {
+		if got := matchScore(c.term, c.word); got != c.want {
+			t.Errorf("matchScore(%q, %q) = %g, want %g", c.term, c.word, got, c.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"parasetamol", "paracetamol", 1},
+		{"ß", "ss", 2},
+	}
+	for _, c := range cases {
+		if got := levenshtein(c.a, c.b); got != c.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"AMOXICILLIN 500 MG KAPS", []string{"amoxicillin", "500", "mg", "kaps"}},
+		{"Ondansetron 4mg/2ml (inj)", []string{"ondansetron", "4mg", "2ml", "inj"}},
+		{"Salbutamol 0,5 mg. salbutamol", []string{"salbutamol", "0,5", "mg"}},
+		{"  ", []string{}},
+	}
+	for _, c := range cases {
+		if got := tokenize(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestParseKekuatan(t *testing.T) {
+	cases := []struct {
+		nama      string
+		kapasitas float64
+		want      string
+	}{
+		{"AMOXICILLIN 500 MG KAPS", 0, "500 mg"},
+		{"Ondansetron inj 0,5 mg/ml", 0, "0.5 mg/ml"},
+		{"Paracetamol syr 125mg/5ml", 0, "125 mg/5 ml"},
+		{"Ceftriaxone 1 g", 0, "1 g"},
+		{"Povidone iodine 10%", 0, "10 %"},
+		{"Infus RL", 500, "500"},
+		{"Kasa steril", 0, ""},
+	}
+	for _, c := range cases {
+		if got := parseKekuatan(c.nama, c.kapasitas); got != c.want {
+			t.Errorf("parseKekuatan(%q, %g) = %q, want %q", c.nama, c.kapasitas, got, c.want)
+		}
+	}
+}
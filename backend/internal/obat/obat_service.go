@@ -0,0 +1,212 @@
+package obat
+
+import (
+	"fmt"
+	"pwa-rsbw/internal/listranap"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Obat dianggap masih aktif jika diresepkan atau diberikan dalam rentang ini
+const activeWindow = 48 * time.Hour
+
+type ObatService interface {
+	GetObatPasien(noRawat, kdDokter string, filter ObatFilter) (*ObatResponse, error)
+	CreateResep(noRawat, kdDokter string, req ResepCreateRequest) (*Resep, error)
+	SearchObat(filter ObatSearchFilter) (*ObatSearchResponse, error)
+
+	// Master generik & formularium (admin)
+	GetGenerik() ([]ObatGenerik, error)
+	ImportGenerik(req ObatGenerikImportRequest) (int, error)
+	DeleteGenerik(kodeBrng string) error
+	GetFormularium(kdPj string) ([]ObatFormularium, error)
+	ImportFormularium(kdPj string, req FormulariumImportRequest) (int, error)
+	DeleteFormularium(kdPj, kodeBrng string) error
+}
+
+type obatService struct {
+	obatRepo ObatRepository
+	access   listranap.AccessChecker
+	kdDepo   string // Depo farmasi (gudangbarang.kd_bangsal) untuk cek stok e-resep, kosong = semua
+	index    *ObatIndex
+}
+
+func NewObatService(obatRepo ObatRepository, access listranap.AccessChecker, kdDepo string, index *ObatIndex) ObatService {
+	return &obatService{
+		obatRepo: obatRepo,
+		access:   access,
+		kdDepo:   kdDepo,
+		index:    index,
+	}
+}
+
+// ✅ Daftar terapi: gabungan resep dokter, pemberian obat dan aturan pakai, per barang
+func (s *obatService) GetObatPasien(noRawat, kdDokter string, filter ObatFilter) (*ObatResponse, error) {
+	fmt.Printf("🔍 Getting medication for: %s by doctor: %s\n", noRawat, kdDokter)
+
+	if err := s.access.CheckAccess(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ Medication access denied for %s: %v\n", kdDokter, err)
+		return nil, err
+	}
+
+	items, err := s.currentObat(noRawat)
+	if err != nil {
+		return nil, err
+	}
+
+	aktif := 0
+	data := []ObatItem{}
+	for _, item := range items {
+		if item.Aktif {
+			aktif++
+		}
+		if item.Aktif || filter.All {
+			data = append(data, item)
+		}
+	}
+
+	fmt.Printf("✅ Found %d medications (%d active) for %s\n", len(items), aktif, noRawat)
+	return &ObatResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Found %d active medications", aktif),
+		NoRawat: noRawat,
+		Aktif:   aktif,
+		Data:    data,
+	}, nil
+}
+
+// ✅ Pencarian obat dari indeks di memori (databarang + generik + formularium)
+func (s *obatService) SearchObat(filter ObatSearchFilter) (*ObatSearchResponse, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	kdPj := strings.TrimSpace(filter.KdPj)
+
+	results, total, indexedAt, err := s.index.Search(filter.Q, kdPj, limit)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []ObatSearchResult{}
+	}
+
+	return &ObatSearchResponse{
+		Status:    "success",
+		Message:   "Drug search results",
+		Query:     strings.TrimSpace(filter.Q),
+		Total:     total,
+		IndexedAt: indexedAt,
+		Data:      results,
+	}, nil
+}
+
+// currentObat: semua obat selama rawat inap, dipakai daftar terapi dan cek duplikasi e-resep
+func (s *obatService) currentObat(noRawat string) ([]ObatItem, error) {
+	resep, err := s.obatRepo.FindResep(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Error getting prescriptions: %v\n", err)
+		return nil, err
+	}
+	pemberian, err := s.obatRepo.FindPemberian(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Error getting medication administration: %v\n", err)
+		return nil, err
+	}
+	aturan, err := s.obatRepo.FindAturanPakai(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Error getting aturan pakai: %v\n", err)
+		return nil, err
+	}
+	return mergeObat(resep, pemberian, aturan, time.Now()), nil
+}
+
+func mergeObat(resep []ResepRow, pemberian []PemberianRow, aturan []AturanRow, now time.Time) []ObatItem {
+	byKode := map[string]*ObatItem{}
+	var order []string
+
+	get := func(kode, nama, satuan string) *ObatItem {
+		item, ok := byKode[kode]
+		if !ok {
+			item = &ObatItem{KodeBrng: kode, NamaBrng: nama, Satuan: satuan}
+			byKode[kode] = item
+			order = append(order, kode)
+		}
+		return item
+	}
+
+	// Resep urut terlama → terbaru, jadi resep terakhir menimpa aturan pakai dan dokter
+	for _, row := range resep {
+		item := get(row.KodeBrng, row.NamaBrng, row.Satuan)
+		if item.TglMulai == "" {
+			item.TglMulai = row.Waktu
+		}
+		item.TerakhirDiresepkan = row.Waktu
+		item.DokterPeresep = row.NmDokter
+		item.Racikan = row.NamaRacik
+		if row.AturanPakai != "" {
+			item.AturanPakai = row.AturanPakai
+		}
+	}
+
+	for _, row := range pemberian {
+		item := get(row.KodeBrng, row.NamaBrng, row.Satuan)
+		if item.TglMulai == "" || row.Pertama < item.TglMulai {
+			item.TglMulai = row.Pertama
+		}
+		item.TerakhirDiberikan = row.Terakhir
+		item.JumlahPemberian = row.JumlahPemberian
+		item.TotalDiberikan = row.TotalJml
+	}
+
+	// Aturan pakai saat pemberian lebih baru dari resep awal (mis. dosis diturunkan)
+	seen := map[string]bool{}
+	for _, row := range aturan {
+		if seen[row.KodeBrng] {
+			continue
+		}
+		seen[row.KodeBrng] = true
+		if item, ok := byKode[row.KodeBrng]; ok {
+			item.AturanPakai = row.Aturan
+		}
+	}
+
+	items := make([]ObatItem, 0, len(order))
+	for _, kode := range order {
+		item := byKode[kode]
+		item.Dosis, item.Frekuensi, item.Rute = parseAturanPakai(item.AturanPakai)
+		item.Aktif = isRecent(item.TerakhirDiresepkan, now) || isRecent(item.TerakhirDiberikan, now)
+		items = append(items, *item)
+	}
+
+	// Aktif dulu, lalu yang terakhir diberikan/diresepkan paling baru
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Aktif != items[j].Aktif {
+			return items[i].Aktif
+		}
+		return lastEvent(items[i]) > lastEvent(items[j])
+	})
+	return items
+}
+
+func lastEvent(item ObatItem) string {
+	if item.TerakhirDiberikan > item.TerakhirDiresepkan {
+		return item.TerakhirDiberikan
+	}
+	return item.TerakhirDiresepkan
+}
+
+func isRecent(waktu string, now time.Time) bool {
+	if waktu == "" {
+		return false
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", waktu, time.Local)
+	if err != nil {
+		return false
+	}
+	return now.Sub(t) <= activeWindow
+}
@@ -0,0 +1,54 @@
+package obat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeObat(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	resep := []ResepRow{
+		{NoResep: "202603070001", Waktu: "2026-03-07 08:00:00", NmDokter: "dr. A", KodeBrng: "B001", NamaBrng: "Paracetamol 500 mg", AturanPakai: "3x1 tab"},
+		{NoResep: "202603100001", Waktu: "2026-03-10 07:00:00", NmDokter: "dr. B", KodeBrng: "B002", NamaBrng: "CTM 4 mg", AturanPakai: "3x1 bungkus", NamaRacik: "Puyer batuk"},
+		{NoResep: "202603100001", Waktu: "2026-03-10 07:00:00", NmDokter: "dr. B", KodeBrng: "B003", NamaBrng: "Ambroxol 30 mg", AturanPakai: "3x1 bungkus", NamaRacik: "Puyer batuk"},
+	}
+	pemberian := []PemberianRow{
+		{KodeBrng: "B001", NamaBrng: "Paracetamol 500 mg", Pertama: "2026-03-07 09:00:00", Terakhir: "2026-03-07 21:00:00", TotalJml: 2, JumlahPemberian: 2},
+	}
+	aturan := []AturanRow{
+		{KodeBrng: "B001", Aturan: "2x1 tab"},
+		{KodeBrng: "B001", Aturan: "3x1 tab"},
+	}
+
+	items := mergeObat(resep, pemberian, aturan, now)
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+
+	byKode := map[string]ObatItem{}
+	for _, item := range items {
+		byKode[item.KodeBrng] = item
+	}
+
+	para := byKode["B001"]
+	if para.Aktif {
+		t.Error("B001 last given more than 48h ago, want inactive")
+	}
+	if para.AturanPakai != "2x1 tab" || para.Frekuensi != "2x sehari" {
+		t.Errorf("B001 aturan = %q (%q), want latest administration rule", para.AturanPakai, para.Frekuensi)
+	}
+	if para.TglMulai != "2026-03-07 08:00:00" || para.JumlahPemberian != 2 {
+		t.Errorf("B001 = %+v, want start at prescription and 2 administrations", para)
+	}
+
+	for _, kode := range []string{"B002", "B003"} {
+		item := byKode[kode]
+		if !item.Aktif || item.Racikan != "Puyer batuk" || item.DokterPeresep != "dr. B" {
+			t.Errorf("%s = %+v, want active racikan component", kode, item)
+		}
+	}
+	if items[2].KodeBrng != "B001" {
+		t.Errorf("inactive medication should be listed last, got order %s, %s, %s",
+			items[0].KodeBrng, items[1].KodeBrng, items[2].KodeBrng)
+	}
+}
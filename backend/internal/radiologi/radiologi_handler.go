@@ -0,0 +1,82 @@
+package radiologi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pwa-rsbw/internal/listranap"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RadiologiHandler struct {
+	radiologiService RadiologiService
+}
+
+func NewRadiologiHandler(radiologiService RadiologiService) *RadiologiHandler {
+	return &RadiologiHandler{
+		radiologiService: radiologiService,
+	}
+}
+
+// ✅ Expertise radiologi + link gambar untuk satu pasien
+func (h *RadiologiHandler) GetRadiologi(c *gin.Context) {
+	noRawat := c.Param("no_rawat")
+	kdDokter := c.GetString("kd_dokter")
+	if kdDokter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Doctor code not found in token",
+		})
+		return
+	}
+
+	response, err := h.radiologiService.GetRadiologi(noRawat, kdDokter)
+	if errors.Is(err, listranap.ErrAksesDitolak) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get radiology results",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ✅ Gambar radiologi lewat URL bertanda tangan (publik, tanpa JWT)
+func (h *RadiologiHandler) ServeImage(c *gin.Context) {
+	var req ImageRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid image link",
+		})
+		return
+	}
+
+	file, err := h.radiologiService.ResolveImage(req)
+	if errors.Is(err, ErrInvalidSignature) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": ErrImageNotFound.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(h.radiologiService.ImageTTL().Seconds())))
+	c.File(file)
+}
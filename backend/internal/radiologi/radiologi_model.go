@@ -0,0 +1,67 @@
+package radiologi
+
+import "time"
+
+// PemeriksaanRow: satu baris periksa_radiologi (satu jenis pemeriksaan)
+type PemeriksaanRow struct {
+	TglPeriksa      string `gorm:"column:tgl_periksa"` // YYYY-MM-DD
+	Jam             string `gorm:"column:jam"`         // HH:MM:SS
+	KdJenisPrw      string `gorm:"column:kd_jenis_prw"`
+	NmPerawatan     string `gorm:"column:nm_perawatan"`
+	DokterPerujuk   string `gorm:"column:dokter_perujuk"`
+	DokterRadiologi string `gorm:"column:dokter_radiologi"`
+	Proyeksi        string `gorm:"column:proyeksi"`
+	Status          string `gorm:"column:status"`
+}
+
+// HasilRow: expertise dokter radiologi (hasil_radiologi)
+type HasilRow struct {
+	TglPeriksa string `gorm:"column:tgl_periksa"`
+	Jam        string `gorm:"column:jam"`
+	Hasil      string `gorm:"column:hasil"`
+}
+
+// GambarRow: lokasi file gambar (gambar_radiologi), relatif terhadap RADIOLOGI_STORAGE_PATH
+type GambarRow struct {
+	TglPeriksa   string `gorm:"column:tgl_periksa"`
+	Jam          string `gorm:"column:jam"`
+	LokasiGambar string `gorm:"column:lokasi_gambar"`
+}
+
+type RadiologiPemeriksaan struct {
+	KdJenisPrw  string `json:"kd_jenis_prw"`
+	NmPerawatan string `json:"nm_perawatan"`
+	Proyeksi    string `json:"proyeksi,omitempty"`
+}
+
+// RadiologiImage: URL bertanda tangan, hanya berlaku sampai ExpiresAt
+type RadiologiImage struct {
+	Nama      string    `json:"nama"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RadiologiExam: satu pemeriksaan radiologi (tanggal + jam yang sama) dengan expertise dan gambar
+type RadiologiExam struct {
+	TglPeriksa      string                 `json:"tgl_periksa"`
+	Jam             string                 `json:"jam"`
+	DokterPerujuk   string                 `json:"dokter_perujuk"`
+	DokterRadiologi string                 `json:"dokter_radiologi"`
+	Pemeriksaan     []RadiologiPemeriksaan `json:"pemeriksaan"`
+	Expertise       string                 `json:"expertise"` // Kosong = belum dibaca
+	Gambar          []RadiologiImage       `json:"gambar"`
+}
+
+type RadiologiResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	NoRawat string          `json:"no_rawat"`
+	Data    []RadiologiExam `json:"data"` // Terbaru di atas
+}
+
+// ImageRequest: query string URL gambar bertanda tangan
+type ImageRequest struct {
+	Path      string `form:"p" binding:"required"`
+	ExpiresAt int64  `form:"e" binding:"required"`
+	Signature string `form:"s" binding:"required"`
+}
@@ -0,0 +1,77 @@
+package radiologi
+
+import (
+	"gorm.io/gorm"
+)
+
+type RadiologiRepository interface {
+	FindPemeriksaan(noRawat string) ([]PemeriksaanRow, error)
+	FindHasil(noRawat string) ([]HasilRow, error)
+	FindGambar(noRawat string) ([]GambarRow, error)
+}
+
+type radiologiRepository struct {
+	db *gorm.DB
+}
+
+func NewRadiologiRepository(db *gorm.DB) RadiologiRepository {
+	return &radiologiRepository{
+		db: db,
+	}
+}
+
+// ✅ Pemeriksaan radiologi satu rawat inap, terbaru di atas
+func (r *radiologiRepository) FindPemeriksaan(noRawat string) ([]PemeriksaanRow, error) {
+	var rows []PemeriksaanRow
+	err := r.db.Raw(`
+	SELECT
+		DATE_FORMAT(pr.tgl_periksa, '%Y-%m-%d') as tgl_periksa,
+		CAST(pr.jam AS CHAR) as jam,
+		pr.kd_jenis_prw,
+		COALESCE(jpr.nm_perawatan, pr.kd_jenis_prw) as nm_perawatan,
+		COALESCE(perujuk.nm_dokter, pr.dokter_perujuk, '') as dokter_perujuk,
+		COALESCE(radiolog.nm_dokter, pr.kd_dokter, '') as dokter_radiologi,
+		COALESCE(pr.proyeksi, '') as proyeksi,
+		COALESCE(pr.status, '') as status
+	FROM periksa_radiologi pr
+	LEFT JOIN jns_perawatan_radiologi jpr ON pr.kd_jenis_prw = jpr.kd_jenis_prw
+	LEFT JOIN dokter perujuk ON pr.dokter_perujuk = perujuk.kd_dokter
+	LEFT JOIN dokter radiolog ON pr.kd_dokter = radiolog.kd_dokter
+	WHERE pr.no_rawat = ?
+	ORDER BY pr.tgl_periksa DESC, pr.jam DESC, pr.kd_jenis_prw`, noRawat).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *radiologiRepository) FindHasil(noRawat string) ([]HasilRow, error) {
+	var rows []HasilRow
+	err := r.db.Raw(`
+	SELECT
+		DATE_FORMAT(tgl_periksa, '%Y-%m-%d') as tgl_periksa,
+		CAST(jam AS CHAR) as jam,
+		COALESCE(hasil, '') as hasil
+	FROM hasil_radiologi
+	WHERE no_rawat = ?`, noRawat).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *radiologiRepository) FindGambar(noRawat string) ([]GambarRow, error) {
+	var rows []GambarRow
+	err := r.db.Raw(`
+	SELECT
+		DATE_FORMAT(tgl_periksa, '%Y-%m-%d') as tgl_periksa,
+		CAST(jam AS CHAR) as jam,
+		lokasi_gambar
+	FROM gambar_radiologi
+	WHERE no_rawat = ? AND lokasi_gambar <> ''
+	ORDER BY lokasi_gambar`, noRawat).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
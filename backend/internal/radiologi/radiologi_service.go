@@ -0,0 +1,125 @@
+package radiologi
+
+import (
+	"fmt"
+	"path"
+	"pwa-rsbw/internal/listranap"
+	"time"
+)
+
+type RadiologiService interface {
+	GetRadiologi(noRawat, kdDokter string) (*RadiologiResponse, error)
+	ResolveImage(req ImageRequest) (string, error)
+	ImageTTL() time.Duration
+}
+
+type radiologiService struct {
+	radiologiRepo RadiologiRepository
+	access        listranap.AccessChecker
+	signer        *urlSigner
+}
+
+func NewRadiologiService(radiologiRepo RadiologiRepository, access listranap.AccessChecker, storagePath, urlSecret string, urlTTL time.Duration) RadiologiService {
+	return &radiologiService{
+		radiologiRepo: radiologiRepo,
+		access:        access,
+		signer:        newURLSigner(storagePath, urlSecret, urlTTL),
+	}
+}
+
+// ✅ Pemeriksaan radiologi + expertise + URL gambar bertanda tangan untuk satu pasien
+func (s *radiologiService) GetRadiologi(noRawat, kdDokter string) (*RadiologiResponse, error) {
+	fmt.Printf("🔍 Getting radiology for: %s by doctor: %s\n", noRawat, kdDokter)
+
+	if err := s.access.CheckAccess(noRawat, kdDokter); err != nil {
+		fmt.Printf("❌ Radiology access denied for %s: %v\n", kdDokter, err)
+		return nil, err
+	}
+
+	pemeriksaan, err := s.radiologiRepo.FindPemeriksaan(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Error getting radiology exams: %v\n", err)
+		return nil, err
+	}
+	hasil, err := s.radiologiRepo.FindHasil(noRawat)
+	if err != nil {
+		fmt.Printf("❌ Error getting radiology reports: %v\n", err)
+		return nil, err
+	}
+
+	var gambar []GambarRow
+	if s.signer.enabled() {
+		gambar, err = s.radiologiRepo.FindGambar(noRawat)
+		if err != nil {
+			fmt.Printf("❌ Error getting radiology images: %v\n", err)
+			return nil, err
+		}
+	}
+
+	exams := s.groupExams(pemeriksaan, hasil, gambar, time.Now())
+
+	fmt.Printf("✅ Found %d radiology exams for %s\n", len(exams), noRawat)
+	return &RadiologiResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Found %d radiology exams", len(exams)),
+		NoRawat: noRawat,
+		Data:    exams,
+	}, nil
+}
+
+func (s *radiologiService) ResolveImage(req ImageRequest) (string, error) {
+	return s.signer.resolve(req, time.Now())
+}
+
+func (s *radiologiService) ImageTTL() time.Duration {
+	return s.signer.ttl
+}
+
+// groupExams: satu exam per tanggal + jam (key yang sama di periksa/hasil/gambar_radiologi)
+func (s *radiologiService) groupExams(pemeriksaan []PemeriksaanRow, hasil []HasilRow, gambar []GambarRow, now time.Time) []RadiologiExam {
+	expertise := make(map[string]string, len(hasil))
+	for _, row := range hasil {
+		key := row.TglPeriksa + " " + row.Jam
+		if expertise[key] != "" {
+			expertise[key] += "\n\n"
+		}
+		expertise[key] += row.Hasil
+	}
+
+	images := make(map[string][]RadiologiImage)
+	for _, row := range gambar {
+		key := row.TglPeriksa + " " + row.Jam
+		url, expiresAt := s.signer.signedURL(row.LokasiGambar, now)
+		images[key] = append(images[key], RadiologiImage{
+			Nama:      path.Base(row.LokasiGambar),
+			URL:       url,
+			ExpiresAt: expiresAt,
+		})
+	}
+
+	exams := []RadiologiExam{}
+	for _, row := range pemeriksaan {
+		key := row.TglPeriksa + " " + row.Jam
+		if len(exams) == 0 || exams[len(exams)-1].TglPeriksa+" "+exams[len(exams)-1].Jam != key {
+			exams = append(exams, RadiologiExam{
+				TglPeriksa:      row.TglPeriksa,
+				Jam:             row.Jam,
+				DokterPerujuk:   row.DokterPerujuk,
+				DokterRadiologi: row.DokterRadiologi,
+				Pemeriksaan:     []RadiologiPemeriksaan{},
+				Expertise:       expertise[key],
+				Gambar:          images[key],
+			})
+			if exams[len(exams)-1].Gambar == nil {
+				exams[len(exams)-1].Gambar = []RadiologiImage{}
+			}
+		}
+		exam := &exams[len(exams)-1]
+		exam.Pemeriksaan = append(exam.Pemeriksaan, RadiologiPemeriksaan{
+			KdJenisPrw:  row.KdJenisPrw,
+			NmPerawatan: row.NmPerawatan,
+			Proyeksi:    row.Proyeksi,
+		})
+	}
+	return exams
+}
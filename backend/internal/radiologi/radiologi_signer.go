@@ -0,0 +1,82 @@
+package radiologi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid or expired image link")
+	ErrImageNotFound    = errors.New("image not found")
+	ErrStorageDisabled  = errors.New("radiology image storage is not configured")
+)
+
+// imageRoute: endpoint publik yang melayani gambar (tag <img> tidak bisa mengirim Authorization)
+const imageRoute = "/api/v1/radiologi/gambar"
+
+// urlSigner membuat dan memverifikasi URL gambar bertanda tangan HMAC yang berumur pendek
+type urlSigner struct {
+	root   string // RADIOLOGI_STORAGE_PATH, kosong = gambar tidak tersedia
+	secret []byte
+	ttl    time.Duration
+}
+
+func newURLSigner(root, secret string, ttl time.Duration) *urlSigner {
+	if root != "" {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+	}
+	return &urlSigner{root: root, secret: []byte(secret), ttl: ttl}
+}
+
+// enabled: gambar hanya dilayani jika storage diatur DAN ada kunci HMAC (tanpa kunci, URL bisa dipalsukan)
+func (s *urlSigner) enabled() bool {
+	return s.root != "" && len(s.secret) > 0
+}
+
+func (s *urlSigner) sign(path string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(expiresAt, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signedURL: URL relatif terhadap host API, berlaku selama ttl
+func (s *urlSigner) signedURL(path string, now time.Time) (string, time.Time) {
+	expiresAt := now.Add(s.ttl)
+	query := url.Values{}
+	query.Set("p", path)
+	query.Set("e", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("s", s.sign(path, expiresAt.Unix()))
+	return imageRoute + "?" + query.Encode(), expiresAt
+}
+
+// ✅ resolve memverifikasi tanda tangan lalu mengembalikan path file di dalam root storage.
+// Path yang keluar dari root (../) ditolak walaupun tanda tangannya valid.
+func (s *urlSigner) resolve(req ImageRequest, now time.Time) (string, error) {
+	if !s.enabled() {
+		return "", ErrStorageDisabled
+	}
+	expected := s.sign(req.Path, req.ExpiresAt)
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) || now.Unix() > req.ExpiresAt {
+		return "", ErrInvalidSignature
+	}
+
+	full := filepath.Join(s.root, filepath.FromSlash(strings.TrimPrefix(req.Path, "/")))
+	if full != s.root && !strings.HasPrefix(full, s.root+string(filepath.Separator)) {
+		return "", ErrImageNotFound
+	}
+	info, err := os.Stat(full)
+	if err != nil || info.IsDir() {
+		return "", ErrImageNotFound
+	}
+	return full, nil
+}
@@ -0,0 +1,144 @@
+package radiologi
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// requestFromURL mem-parse URL hasil signedURL seperti binding query handler
+func requestFromURL(t *testing.T, signed string) ImageRequest {
+	t.Helper()
+
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	expiresAt, err := strconv.ParseInt(query.Get("e"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ImageRequest{Path: query.Get("p"), ExpiresAt: expiresAt, Signature: query.Get("s")}
+}
+
+func TestURLSignerResolve(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "pages", "upload"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(root, "pages", "upload", "thorax.jpg")
+	if err := os.WriteFile(image, []byte("jpg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(root), "secret.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	signer := newURLSigner(root, "radiologi-test-secret", 10*time.Minute)
+	other := newURLSigner(root, "another-secret", 10*time.Minute)
+	now := time.Now()
+
+	signed := func(s *urlSigner, path string) ImageRequest {
+		u, _ := s.signedURL(path, now)
+		return requestFromURL(t, u)
+	}
+
+	tests := []struct {
+		name    string
+		req     func() ImageRequest
+		at      time.Time
+		want    string
+		wantErr error
+	}{
+		{
+			name: "valid link",
+			req:  func() ImageRequest { return signed(signer, "pages/upload/thorax.jpg") },
+			at:   now,
+			want: image,
+		},
+		{
+			name:    "expired link",
+			req:     func() ImageRequest { return signed(signer, "pages/upload/thorax.jpg") },
+			at:      now.Add(11 * time.Minute),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "path swapped after signing",
+			req: func() ImageRequest {
+				req := signed(signer, "pages/upload/thorax.jpg")
+				req.Path = "pages/upload/other.jpg"
+				return req
+			},
+			at:      now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "expiry extended after signing",
+			req: func() ImageRequest {
+				req := signed(signer, "pages/upload/thorax.jpg")
+				req.ExpiresAt += 3600
+				return req
+			},
+			at:      now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "signed with another secret",
+			req:     func() ImageRequest { return signed(other, "pages/upload/thorax.jpg") },
+			at:      now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			// Tanda tangan valid tetap tidak boleh keluar dari root storage
+			name:    "path traversal",
+			req:     func() ImageRequest { return signed(signer, "../secret.txt") },
+			at:      now,
+			wantErr: ErrImageNotFound,
+		},
+		{
+			name:    "missing file",
+			req:     func() ImageRequest { return signed(signer, "pages/upload/missing.jpg") },
+			at:      now,
+			wantErr: ErrImageNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signer.resolve(tt.req(), tt.at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLSignerEnabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		root   string
+		secret string
+		want   bool
+	}{
+		{"storage and secret", "/data/radiologi", "secret", true},
+		{"no storage", "", "secret", false},
+		// Tanpa kunci HMAC siapa pun bisa membuat tanda tangan yang valid
+		{"no secret", "/data/radiologi", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newURLSigner(tt.root, tt.secret, time.Minute).enabled(); got != tt.want {
+				t.Errorf("enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
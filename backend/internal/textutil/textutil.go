@@ -0,0 +1,11 @@
+// Package textutil berisi helper string kecil yang dipakai beberapa modul.
+package textutil
+
+// Truncate memotong string sesuai ukuran kolom tanpa merusak karakter UTF-8
+func Truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,27 @@
+package textutil
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		max   int
+		want  string
+	}{
+		{"shorter than max", "abc", 5, "abc"},
+		{"exactly max", "abcde", 5, "abcde"},
+		{"longer than max", "abcdef", 5, "abcde"},
+		{"multibyte is cut per rune", "dr. Ñoño 🚑", 9, "dr. Ñoño "},
+		{"empty", "", 3, ""},
+		{"zero max", "abc", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.value, tt.max); got != tt.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.value, tt.max, got, tt.want)
+			}
+		})
+	}
+}
@@ -40,7 +40,7 @@ export const patientService = {
   async getPatientById(id) {
     try {
       console.log('🔍 Fetching patient detail:', id);
-      const response = await api.get(`/ranap/pasien/${id}`);
+      const response = await api.get(`/ranap/pasien/${encodeURIComponent(id)}`);
       
       if (response.data.status === 'success') {
         console.log('✅ Patient detail received:', response.data.data);